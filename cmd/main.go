@@ -1,38 +1,101 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"fmt"
 
+	"github.com/sofc-t/sentinel/domain/models"
+	"github.com/sofc-t/sentinel/exporter"
 	"github.com/sofc-t/sentinel/probe"
 	sentinel "github.com/sofc-t/sentinel/sentinel_core"
-	"github.com/gosnmp/gosnmp"
 )
 
-func lookupVendorFromMAC(mac string) string {
-	if mac == "" {
-		return ""
+func main() {
+	exportFormat := flag.String("export", "", "export the topology as "+strings.Join(exporter.Formats, ", "))
+	exportPath := flag.String("out", "", "file to write the export to (default stdout)")
+	passive := flag.Duration("passive", 0, "only listen for ARP/NDP/DHCP traffic for this long, sending no probes")
+	pcapFile := flag.String("pcap", "", "run passive discovery against a pcap file instead of a live interface")
+	portSpec := flag.String("ports", probe.DefaultPortProfile, "ports to scan: numbers, ranges and profiles ("+portProfileNames()+")")
+	scanMethod := flag.String("scan-method", probe.ScanAuto, "port scan method: auto, syn or connect")
+	sigFile := flag.String("signatures", "", "JSON file of extra banner probes and signatures")
+	httpRuleFile := flag.String("http-rules", "", "JSON file of extra web interface fingerprint rules")
+	ouiFile := flag.String("oui", "", "IEEE OUI CSV (oui.csv, mam.csv or oui36.csv) to use on top of the embedded registry")
+	sshKeyFile := flag.String("ssh-keys", "", "file remembering SSH host keys between scans, to detect key changes")
+	snmpCredFile := flag.String("snmp-credentials", "", "JSON file with the SNMP communities and SNMPv3 users to try (default v2c \"public\")")
+	snmpCacheFile := flag.String("snmp-cache", "", "file remembering which SNMP credentials each device answered to")
+	rateInterval := flag.Duration("rate-interval", 0, "poll SNMP interface counters twice this far apart to report throughput and utilisation")
+	certWarn := flag.Duration("cert-warn", 30*24*time.Hour, "flag TLS certificates that expire within this window")
+	flag.Parse()
+
+	ports, err := probe.ParsePorts(*portSpec)
+	if err != nil {
+		log.Fatalf("Invalid -ports: %v", err)
 	}
-	parts := strings.Split(mac, ":")
-	if len(parts) > 2 {
-		return "Vendor-" + strings.ToUpper(parts[0]) + strings.ToUpper(parts[1])
+	if err := probe.CheckScanMethod(*scanMethod); err != nil {
+		log.Fatalf("Invalid -scan-method: %v", err)
+	}
+	if *ouiFile != "" {
+		if err := probe.LoadOUIFile(*ouiFile); err != nil {
+			log.Fatalf("Invalid -oui: %v", err)
+		}
+	}
+	signatures := probe.DefaultSignatureDB()
+	if *sigFile != "" {
+		if err := signatures.LoadSignatureFile(*sigFile); err != nil {
+			log.Fatalf("Invalid -signatures: %v", err)
+		}
+	}
+	snmpCreds := []probe.SNMPCredentials{probe.DefaultSNMPCredentials}
+	if *snmpCredFile != "" {
+		if snmpCreds, err = probe.LoadSNMPCredentials(*snmpCredFile); err != nil {
+			log.Fatalf("Invalid -snmp-credentials: %v", err)
+		}
+	}
+	snmpSweeper, err := probe.NewSNMPSweeper(snmpCreds, *snmpCacheFile)
+	if err != nil {
+		log.Fatalf("Invalid -snmp-cache: %v", err)
+	}
+	httpRules := probe.DefaultHTTPRules()
+	if *httpRuleFile != "" {
+		if err := httpRules.LoadRuleFile(*httpRuleFile); err != nil {
+			log.Fatalf("Invalid -http-rules: %v", err)
+		}
 	}
-	return "UnknownVendor"
-}
 
-func main() {
 	allDevices := []sentinel.DeviceRecord{}
 
+	if *pcapFile != "" {
+		runPassive("", *pcapFile, 0)
+		return
+	}
+
 	interfaceName, subnet, err := probe.FindDefaultInterfaceAndSubnet()
 	if err != nil {
 		log.Fatalf("Failed to find default network interface: %v", err)
 	}
 	log.Printf("[Main] Using Interface: %s, Subnet: %s\n", interfaceName, subnet)
 
+	if *passive > 0 {
+		runPassive(interfaceName, "", *passive)
+		return
+	}
+
+	// Neighbor adjacencies from LLDP/CDP, used to build the topology
+	var neighbors []probe.NeighborEntry
+	var neighborsMu sync.Mutex
+	localName, _ := os.Hostname()
+	localAddr, _, _ := strings.Cut(subnet, "/")
+
 	// Channels for discovered devices
 	devChan := make(chan sentinel.DeviceRecord, 100)
 	var wg sync.WaitGroup
@@ -41,21 +104,31 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		lldpDevices, err := probe.CaptureLLDP(interfaceName, 10*time.Second)
+		lldpNeighbors, err := probe.CaptureLLDPNeighbors(interfaceName, 10*time.Second)
 		if err != nil {
 			log.Println("LLDP capture error:", err)
 			return
 		}
-		for _, d := range lldpDevices {
+		neighborsMu.Lock()
+		for i := range lldpNeighbors {
+			neighbors = append(neighbors, lldpNeighbors[i].NeighborEntry(localName, localAddr))
+		}
+		neighborsMu.Unlock()
+
+		for _, d := range probe.LLDPDevices(lldpNeighbors) {
 			devChan <- sentinel.DeviceRecord{
 				DeviceID:  d.GetID(),
+				Source:    sentinel.SourceLLDP,
 				Hostname:  d.GetHostname(),
 				IP:        d.GetIPAddress(),
 				MAC:       d.GetMACAddress(),
 				Status:    d.GetStatus(),
+				LLDP:      d.GetChassisID(),
+				Descr:     d.GetDescription(),
 				Type:      d.GetDeviceType(),
 				Vendor:    d.GetVendor(),
 				Protocols: strings.Join(d.GetMonitoringProtocols(), ","),
+				SysName:   d.GetHostname(),
 			}
 		}
 	}()
@@ -72,8 +145,11 @@ func main() {
 		for _, d := range ipDevices {
 			devChan <- sentinel.DeviceRecord{
 				DeviceID:  d.GetID(),
+				Source:    sentinel.SourceNmap,
 				Hostname:  d.GetHostname(),
 				IP:        d.GetIPAddress(),
+				IPs:       d.GetIPAddresses(),
+				MAC:       d.GetMACAddress(),
 				Status:    d.GetStatus(),
 				Type:      d.GetDeviceType(),
 				Vendor:    d.GetVendor(),
@@ -86,16 +162,53 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		arpDevices, err := probe.ARPScan(interfaceName)
+		prefix, err := netip.ParsePrefix(subnet)
 		if err != nil {
 			log.Println("ARP scan error:", err)
 			return
 		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		arpDevices, err := probe.ARPScan(ctx, probe.ARPScanConfig{
+			Interface: interfaceName,
+			Prefixes:  []netip.Prefix{prefix},
+		})
+		if err != nil {
+			// A cancelled sweep still returns the hosts found so far
+			log.Println("ARP scan error:", err)
+		}
 		for _, d := range arpDevices {
 			devChan <- sentinel.DeviceRecord{
 				DeviceID:  d.GetID(),
+				Source:    sentinel.SourceARP,
+				Hostname:  d.GetHostname(),
+				IP:        d.GetIPAddress(),
+				MAC:       d.GetMACAddress(),
+				Status:    d.GetStatus(),
+				Type:      d.GetDeviceType(),
+				Vendor:    d.GetVendor(),
+				Protocols: strings.Join(d.GetMonitoringProtocols(), ","),
+			}
+		}
+	}()
+
+	// IPv6 neighbor discovery
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		ndpDevices, err := probe.NDPScan(ctx, probe.NDPScanConfig{Interface: interfaceName})
+		if err != nil {
+			log.Println("NDP scan error:", err)
+		}
+		for _, d := range ndpDevices {
+			devChan <- sentinel.DeviceRecord{
+				DeviceID:  d.GetID(),
+				Source:    sentinel.SourceNDP,
 				Hostname:  d.GetHostname(),
 				IP:        d.GetIPAddress(),
+				IPs:       d.GetIPAddresses(),
 				MAC:       d.GetMACAddress(),
 				Status:    d.GetStatus(),
 				Type:      d.GetDeviceType(),
@@ -105,18 +218,103 @@ func main() {
 		}
 	}()
 
+	// mDNS/DNS-SD service browse
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mdnsDevices, err := probe.CaptureMDNS(15 * time.Second)
+		if err != nil {
+			log.Println("mDNS browse error:", err)
+			return
+		}
+		for _, d := range mdnsDevices {
+			devChan <- sentinel.DeviceRecord{
+				DeviceID:  d.GetID(),
+				Source:    sentinel.SourceMDNS,
+				Hostname:  d.GetHostname(),
+				IP:        d.GetIPAddress(),
+				IPs:       d.GetIPAddresses(),
+				Status:    d.GetStatus(),
+				Descr:     d.GetDescription(),
+				Type:      d.GetDeviceType(),
+				Vendor:    d.GetVendor(),
+				Protocols: strings.Join(d.GetMonitoringProtocols(), ","),
+			}
+		}
+	}()
+
+	// SSDP/UPnP discovery
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		ssdpDevices, err := probe.SSDPDiscover(ctx, probe.SSDPConfig{Interface: interfaceName})
+		if err != nil {
+			log.Println("SSDP discovery error:", err)
+			return
+		}
+		for _, d := range ssdpDevices {
+			descr := d.GetDescription()
+			if model := d.GetModel(); model != "" {
+				descr = strings.TrimSpace(descr + " (" + model + ")")
+			}
+			devChan <- sentinel.DeviceRecord{
+				DeviceID:     d.GetID(),
+				Source:       sentinel.SourceSSDP,
+				Hostname:     d.GetHostname(),
+				IP:           d.GetIPAddress(),
+				Status:       d.GetStatus(),
+				Descr:        descr,
+				Type:         d.GetDeviceType(),
+				Vendor:       d.GetVendor(),
+				Protocols:    strings.Join(d.GetMonitoringProtocols(), ","),
+				SerialNumber: d.GetSerialNumber(),
+			}
+		}
+	}()
+
+	// WS-Discovery (ONVIF cameras, Windows hosts, WSD printers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		wsdDevices, err := probe.WSDiscover(ctx, probe.WSDiscoveryConfig{})
+		if err != nil {
+			log.Println("WS-Discovery error:", err)
+			return
+		}
+		for _, d := range wsdDevices {
+			devChan <- sentinel.DeviceRecord{
+				DeviceID:  d.GetID(),
+				Source:    sentinel.SourceWSD,
+				Hostname:  d.GetHostname(),
+				IP:        d.GetIPAddress(),
+				Status:    d.GetStatus(),
+				Descr:     d.GetDescription(),
+				Type:      d.GetDeviceType(),
+				Vendor:    d.GetVendor(),
+				Protocols: strings.Join(d.GetMonitoringProtocols(), ","),
+			}
+		}
+	}()
+
 	// Wait for discovery scans
 	go func() {
 		wg.Wait()
 		close(devChan)
 	}()
 
-	// Collect devices
+	// Collect devices, merging the records several probes report for the
+	// same host
 	for d := range devChan {
 		allDevices = append(allDevices, d)
 	}
+	discovered := len(allDevices)
+	allDevices = sentinel.MergeRecords(allDevices)
 
-	log.Printf("[Main] Found %d devices.\n", len(allDevices))
+	log.Printf("[Main] Found %d devices (%d records).\n", len(allDevices), discovered)
 
 	// Ping all devices concurrently
 	pingChan := make(chan sentinel.DeviceRecord, len(allDevices))
@@ -138,6 +336,7 @@ func main() {
 					} else {
 						dev.Status = "inactive"
 					}
+					dev.Note(sentinel.SourcePing, "Status")
 				}
 			}
 			pingChan <- *dev
@@ -154,8 +353,42 @@ func main() {
 		allDevices = append(allDevices, d)
 	}
 
-	// SNMP + Nmap concurrently
-	snmpNmapChan := make(chan sentinel.DeviceRecord, len(allDevices))
+	// Port scan every device in one pass under a shared rate limit
+	var targets []string
+	for _, d := range allDevices {
+		if d.IP != "" {
+			targets = append(targets, d.IP)
+		}
+	}
+	scanCtx, cancelScan := context.WithTimeout(context.Background(), 10*time.Minute)
+	portResults, err := probe.ScanPorts(scanCtx, targets, probe.PortScanConfig{
+		Ports:  ports,
+		Method: *scanMethod,
+	})
+	if err != nil {
+		log.Println("Port scan error:", err)
+	}
+	udpResults, err := probe.ScanUDP(scanCtx, targets, probe.UDPScanConfig{})
+	cancelScan()
+	if err != nil {
+		log.Println("UDP scan error:", err)
+	}
+	openPorts := probe.OpenPorts(portResults)
+	openUDPPorts := probe.OpenPorts(udpResults)
+
+	var sshStore *probe.SSHKeyStore
+	if *sshKeyFile != "" {
+		if sshStore, err = probe.LoadSSHKeyStore(*sshKeyFile); err != nil {
+			log.Fatalf("Invalid -ssh-keys: %v", err)
+		}
+	}
+	var sshMu sync.Mutex
+	var sshInfos []*probe.SSHHostInfo
+
+	// SNMP + Nmap concurrently, each worker feeding its result into the
+	// processor
+	processor := sentinel.NewProcessor()
+	rateEngine := sentinel.NewRateEngine()
 	wgSNMP := sync.WaitGroup{}
 	semSNMP := make(chan struct{}, 20) // limit concurrency
 
@@ -170,17 +403,29 @@ func main() {
 			if dev.Hostname == "" && dev.IP != "" {
 				if names, err := net.LookupAddr(dev.IP); err == nil && len(names) > 0 {
 					dev.Hostname = strings.TrimSuffix(names[0], ".")
+					dev.Note(sentinel.SourceDNS, "Hostname")
 				}
 			}
 
 			// Nmap fingerprint
 			if dev.IP != "" {
-				if descr, protos := probe.NmapFingerprint(dev.IP); descr != "" {
-					dev.Descr = descr
-					if dev.Protocols != "" {
-						dev.Protocols += "," + protos
-					} else {
-						dev.Protocols = protos
+				if result, err := probe.NmapFingerprint(dev.IP); err == nil {
+					if descr, protos := result.Summary(); descr != "" {
+						dev.Descr = descr
+						dev.Note(sentinel.SourceNmap, "Descr")
+						if dev.Protocols != "" {
+							dev.Protocols += "," + protos
+						} else {
+							dev.Protocols = protos
+						}
+					}
+					if dev.Hostname == "" && len(result.Hostnames) > 0 {
+						dev.Hostname = result.Hostnames[0]
+						dev.Note(sentinel.SourceNmap, "Hostname")
+					}
+					if dev.MAC == "" && result.MAC != "" {
+						dev.MAC = result.MAC
+						dev.Note(sentinel.SourceNmap, "MAC")
 					}
 				}
 			}
@@ -190,115 +435,331 @@ func main() {
 				if dev.Hostname == "" {
 					if names, err := net.LookupAddr(dev.IP); err == nil && len(names) > 0 {
 						dev.Hostname = strings.TrimSuffix(names[0], ".")
+						dev.Note(sentinel.SourceDNS, "Hostname")
+					}
+				}
+
+				// NetBIOS node status for Windows/Samba hosts
+				if info, err := probe.NetBIOSScan(dev.IP); err == nil {
+					if dev.Hostname == "" && info.ComputerName != "" {
+						dev.Hostname = info.ComputerName
+						dev.Note(sentinel.SourceNetBIOS, "Hostname")
+					}
+					if dev.MAC == "" && info.MAC != "" {
+						dev.MAC = info.MAC
+						dev.Note(sentinel.SourceNetBIOS, "MAC")
+					}
+					if info.Workgroup != "" {
+						dev.Descr += fmt.Sprintf("Workgroup: %s ", info.Workgroup)
+					}
+					dev.Protocols += ",NetBIOS"
+				}
+
+				// LLMNR answers where NetBIOS is disabled
+				if dev.Hostname == "" {
+					if name, err := probe.LLMNRReverse(dev.IP, 500*time.Millisecond); err == nil {
+						dev.Hostname = name
+						dev.Note(sentinel.SourceLLMNR, "Hostname")
+						dev.Protocols += ",LLMNR"
 					}
 				}
 
 				// Port scan
-				openPorts := scanCommonPorts(dev.IP, 500*time.Millisecond)
-				if len(openPorts) > 0 {
+				if open := openPorts[dev.IP]; len(open) > 0 {
+					dev.OpenPorts = open
 					dev.Protocols += ",ports"
-					dev.Descr += fmt.Sprintf("Open ports: %v ", openPorts)
+					dev.Descr += fmt.Sprintf("Open ports: %v ", open)
 
-					// Guess OS
-					dev.Type = guessOS(openPorts)
+					// Identify services from their banners
+					matches := signatures.IdentifyServices(context.Background(), dev.IP, open, 2*time.Second)
+					for _, m := range matches {
+						dev.Descr += fmt.Sprintf("Service: %s ", m)
+					}
+					if hostOS := probe.HostOS(matches); hostOS != "" {
+						dev.Descr += fmt.Sprintf("OS: %s ", hostOS)
+					}
+					if deviceType := probe.HostDeviceType(matches); deviceType != "" {
+						dev.Type = deviceType
+						dev.Note(sentinel.SourceBanner, "Type")
+					}
+
+					// Certificate inventory on TLS ports and on ports that
+					// answered the banner probe with a TLS record
+					looksTLS := make(map[int]bool)
+					for _, m := range matches {
+						if m.Service == "ssl" {
+							looksTLS[m.Port] = true
+						}
+					}
+					var tlsCandidates []int
+					for _, p := range open {
+						if (probe.TLSPorts[p] || looksTLS[p]) && !probe.IsRawPrintPort(p) {
+							tlsCandidates = append(tlsCandidates, p)
+						}
+					}
+					tlsConfig := probe.TLSInspectConfig{ExpiryWarning: *certWarn}
+					if strings.Contains(dev.Hostname, ".") {
+						tlsConfig.ServerName = dev.Hostname
+					}
+					dev.TLS = probe.InspectTLSPorts(context.Background(), dev.IP, tlsCandidates, tlsConfig)
+					for _, svc := range dev.TLS {
+						if len(svc.Issues) > 0 {
+							log.Printf("[TLS] %s:%d certificate issues: %s", dev.IP, svc.Port, strings.Join(svc.Issues, ", "))
+						}
+					}
+					if len(dev.TLS) > 0 {
+						dev.Protocols += ",tls"
+					}
+
+					// SSH host keys and algorithms
+					for _, m := range matches {
+						if m.Service != "ssh" {
+							continue
+						}
+						info, err := probe.ProbeSSH(context.Background(), dev.IP, m.Port, 5*time.Second)
+						if err != nil {
+							continue
+						}
+						dev.Descr += fmt.Sprintf("SSH: %s ", info)
+						dev.SSHHostKeys = append(dev.SSHHostKeys, info.Fingerprints()...)
+						sshMu.Lock()
+						sshInfos = append(sshInfos, info)
+						if sshStore != nil {
+							for _, c := range sshStore.Update(info) {
+								log.Printf("[SSH] WARNING: %s %s host key changed from %s to %s", c.Addr, c.Type, c.Previous, c.Current)
+							}
+						}
+						sshMu.Unlock()
+					}
+
+					// Identify web management interfaces
+					var webPorts []int
+					for _, m := range matches {
+						if m.Service == "http" {
+							webPorts = append(webPorts, m.Port)
+						}
+					}
+					for _, svc := range dev.TLS {
+						webPorts = append(webPorts, svc.Port)
+					}
+					webs := httpRules.FingerprintPorts(context.Background(), dev.IP, webPorts, 5*time.Second)
+					for _, fp := range webs {
+						dev.Descr += fmt.Sprintf("Web: %s ", fp)
+					}
+					if web := probe.Identified(webs); web != nil {
+						if web.DeviceType != "" {
+							dev.Type = web.DeviceType
+							dev.Note(sentinel.SourceHTTP, "Type")
+						}
+						if dev.Vendor == "" && web.Vendor != "" {
+							dev.Vendor = web.Vendor
+							dev.Note(sentinel.SourceHTTP, "Vendor")
+						}
+					}
+					if len(webs) > 0 {
+						dev.Protocols += ",http"
+					}
+				}
+				if open := openUDPPorts[dev.IP]; len(open) > 0 {
+					dev.OpenUDPPorts = open
+					dev.Protocols += ",udp"
+					dev.Descr += fmt.Sprintf("Open UDP ports: %v ", open)
 				}
 			}
 
 
 			// SNMP metrics
 			if dev.IP != "" {
-				config := probe.SNMPConfig{
-					Target:    dev.IP,
-					Port:      161,
-					Version:   gosnmp.Version2c,
-					Community: "public",
-					Timeout:   2 * time.Second,
-					Retries:   1,
+				config, profile, err := snmpSweeper.Find(probe.SNMPConfig{
+					Target:  dev.IP,
+					Port:    161,
+					Timeout: 2 * time.Second,
+					Retries: 1,
+				})
+				if err == nil {
+					// Only the name: the credentials themselves stay out of logs
+					dev.SNMPProfile = profile
+					log.Printf("[SNMP] %s answered to credentials %q", dev.IP, profile)
 				}
-				oids := []string{
-					"1.3.6.1.2.1.1.3.0",
-					"1.3.6.1.2.1.1.5.0",
-					"1.3.6.1.2.1.1.1.0",
-					"1.3.6.1.2.1.2.2.1.10.1",
-					"1.3.6.1.2.1.2.2.1.16.1",
-					"1.3.6.1.2.1.2.2.1.14.1",
-					"1.3.6.1.2.1.2.2.1.20.1",
+				var metrics *models.SNMPResult
+				if err == nil {
+					metrics, err = probe.FetchMetrics(config, []string{
+						".1.3.6.1.2.1.1.3.0",
+						".1.3.6.1.2.1.1.5.0",
+						".1.3.6.1.2.1.1.1.0",
+					})
 				}
-				metrics, err := probe.FetchMetrics(config, oids)
-				if err == nil && metrics != nil && metrics.Metrics != nil {
-					if val, ok := metrics.Metrics.Values["1.3.6.1.2.1.2.2.1.10.1"]; ok {
-						dev.IntIn = parseInt64(val)
+				if err == nil {
+					// Device answers SNMP: ask it for its LLDP/CDP neighbors too
+					var found []probe.NeighborEntry
+					if entries, err := probe.WalkLLDPNeighbors(config); err == nil {
+						found = append(found, entries...)
 					}
-					if val, ok := metrics.Metrics.Values["1.3.6.1.2.1.2.2.1.16.1"]; ok {
-						dev.IntOut = parseInt64(val)
+					if entries, err := probe.WalkCDPNeighbors(config); err == nil {
+						found = append(found, entries...)
 					}
-					if val, ok := metrics.Metrics.Values["1.3.6.1.2.1.2.2.1.14.1"]; ok {
-						dev.InErrors = parseInt64(val)
+					neighborsMu.Lock()
+					neighbors = append(neighbors, found...)
+					neighborsMu.Unlock()
+
+					if sysName := metrics.Metrics.Values[".1.3.6.1.2.1.1.5.0"]; sysName != "" {
+						dev.SysName = sysName
+						dev.Note(sentinel.SourceSNMP, "SysName")
 					}
-					if val, ok := metrics.Metrics.Values["1.3.6.1.2.1.2.2.1.20.1"]; ok {
-						dev.OutErrors = parseInt64(val)
+
+					if hr, err := probe.FetchHostResources(config); err == nil {
+						dev.SetHostResources(hr)
+						log.Printf("[SNMP] %s resources: CPU from %s, memory from %s, %d storage areas",
+							dev.IP, orNone(hr.CPUSource), orNone(hr.MemSource), len(hr.Storage))
+					} else {
+						log.Printf("[SNMP] Host resources failed for %s: %v", dev.IP, err)
+					}
+
+					// Every port from ifTable/ifXTable, sampled twice for
+					// rates when asked to
+					if sample, err := sampleCounters(config); err == nil {
+						dev.SetInterfaces(sample.Interfaces)
+						rateEngine.Update(dev.DeviceID, sample)
+						if *rateInterval > 0 {
+							time.Sleep(*rateInterval)
+							if next, err := sampleCounters(config); err == nil {
+								dev.SetInterfaces(next.Interfaces)
+								dev.SetRates(rateEngine.Update(dev.DeviceID, next))
+							}
+						}
+					} else {
+						log.Printf("[IF-MIB] Interface walk failed for %s: %v", dev.IP, err)
 					}
 				}
 			}
 
 			// Vendor from MAC if missing
 			if dev.Vendor == "" && dev.MAC != "" {
-				dev.Vendor = lookupVendorFromMAC(dev.MAC)
+				dev.Vendor = probe.LookupVendor(dev.MAC)
+				dev.Note(sentinel.SourceOUI, "Vendor")
 			}
 
-			snmpNmapChan <- *dev
+			// Enrichment can reveal that two addresses belong to one host (a
+			// MAC from NetBIOS, a shared SSH host key); the processor merges
+			// them
+			processor.UpdateDevice(*dev)
 		}(&allDevices[i])
 	}
+	wgSNMP.Wait()
+	allDevices = processor.Devices()
 
-	go func() {
-		wgSNMP.Wait()
-		close(snmpNmapChan)
-	}()
-
-	allDevices = []sentinel.DeviceRecord{}
-	for d := range snmpNmapChan {
-		allDevices = append(allDevices, d)
+	for fp, hosts := range probe.SharedSSHHostKeys(sshInfos) {
+		log.Printf("[SSH] Host key %s is shared by %s", fp, strings.Join(hosts, ", "))
+	}
+	if sshStore != nil {
+		if err := sshStore.Save(); err != nil {
+			log.Println("Saving SSH host keys failed:", err)
+		}
+	}
+	if err := snmpSweeper.Save(); err != nil {
+		log.Println("Saving SNMP credential cache failed:", err)
 	}
 
-	// Display final table
+	// Display final table. An export to stdout must stay parseable, so the
+	// tables go to stderr then.
+	if *exportFormat != "" && *exportPath == "" {
+		sentinel.Output = os.Stderr
+	}
 	sentinel.DisplayTable(allDevices)
+	sentinel.DisplayInterfaces(allDevices)
+	sentinel.DisplayStorage(allDevices)
+
+	// Topology from neighbor advertisements
+	topology := sentinel.NewTopology(sentinel.DevicesFromRecords(allDevices))
+	topology.AddNeighbors(neighbors)
+	sentinel.DisplayLinks(topology.Links())
+
+	if *exportFormat != "" {
+		if err := exportTopology(topology.Network(subnet), *exportFormat, *exportPath); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+	}
 }
 
-// Helper for converting string metrics to int64
-func parseInt64(val string) int64 {
-	var i int64
-	fmt.Sscan(val, &i)
-	return i
+// runPassive builds the device table from observed traffic only.
+func runPassive(interfaceName, pcapFile string, duration time.Duration) {
+	devices, err := probe.PassiveDiscover(probe.PassiveConfig{
+		Interface: interfaceName,
+		PcapFile:  pcapFile,
+		Duration:  duration,
+	})
+	if err != nil {
+		log.Fatalf("Passive discovery failed: %v", err)
+	}
+
+	records := []sentinel.DeviceRecord{}
+	for _, d := range devices {
+		records = append(records, sentinel.DeviceRecord{
+			DeviceID:  d.GetID(),
+			Source:    sentinel.SourcePassive,
+			Hostname:  d.GetHostname(),
+			IP:        d.GetIPAddress(),
+			MAC:       d.GetMACAddress(),
+			Status:    d.GetStatus(),
+			Type:      d.GetDeviceType(),
+			Vendor:    probe.LookupVendor(d.GetMACAddress()),
+			Protocols: strings.Join(d.GetMonitoringProtocols(), ","),
+			LastSeen:  d.GetLastSeen(),
+		})
+	}
+	sentinel.DisplayTable(sentinel.MergeRecords(records))
 }
 
+// exportTopology writes the network graph to path, or stdout when path is empty.
+func exportTopology(network *models.Network, format, path string) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := exporter.Write(out, format, network); err != nil {
+		return err
+	}
+	if path != "" {
+		log.Printf("[Main] Wrote %s topology to %s\n", format, path)
+	}
+	return nil
+}
 
-// scan common ports quickly
-func scanCommonPorts(ip string, timeout time.Duration) []int {
-    ports := []int{22, 80, 135, 139, 445, 443, 3389}
-    openPorts := []int{}
-    for _, port := range ports {
-        addr := fmt.Sprintf("%s:%d", ip, port)
-        conn, err := net.DialTimeout("tcp", addr, timeout)
-        if err == nil {
-            openPorts = append(openPorts, port)
-            conn.Close()
-        }
-    }
-    return openPorts
+// sampleCounters polls sysUpTime and the interface table of a device.
+func sampleCounters(config probe.SNMPConfig) (sentinel.CounterSample, error) {
+	sample := sentinel.CounterSample{Time: time.Now()}
+	if res, err := probe.FetchMetrics(config, []string{".1.3.6.1.2.1.1.3.0"}); err == nil {
+		ticks, _ := strconv.ParseUint(res.Metrics.Values[".1.3.6.1.2.1.1.3.0"], 10, 32)
+		sample.SysUpTime = uint32(ticks)
+	}
+	ifaces, err := probe.FetchInterfaces(config)
+	if err != nil {
+		return sample, err
+	}
+	sample.Interfaces = ifaces
+	return sample, nil
 }
 
+// orNone returns s, or "none" when it is empty, for log lines.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
 
-func guessOS(openPorts []int) string {
-    portSet := map[int]bool{}
-    for _, p := range openPorts {
-        portSet[p] = true
-    }
-    switch {
-    case portSet[135] || portSet[139] || portSet[445] || portSet[3389]:
-        return "Windows (likely)"
-    case portSet[22]:
-        return "Linux/Unix (likely)"
-    default:
-        return "Unknown"
-    }
+// portProfileNames lists the named port sets for the -ports help text.
+func portProfileNames() string {
+	names := make([]string, 0, len(probe.PortProfiles))
+	for name := range probe.PortProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
 }
+
@@ -0,0 +1,123 @@
+// Command oui-update rebuilds the embedded MAC vendor registry from the IEEE
+// CSV files (oui.csv, mam.csv and oui36.csv from standards-oui.ieee.org):
+//
+//	go run ./cmd/oui-update oui.csv mam.csv oui36.csv
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sofc-t/sentinel/probe"
+)
+
+type assignment struct {
+	registry     string
+	prefix       string
+	organization string
+}
+
+func main() {
+	out := flag.String("out", "probe/oui.csv.gz", "registry file to write")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: oui-update [-out file] oui.csv [mam.csv oui36.csv ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	byPrefix := make(map[string]assignment)
+	for _, path := range flag.Args() {
+		rows, err := readIEEECSV(path)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		for _, a := range rows {
+			byPrefix[a.prefix] = a
+		}
+		log.Printf("%s: %d assignments", path, len(rows))
+	}
+
+	rows := make([]assignment, 0, len(byPrefix))
+	for _, a := range byPrefix {
+		rows = append(rows, a)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].prefix < rows[j].prefix })
+
+	// Without mam.csv and oui36.csv, addresses in MA-M and MA-S blocks
+	// resolve to their MA-L entry, "IEEE Registration Authority".
+	perRegistry := make(map[string]int)
+	for _, a := range rows {
+		perRegistry[a.registry]++
+	}
+	for _, registry := range []string{"MA-L", "MA-M", "MA-S"} {
+		if perRegistry[registry] == 0 {
+			log.Printf("WARNING: no %s assignments; pass oui.csv, mam.csv and oui36.csv", registry)
+		}
+	}
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w := csv.NewWriter(zw)
+	w.Write([]string{"Registry", "Assignment", "Organization Name"})
+	for _, a := range rows {
+		w.Write([]string{a.registry, a.prefix, a.organization})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Make sure the result loads before replacing the old registry.
+	zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	reg := probe.NewOUIRegistry()
+	if err := reg.ReadCSV(zr); err != nil {
+		log.Fatalf("generated registry does not load: %v", err)
+	}
+
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %d assignments (MA-L %d, MA-M %d, MA-S %d) to %s",
+		reg.Len(), perRegistry["MA-L"], perRegistry["MA-M"], perRegistry["MA-S"], *out)
+}
+
+// readIEEECSV reads the Registry, Assignment and Organization Name columns of
+// an IEEE registry CSV.
+func readIEEECSV(path string) ([]assignment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []assignment
+	err = probe.ReadOUICSV(f, func(prefix, registry, organization string) error {
+		a := assignment{
+			registry:     strings.TrimSpace(registry),
+			prefix:       strings.ToUpper(strings.TrimSpace(prefix)),
+			organization: strings.Join(strings.Fields(organization), " "),
+		}
+		if a.prefix != "" {
+			rows = append(rows, a)
+		}
+		return nil
+	})
+	return rows, err
+}
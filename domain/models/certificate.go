@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Certificate problems reported on a TLSService.
+const (
+	CertExpired          = "expired"
+	CertExpiring         = "expiring"
+	CertNotYetValid      = "not-yet-valid"
+	CertSelfSigned       = "self-signed"
+	CertWeakKey          = "weak-key"
+	CertHostnameMismatch = "hostname-mismatch"
+)
+
+// Certificate is one X.509 certificate of a presented chain
+type Certificate struct {
+	Subject            string    `json:"subject"`
+	SANs               []string  `json:"sans,omitempty"`
+	Issuer             string    `json:"issuer"`
+	Serial             string    `json:"serial"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyType            string    `json:"key_type"` // RSA, ECDSA, Ed25519, DSA
+	KeyBits            int       `json:"key_bits"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SHA256             string    `json:"sha256"` // fingerprint of the DER encoding
+}
+
+// TLSService is the TLS configuration and certificate chain of one port
+type TLSService struct {
+	Port         int           `json:"port"`
+	Versions     []string      `json:"versions"`      // protocol versions the server accepts
+	CipherSuites []string      `json:"cipher_suites"` // accepted suites, in server preference order per version
+	Chain        []Certificate `json:"chain"`         // leaf first
+	Issues       []string      `json:"issues,omitempty"`
+	CheckedAt    time.Time     `json:"checked_at"`
+}
+
+// Leaf returns the server certificate, or nil when none was presented
+func (s *TLSService) Leaf() *Certificate {
+	if len(s.Chain) == 0 {
+		return nil
+	}
+	return &s.Chain[0]
+}
+
+// HasIssue reports whether the given problem was flagged
+func (s *TLSService) HasIssue(issue string) bool {
+	for _, i := range s.Issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary renders the service on one line, e.g.
+// "443 CN=nas.local exp 2025-03-01 TLS 1.2,TLS 1.3 [self-signed]".
+func (s *TLSService) Summary() string {
+	parts := []string{fmt.Sprint(s.Port)}
+	if leaf := s.Leaf(); leaf != nil {
+		parts = append(parts, leaf.Subject, "exp "+leaf.NotAfter.Format("2006-01-02"))
+	}
+	if len(s.Versions) > 0 {
+		parts = append(parts, strings.Join(s.Versions, ","))
+	}
+	if len(s.Issues) > 0 {
+		parts = append(parts, "["+strings.Join(s.Issues, " ")+"]")
+	}
+	return strings.Join(parts, " ")
+}
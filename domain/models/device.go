@@ -1,16 +1,31 @@
 package models
 
+import (
+	"net/netip"
+	"time"
+)
+
 // Device represents a network device such as a router, switch, or firewall
 type Device struct {
 	id                 string     
 	hostname           string     
 	ipAddress          string     
+	ipAddresses        []string
 	deviceType         string     
 	vendor             string     
 	status             string     
 	monitoringProtocols []string  
 	interfaces         []Interface
 	macAddress        string 
+	chassisID          string
+	description        string
+	model              string
+	serialNumber       string
+	tlsServices        []TLSService
+	openPorts          []int
+	openUDPPorts       []int
+	firstSeen          time.Time
+	lastSeen           time.Time
 }
 
 
@@ -18,26 +33,51 @@ type Device struct {
 type DeviceConfig struct {
 	Hostname           string
 	IPAddress          string
+	IPAddresses        []string // all IPv4/IPv6 addresses; IPAddress is added if missing
 	DeviceType         string
 	Vendor             string
 	Status             string
 	MonitoringProtocols []string
 	Interfaces         []Interface
 	MACAddress        string
+	ChassisID          string
+	Description        string
+	Model              string
+	SerialNumber       string
+	TLSServices        []TLSService
+	OpenPorts          []int // open TCP ports
+	OpenUDPPorts       []int
+	FirstSeen          time.Time
+	LastSeen           time.Time
 }
 
 // NewDeviceConfig creates a new DeviceConfig instance
 func NewDevice(device DeviceConfig) *Device {
-	return &Device{
+	d := &Device{
 		hostname:           device.Hostname,
-		ipAddress:          device.IPAddress,
 		deviceType:         device.DeviceType,
 		vendor:             device.Vendor,
 		status:             device.Status,
 		monitoringProtocols: device.MonitoringProtocols,
 		interfaces:         device.Interfaces,
 		macAddress:        device.MACAddress,
+		chassisID:          device.ChassisID,
+		description:        device.Description,
+		model:              device.Model,
+		serialNumber:       device.SerialNumber,
+		tlsServices:        device.TLSServices,
+		openPorts:          device.OpenPorts,
+		openUDPPorts:       device.OpenUDPPorts,
+		firstSeen:          device.FirstSeen,
+		lastSeen:           device.LastSeen,
+	}
+	if device.IPAddress != "" {
+		d.AddIPAddress(device.IPAddress)
 	}
+	for _, ip := range device.IPAddresses {
+		d.AddIPAddress(ip)
+	}
+	return d
 }
 
 
@@ -61,16 +101,82 @@ func (d *Device) GetHostname() string {
 	return d.hostname
 }
 
-// SetIPAddress sets the IP address of the device
+// SetIPAddress sets the primary IP address of the device
 func (d *Device) SetIPAddress(ipAddress string) {
 	d.ipAddress = ipAddress
+	if ipAddress != "" && !d.HasIPAddress(ipAddress) {
+		d.ipAddresses = append(d.ipAddresses, ipAddress)
+	}
 }
 
-// GetIPAddress gets the IP address of the device
+// GetIPAddress gets the primary IP address of the device
 func (d *Device) GetIPAddress() string {
 	return d.ipAddress
 }
 
+// AddIPAddress adds an address to the device. The first IPv4 address seen
+// becomes the primary address, falling back to the first IPv6 address.
+func (d *Device) AddIPAddress(ipAddress string) {
+	if ipAddress == "" || d.HasIPAddress(ipAddress) {
+		return
+	}
+	d.ipAddresses = append(d.ipAddresses, ipAddress)
+	if d.ipAddress == "" || (!isIPv4(d.ipAddress) && isIPv4(ipAddress)) {
+		d.ipAddress = ipAddress
+	}
+}
+
+// HasIPAddress reports whether the device carries the given address
+func (d *Device) HasIPAddress(ipAddress string) bool {
+	for _, ip := range d.ipAddresses {
+		if ip == ipAddress {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIPAddresses replaces all addresses of the device
+func (d *Device) SetIPAddresses(ipAddresses []string) {
+	d.ipAddress = ""
+	d.ipAddresses = nil
+	for _, ip := range ipAddresses {
+		d.AddIPAddress(ip)
+	}
+}
+
+// GetIPAddresses gets all IPv4 and IPv6 addresses of the device
+func (d *Device) GetIPAddresses() []string {
+	return d.ipAddresses
+}
+
+// GetIPv4Addresses gets the IPv4 addresses of the device
+func (d *Device) GetIPv4Addresses() []string {
+	var out []string
+	for _, ip := range d.ipAddresses {
+		if isIPv4(ip) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+// GetIPv6Addresses gets the IPv6 addresses of the device
+func (d *Device) GetIPv6Addresses() []string {
+	var out []string
+	for _, ip := range d.ipAddresses {
+		if !isIPv4(ip) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+func isIPv4(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && addr.Unmap().Is4()
+}
+
 // SetDeviceType sets the device type
 func (d *Device) SetDeviceType(deviceType string) {
 	d.deviceType = deviceType
@@ -121,6 +227,102 @@ func (d *Device) GetInterfaces() []Interface {
 	return d.interfaces
 }
 
+// SetMACAddress sets the MAC address of the device
+func (d *Device) SetMACAddress(macAddress string) {
+	d.macAddress = macAddress
+}
+
+// GetMACAddress gets the MAC address of the device
 func (d *Device) GetMACAddress() string {
 	return d.macAddress
-}
\ No newline at end of file
+}
+
+// SetChassisID sets the LLDP chassis ID of the device
+func (d *Device) SetChassisID(chassisID string) {
+	d.chassisID = chassisID
+}
+
+// GetChassisID gets the LLDP chassis ID of the device
+func (d *Device) GetChassisID() string {
+	return d.chassisID
+}
+
+// SetDescription sets the system description of the device
+func (d *Device) SetDescription(description string) {
+	d.description = description
+}
+
+// GetDescription gets the system description of the device
+func (d *Device) GetDescription() string {
+	return d.description
+}
+
+// SetModel sets the hardware model of the device
+func (d *Device) SetModel(model string) {
+	d.model = model
+}
+
+// GetModel gets the hardware model of the device
+func (d *Device) GetModel() string {
+	return d.model
+}
+
+// SetSerialNumber sets the serial number of the device
+func (d *Device) SetSerialNumber(serialNumber string) {
+	d.serialNumber = serialNumber
+}
+
+// GetSerialNumber gets the serial number of the device
+func (d *Device) GetSerialNumber() string {
+	return d.serialNumber
+}
+
+// SetTLSServices sets the TLS services and certificates found on the device
+func (d *Device) SetTLSServices(services []TLSService) {
+	d.tlsServices = services
+}
+
+// GetTLSServices gets the TLS services and certificates found on the device
+func (d *Device) GetTLSServices() []TLSService {
+	return d.tlsServices
+}
+
+// SetOpenPorts sets the open TCP ports of the device
+func (d *Device) SetOpenPorts(ports []int) {
+	d.openPorts = ports
+}
+
+// GetOpenPorts gets the open TCP ports of the device
+func (d *Device) GetOpenPorts() []int {
+	return d.openPorts
+}
+
+// SetOpenUDPPorts sets the open UDP ports of the device
+func (d *Device) SetOpenUDPPorts(ports []int) {
+	d.openUDPPorts = ports
+}
+
+// GetOpenUDPPorts gets the open UDP ports of the device
+func (d *Device) GetOpenUDPPorts() []int {
+	return d.openUDPPorts
+}
+
+// SetFirstSeen sets when the device was first observed
+func (d *Device) SetFirstSeen(t time.Time) {
+	d.firstSeen = t
+}
+
+// GetFirstSeen gets when the device was first observed
+func (d *Device) GetFirstSeen() time.Time {
+	return d.firstSeen
+}
+
+// SetLastSeen sets when the device was last observed
+func (d *Device) SetLastSeen(t time.Time) {
+	d.lastSeen = t
+}
+
+// GetLastSeen gets when the device was last observed
+func (d *Device) GetLastSeen() time.Time {
+	return d.lastSeen
+}
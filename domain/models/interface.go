@@ -3,11 +3,22 @@ package models
 // Interface represents a network interface on a device
 type Interface struct {
 	id                 string // ID of the interface
+	ifIndex            int    // SNMP ifIndex
+	name               string // Port name, e.g. Gi1/0/1
+	alias              string // Administrator-assigned label (ifAlias)
+	description        string // Port description
+	ifType             int    // IANAifType, e.g. 6 for ethernetCsmacd
+	mtu                int    // Largest frame in octets
+	speedBps           uint64 // Bandwidth in bits per second
+	adminStatus        string // Configured state: up, down, testing
+	counters           InterfaceCounters
 	deviceID           string // ID of the device
 	macAddress         string // MAC address of the interface
 	ipAddress          string // Optional IP Address
 	status             string // Up, Down
 	speed              string // e.g., 1Gbps, 10Gbps
+	mauType            string // IEEE 802.3 MAU type, e.g. 1000BaseT_FD
+	vlanID             int    // Port VLAN ID
 	connectedDevice    string // ID of the connected device
 	connectedInterface string // ID of the connected interface
 }
@@ -22,6 +33,85 @@ func (i *Interface) GetID() string {
 	return i.id
 }
 
+// InterfaceCounters holds the cumulative traffic counters of an interface.
+type InterfaceCounters struct {
+	InOctets         uint64 `json:"in_octets"`
+	OutOctets        uint64 `json:"out_octets"`
+	InUcastPkts      uint64 `json:"in_ucast_pkts"`
+	OutUcastPkts     uint64 `json:"out_ucast_pkts"`
+	InMulticastPkts  uint64 `json:"in_multicast_pkts"`
+	OutMulticastPkts uint64 `json:"out_multicast_pkts"`
+	InBroadcastPkts  uint64 `json:"in_broadcast_pkts"`
+	OutBroadcastPkts uint64 `json:"out_broadcast_pkts"`
+	InErrors         uint64 `json:"in_errors"`
+	OutErrors        uint64 `json:"out_errors"`
+	InDiscards       uint64 `json:"in_discards"`
+	OutDiscards      uint64 `json:"out_discards"`
+	// HighCapacity is set when the octet and packet counters are the 64-bit
+	// ifXTable ones; otherwise they are 32-bit and wrap at 2^32.
+	HighCapacity bool `json:"high_capacity"`
+}
+
+// SetIfIndex sets the SNMP ifIndex of the interface
+func (i *Interface) SetIfIndex(ifIndex int) {
+	i.ifIndex = ifIndex
+}
+
+// GetIfIndex gets the SNMP ifIndex of the interface
+func (i *Interface) GetIfIndex() int {
+	return i.ifIndex
+}
+
+// SetName sets the Name of the interface
+func (i *Interface) SetName(name string) {
+	i.name = name
+}
+
+// GetName gets the Name of the interface
+func (i *Interface) GetName() string {
+	return i.name
+}
+
+// SetAlias sets the Alias of the interface
+func (i *Interface) SetAlias(alias string) {
+	i.alias = alias
+}
+
+// GetAlias gets the Alias of the interface
+func (i *Interface) GetAlias() string {
+	return i.alias
+}
+
+// SetDescription sets the Description of the interface
+func (i *Interface) SetDescription(description string) {
+	i.description = description
+}
+
+// GetDescription gets the Description of the interface
+func (i *Interface) GetDescription() string {
+	return i.description
+}
+
+// SetIfType sets the IANAifType of the interface
+func (i *Interface) SetIfType(ifType int) {
+	i.ifType = ifType
+}
+
+// GetIfType gets the IANAifType of the interface
+func (i *Interface) GetIfType() int {
+	return i.ifType
+}
+
+// SetMTU sets the MTU of the interface
+func (i *Interface) SetMTU(mtu int) {
+	i.mtu = mtu
+}
+
+// GetMTU gets the MTU of the interface
+func (i *Interface) GetMTU() int {
+	return i.mtu
+}
+
 // SetDeviceID sets the DeviceID of the interface
 func (i *Interface) SetDeviceID(deviceID string) {
 	i.deviceID = deviceID
@@ -62,6 +152,16 @@ func (i *Interface) GetStatus() string {
 	return i.status
 }
 
+// SetAdminStatus sets the AdminStatus of the interface
+func (i *Interface) SetAdminStatus(adminStatus string) {
+	i.adminStatus = adminStatus
+}
+
+// GetAdminStatus gets the AdminStatus of the interface
+func (i *Interface) GetAdminStatus() string {
+	return i.adminStatus
+}
+
 // SetSpeed sets the Speed of the interface
 func (i *Interface) SetSpeed(speed string) {
 	i.speed = speed
@@ -72,6 +172,46 @@ func (i *Interface) GetSpeed() string {
 	return i.speed
 }
 
+// SetSpeedBps sets the bandwidth of the interface in bits per second
+func (i *Interface) SetSpeedBps(speedBps uint64) {
+	i.speedBps = speedBps
+}
+
+// GetSpeedBps gets the bandwidth of the interface in bits per second
+func (i *Interface) GetSpeedBps() uint64 {
+	return i.speedBps
+}
+
+// SetCounters sets the traffic counters of the interface
+func (i *Interface) SetCounters(counters InterfaceCounters) {
+	i.counters = counters
+}
+
+// GetCounters gets the traffic counters of the interface
+func (i *Interface) GetCounters() InterfaceCounters {
+	return i.counters
+}
+
+// SetMAUType sets the MAUType of the interface
+func (i *Interface) SetMAUType(mauType string) {
+	i.mauType = mauType
+}
+
+// GetMAUType gets the MAUType of the interface
+func (i *Interface) GetMAUType() string {
+	return i.mauType
+}
+
+// SetVLANID sets the VLANID of the interface
+func (i *Interface) SetVLANID(vlanID int) {
+	i.vlanID = vlanID
+}
+
+// GetVLANID gets the VLANID of the interface
+func (i *Interface) GetVLANID() int {
+	return i.vlanID
+}
+
 // SetConnectedDevice sets the ConnectedDevice of the interface
 func (i *Interface) SetConnectedDevice(connectedDevice string) {
 	i.connectedDevice = connectedDevice
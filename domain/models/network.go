@@ -8,6 +8,7 @@ type Network struct {
 	ipRanges    []string
 	subnetMask  string
 	devices     []Device
+	links       []Link
 }
 
 // GetID returns the ID of the network
@@ -70,3 +71,12 @@ func (n *Network) SetDevices(devices []Device) {
 	n.devices = devices
 }
 
+// GetLinks returns the links between devices in the network
+func (n *Network) GetLinks() []Link {
+	return n.links
+}
+
+// SetLinks sets the links between devices in the network
+func (n *Network) SetLinks(links []Link) {
+	n.links = links
+}
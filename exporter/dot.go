@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeDOT renders the graph as an undirected Graphviz graph.
+func writeDOT(w io.Writer, g graph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "graph %s {\n", dotQuote(g.Name))
+	fmt.Fprintln(bw, "  node [shape=box];")
+	for _, n := range g.Nodes {
+		label := n.Label
+		if n.IP != "" && n.IP != n.Label {
+			label += "\n" + n.IP
+		}
+		fmt.Fprintf(bw, "  %s [%s];\n", dotQuote(n.ID), dotAttrs(
+			"label", label,
+			"ip", n.IP,
+			"mac", n.MAC,
+			"vendor", n.Vendor,
+			"type", n.Type,
+			"status", n.Status,
+			"tls", tlsSummary(n),
+			"cert_issues", certIssues(n),
+			"interfaces", interfaceSummary(n),
+		))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -- %s [%s];\n", dotQuote(e.Source), dotQuote(e.Target), dotAttrs(
+			"label", edgeLabel(e),
+			"taillabel", e.SourcePort,
+			"headlabel", e.TargetPort,
+			"status", e.Status,
+		))
+	}
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+// dotAttrs renders key/value pairs as a DOT attribute list, skipping empty values.
+func dotAttrs(kv ...string) string {
+	var attrs []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			continue
+		}
+		attrs = append(attrs, kv[i]+"="+dotQuote(kv[i+1]))
+	}
+	return strings.Join(attrs, ", ")
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,188 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// Supported export formats.
+const (
+	FormatDOT     = "dot"
+	FormatGraphML = "graphml"
+	FormatJSON    = "json"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{FormatDOT, FormatGraphML, FormatJSON}
+
+// node is a device rendered as a graph vertex.
+type node struct {
+	ID     string
+	Label  string
+	IP     string
+	MAC    string
+	Vendor string
+	Type   string
+	Status string
+	TLS    []models.TLSService
+	Ifaces []models.Interface
+	TCP    []int // open ports
+	UDP    []int
+}
+
+// edge is a link rendered as a graph edge.
+type edge struct {
+	ID         string
+	Source     string
+	Target     string
+	SourcePort string
+	TargetPort string
+	Status     string
+}
+
+// graph is the format-independent view of a network shared by all writers.
+type graph struct {
+	Name  string
+	Nodes []node
+	Edges []edge
+}
+
+// Write renders the network in the given format.
+func Write(w io.Writer, format string, network *models.Network) error {
+	g := newGraph(network)
+	switch strings.ToLower(format) {
+	case FormatDOT:
+		return writeDOT(w, g)
+	case FormatGraphML:
+		return writeGraphML(w, g)
+	case FormatJSON:
+		return writeJSON(w, g)
+	default:
+		return fmt.Errorf("unknown export format %q (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// newGraph converts a network into nodes and edges. Devices referenced by a
+// link but missing from the network get a bare node so every edge resolves.
+func newGraph(network *models.Network) graph {
+	g := graph{Name: network.GetName()}
+	if g.Name == "" {
+		g.Name = "network"
+	}
+
+	known := make(map[string]bool)
+	for _, d := range network.GetDevices() {
+		id := deviceID(d)
+		if known[id] {
+			continue
+		}
+		known[id] = true
+
+		label := d.GetHostname()
+		if label == "" {
+			label = d.GetIPAddress()
+		}
+		if label == "" {
+			label = id
+		}
+		g.Nodes = append(g.Nodes, node{
+			ID:     id,
+			Label:  label,
+			IP:     d.GetIPAddress(),
+			MAC:    d.GetMACAddress(),
+			Vendor: d.GetVendor(),
+			Type:   d.GetDeviceType(),
+			Status: d.GetStatus(),
+			TLS:    d.GetTLSServices(),
+			Ifaces: d.GetInterfaces(),
+			TCP:    d.GetOpenPorts(),
+			UDP:    d.GetOpenUDPPorts(),
+		})
+	}
+
+	for _, l := range network.GetLinks() {
+		for _, id := range []string{l.GetSourceDevice(), l.GetDestinationDevice()} {
+			if !known[id] {
+				known[id] = true
+				g.Nodes = append(g.Nodes, node{ID: id, Label: id})
+			}
+		}
+		g.Edges = append(g.Edges, edge{
+			ID:         l.GetID(),
+			Source:     l.GetSourceDevice(),
+			Target:     l.GetDestinationDevice(),
+			SourcePort: l.GetSourceInterface(),
+			TargetPort: l.GetDestinationInterface(),
+			Status:     l.GetStatus(),
+		})
+	}
+	return g
+}
+
+// deviceID falls back to the IP or MAC for devices without an ID.
+func deviceID(d models.Device) string {
+	switch {
+	case d.GetID() != "":
+		return d.GetID()
+	case d.GetIPAddress() != "":
+		return d.GetIPAddress()
+	default:
+		return d.GetMACAddress()
+	}
+}
+
+// tlsSummary joins the one-line summaries of a node's TLS ports for formats
+// that only carry flat attributes.
+func tlsSummary(n node) string {
+	lines := make([]string, 0, len(n.TLS))
+	for i := range n.TLS {
+		lines = append(lines, n.TLS[i].Summary())
+	}
+	return strings.Join(lines, "; ")
+}
+
+// certIssues lists the distinct certificate problems across a node's TLS
+// ports, e.g. "expired,self-signed".
+func certIssues(n node) string {
+	var issues []string
+	seen := make(map[string]bool)
+	for _, svc := range n.TLS {
+		for _, issue := range svc.Issues {
+			if !seen[issue] {
+				seen[issue] = true
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return strings.Join(issues, ",")
+}
+
+// interfaceSummary counts a node's interfaces and how many are up, e.g.
+// "48 interfaces, 12 up".
+func interfaceSummary(n node) string {
+	if len(n.Ifaces) == 0 {
+		return ""
+	}
+	up := 0
+	for _, iface := range n.Ifaces {
+		if iface.GetStatus() == "up" {
+			up++
+		}
+	}
+	return fmt.Sprintf("%d interfaces, %d up", len(n.Ifaces), up)
+}
+
+// edgeLabel joins the known port names of a link, e.g. "Gi1/0/1 - Gi0/2",
+// or just "Gi1/0/1" when the other side's port is unknown.
+func edgeLabel(e edge) string {
+	var ports []string
+	for _, p := range []string{e.SourcePort, e.TargetPort} {
+		if p != "" {
+			ports = append(ports, p)
+		}
+	}
+	return strings.Join(ports, " - ")
+}
@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// testNetwork is a small synthetic network: a switch with two ports, a NAS
+// with a TLS service and a link to a device that is only known by its ID.
+func testNetwork() *models.Network {
+	port := func(ifIndex int, name, status, peer, peerPort string) models.Interface {
+		var iface models.Interface
+		iface.SetIfIndex(ifIndex)
+		iface.SetID(name)
+		iface.SetName(name)
+		iface.SetDeviceID("sw1")
+		iface.SetIfType(6)
+		iface.SetMTU(1500)
+		iface.SetSpeedBps(1000000000)
+		iface.SetAdminStatus("up")
+		iface.SetStatus(status)
+		iface.SetConnectedDevice(peer)
+		iface.SetConnectedInterface(peerPort)
+		iface.SetCounters(models.InterfaceCounters{InOctets: 1000 * uint64(ifIndex), OutOctets: 2000, HighCapacity: true})
+		return iface
+	}
+	sw := models.NewDevice(models.DeviceConfig{
+		Hostname:   "sw1",
+		IPAddress:  "192.0.2.1",
+		DeviceType: "switch",
+		Vendor:     "Cisco Systems, Inc",
+		Status:     "active",
+		MACAddress: "00:1b:54:00:00:01",
+		Interfaces: []models.Interface{
+			port(1, "Gi1/0/1", "up", "nas", "eth0"),
+			port(2, "Gi1/0/2", "down", "", ""),
+		},
+	})
+	sw.SetID("sw1")
+
+	notAfter := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	nas := models.NewDevice(models.DeviceConfig{
+		Hostname:     "nas",
+		IPAddress:    "192.0.2.20",
+		DeviceType:   "storage",
+		Vendor:       `Synology "DS" <Inc>`,
+		Status:       "active",
+		MACAddress:   "00:11:32:00:00:02",
+		OpenPorts:    []int{22, 443, 5000},
+		OpenUDPPorts: []int{161, 1900},
+		TLSServices: []models.TLSService{{
+			Port:     443,
+			Versions: []string{"TLS 1.2", "TLS 1.3"},
+			Chain:    []models.Certificate{{Subject: "CN=nas.local", Issuer: "CN=nas.local", NotAfter: notAfter}},
+			Issues:   []string{models.CertSelfSigned, models.CertExpired},
+		}},
+	})
+	nas.SetID("nas")
+
+	link := func(src, srcPort, dst, dstPort, status string) models.Link {
+		var l models.Link
+		l.SetID(src + ":" + srcPort + "<->" + dst + ":" + dstPort)
+		l.SetSourceDevice(src)
+		l.SetSourceInterface(srcPort)
+		l.SetDestinationDevice(dst)
+		l.SetDestinationInterface(dstPort)
+		l.SetStatus(status)
+		return l
+	}
+
+	network := &models.Network{}
+	network.SetID("lab")
+	network.SetName("lab")
+	network.SetDevices([]models.Device{*sw, *nas})
+	network.SetLinks([]models.Link{
+		link("sw1", "Gi1/0/1", "nas", "eth0", "up"),
+		link("sw1", "Gi1/0/3", "ap-7", "", "unknown"),
+	})
+	return network
+}
+
+func TestWriteGolden(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, testNetwork()); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "network."+format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s output differs from %s:\n%s", format, golden, buf.String())
+			}
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "svg", testNetwork()); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestEdgeLabel(t *testing.T) {
+	tests := []struct {
+		source, target string
+		want           string
+	}{
+		{"Gi1/0/1", "eth0", "Gi1/0/1 - eth0"},
+		{"Gi1/0/1", "", "Gi1/0/1"},
+		{"", "eth0", "eth0"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := edgeLabel(edge{SourcePort: tt.source, TargetPort: tt.target}); got != tt.want {
+			t.Errorf("edgeLabel(%q, %q) = %q, want %q", tt.source, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr,omitempty"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphMLKeys = []graphMLKey{
+	{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+	{ID: "ip", For: "node", AttrName: "ip", AttrType: "string"},
+	{ID: "mac", For: "node", AttrName: "mac", AttrType: "string"},
+	{ID: "vendor", For: "node", AttrName: "vendor", AttrType: "string"},
+	{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+	{ID: "status", For: "node", AttrName: "status", AttrType: "string"},
+	{ID: "tls", For: "node", AttrName: "tls", AttrType: "string"},
+	{ID: "cert_issues", For: "node", AttrName: "cert_issues", AttrType: "string"},
+	{ID: "interfaces", For: "node", AttrName: "interfaces", AttrType: "string"},
+	{ID: "source_port", For: "edge", AttrName: "source_port", AttrType: "string"},
+	{ID: "target_port", For: "edge", AttrName: "target_port", AttrType: "string"},
+	{ID: "link_status", For: "edge", AttrName: "status", AttrType: "string"},
+}
+
+// writeGraphML renders the graph as an undirected GraphML document.
+func writeGraphML(w io.Writer, g graph) error {
+	doc := graphMLDocument{
+		XMLNS: graphMLNamespace,
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: g.Name, EdgeDefault: "undirected"},
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.ID,
+			Data: graphMLValues(
+				"label", n.Label,
+				"ip", n.IP,
+				"mac", n.MAC,
+				"vendor", n.Vendor,
+				"type", n.Type,
+				"status", n.Status,
+				"tls", tlsSummary(n),
+				"cert_issues", certIssues(n),
+				"interfaces", interfaceSummary(n),
+			),
+		})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     e.ID,
+			Source: e.Source,
+			Target: e.Target,
+			Data: graphMLValues(
+				"source_port", e.SourcePort,
+				"target_port", e.TargetPort,
+				"link_status", e.Status,
+			),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// graphMLValues builds data elements from key/value pairs, skipping empty values.
+func graphMLValues(kv ...string) []graphMLData {
+	var data []graphMLData
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			continue
+		}
+		data = append(data, graphMLData{Key: kv[i], Value: kv[i+1]})
+	}
+	return data
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// jsonGraph follows the node/link layout used by D3 force-directed graphs.
+type jsonGraph struct {
+	Name  string     `json:"name"`
+	Nodes []jsonNode `json:"nodes"`
+	Links []jsonLink `json:"links"`
+}
+
+type jsonNode struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	IP     string `json:"ip,omitempty"`
+	MAC    string `json:"mac,omitempty"`
+	Vendor string `json:"vendor,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Status string `json:"status,omitempty"`
+
+	TCPPorts   []int               `json:"tcp_ports,omitempty"`
+	UDPPorts   []int               `json:"udp_ports,omitempty"`
+	TLS        []models.TLSService `json:"tls,omitempty"`
+	Interfaces []jsonInterface     `json:"interfaces,omitempty"`
+}
+
+type jsonInterface struct {
+	IfIndex     int                      `json:"if_index"`
+	Name        string                   `json:"name"`
+	Alias       string                   `json:"alias,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Type        int                      `json:"type,omitempty"`
+	MTU         int                      `json:"mtu,omitempty"`
+	SpeedBps    uint64                   `json:"speed_bps,omitempty"`
+	MAC         string                   `json:"mac,omitempty"`
+	AdminStatus string                   `json:"admin_status,omitempty"`
+	OperStatus  string                   `json:"oper_status,omitempty"`
+	Neighbor    string                   `json:"neighbor,omitempty"`
+	Counters    models.InterfaceCounters `json:"counters"`
+}
+
+type jsonLink struct {
+	ID         string `json:"id,omitempty"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	SourcePort string `json:"source_port,omitempty"`
+	TargetPort string `json:"target_port,omitempty"`
+	Label      string `json:"label,omitempty"`
+	Status     string `json:"status,omitempty"`
+}
+
+// writeJSON renders the graph as D3-style node/link JSON.
+func writeJSON(w io.Writer, g graph) error {
+	out := jsonGraph{
+		Name:  g.Name,
+		Nodes: []jsonNode{},
+		Links: []jsonLink{},
+	}
+	for _, n := range g.Nodes {
+		jn := jsonNode{
+			ID:       n.ID,
+			Label:    n.Label,
+			IP:       n.IP,
+			MAC:      n.MAC,
+			Vendor:   n.Vendor,
+			Type:     n.Type,
+			Status:   n.Status,
+			TCPPorts: n.TCP,
+			UDPPorts: n.UDP,
+			TLS:      n.TLS,
+		}
+		for _, iface := range n.Ifaces {
+			jn.Interfaces = append(jn.Interfaces, jsonInterface{
+				IfIndex:     iface.GetIfIndex(),
+				Name:        iface.GetName(),
+				Alias:       iface.GetAlias(),
+				Description: iface.GetDescription(),
+				Type:        iface.GetIfType(),
+				MTU:         iface.GetMTU(),
+				SpeedBps:    iface.GetSpeedBps(),
+				MAC:         iface.GetMACAddress(),
+				AdminStatus: iface.GetAdminStatus(),
+				OperStatus:  iface.GetStatus(),
+				Neighbor:    iface.GetConnectedDevice(),
+				Counters:    iface.GetCounters(),
+			})
+		}
+		out.Nodes = append(out.Nodes, jn)
+	}
+	for _, e := range g.Edges {
+		out.Links = append(out.Links, jsonLink{
+			ID:         e.ID,
+			Source:     e.Source,
+			Target:     e.Target,
+			SourcePort: e.SourcePort,
+			TargetPort: e.TargetPort,
+			Label:      edgeLabel(e),
+			Status:     e.Status,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
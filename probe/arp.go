@@ -0,0 +1,235 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/arp"
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// DefaultARPMaxHosts is the largest sweep ARPScan runs unless AllowLarge is
+// set (a /20).
+const DefaultARPMaxHosts = 4096
+
+// ARPScanConfig holds settings for an active ARP sweep.
+type ARPScanConfig struct {
+	Interface  string
+	Prefixes   []netip.Prefix
+	Workers    int           // concurrent request senders (default 16)
+	Passes     int           // request rounds over unanswered addresses (default 2)
+	Timeout    time.Duration // wait for late replies after each pass (default 1s)
+	MaxHosts   int           // refuse sweeps larger than this (default DefaultARPMaxHosts)
+	AllowLarge bool          // disable the MaxHosts check
+}
+
+func (cfg *ARPScanConfig) setDefaults() {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 16
+	}
+	if cfg.Passes <= 0 {
+		cfg.Passes = 2
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.MaxHosts <= 0 {
+		cfg.MaxHosts = DefaultARPMaxHosts
+	}
+}
+
+// ARPScan sweeps the configured IPv4 prefixes with ARP requests and returns
+// every host that replied, including its MAC address.
+//
+// Requests are sent by a pool of workers while a single reader collects all
+// replies, so answers are never lost to a worker waiting on another address.
+// The scan ends once every pass has been sent and its reply window has
+// elapsed, or when ctx is cancelled, in which case the hosts found so far are
+// returned together with ctx.Err().
+func ARPScan(ctx context.Context, cfg ARPScanConfig) ([]models.Device, error) {
+	cfg.setDefaults()
+
+	targets, err := arpTargets(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	iface, err := net.InterfaceByName(cfg.Interface)
+	if err != nil {
+		return nil, fmt.Errorf("error finding interface %s: %v", cfg.Interface, err)
+	}
+
+	client, err := arp.Dial(iface)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ARP client: %v", err)
+	}
+	defer client.Close()
+
+	wanted := make(map[netip.Addr]bool, len(targets))
+	for _, ip := range targets {
+		wanted[ip] = true
+	}
+
+	var mu sync.Mutex
+	found := make(map[netip.Addr]net.HardwareAddr)
+
+	// Reader: collect replies until told to stop.
+	stop := make(chan struct{})
+	var readerWG sync.WaitGroup
+	readerWG.Add(1)
+	go func() {
+		defer readerWG.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			client.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			pkt, _, err := client.Read()
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					continue
+				}
+				select {
+				case <-stop:
+				default:
+					log.Printf("[ARP] Read error: %v", err)
+				}
+				return
+			}
+			if pkt.Operation != arp.OperationReply || !wanted[pkt.SenderIP] {
+				continue
+			}
+			mu.Lock()
+			if _, seen := found[pkt.SenderIP]; !seen {
+				found[pkt.SenderIP] = pkt.SenderHardwareAddr
+				log.Printf("[ARP] Found device: IP=%s, MAC=%s\n", pkt.SenderIP, pkt.SenderHardwareAddr)
+			}
+			mu.Unlock()
+		}
+	}()
+
+	pending := targets
+	for pass := 0; pass < cfg.Passes && len(pending) > 0 && ctx.Err() == nil; pass++ {
+		sendARPRequests(ctx, client, pending, cfg.Workers)
+
+		select {
+		case <-time.After(cfg.Timeout):
+		case <-ctx.Done():
+		}
+
+		mu.Lock()
+		var unanswered []netip.Addr
+		for _, ip := range pending {
+			if _, ok := found[ip]; !ok {
+				unanswered = append(unanswered, ip)
+			}
+		}
+		mu.Unlock()
+		pending = unanswered
+	}
+
+	close(stop)
+	client.SetReadDeadline(time.Now())
+	readerWG.Wait()
+
+	ips := make([]netip.Addr, 0, len(found))
+	for ip := range found {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i].Less(ips[j]) })
+
+	devices := make([]models.Device, 0, len(ips))
+	for _, ip := range ips {
+		device := models.NewDevice(models.DeviceConfig{
+			Hostname:            "",
+			IPAddress:           ip.String(),
+			DeviceType:          "unknown",
+			Vendor:              "",
+			Status:              "active",
+			MonitoringProtocols: []string{"ARP"},
+			Interfaces:          nil,
+			MACAddress:          found[ip].String(),
+		})
+		devices = append(devices, *device)
+	}
+
+	log.Printf("[ARP] Sweep finished. %d of %d address(es) answered.\n", len(devices), len(targets))
+	return devices, ctx.Err()
+}
+
+// sendARPRequests fans the addresses out to workers and returns once every
+// request has been written or ctx is cancelled.
+func sendARPRequests(ctx context.Context, client *arp.Client, ips []netip.Addr, workers int) {
+	ipChan := make(chan netip.Addr)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range ipChan {
+				if err := client.Request(ip); err != nil {
+					log.Printf("[ARP] Request to %s failed: %v", ip, err)
+				}
+			}
+		}()
+	}
+
+FEED:
+	for _, ip := range ips {
+		select {
+		case ipChan <- ip:
+		case <-ctx.Done():
+			break FEED
+		}
+	}
+	close(ipChan)
+	wg.Wait()
+}
+
+// arpTargets expands the configured prefixes into host addresses, skipping
+// network and broadcast addresses, and enforces the MaxHosts limit.
+func arpTargets(cfg ARPScanConfig) ([]netip.Addr, error) {
+	if len(cfg.Prefixes) == 0 {
+		return nil, fmt.Errorf("no prefixes to scan")
+	}
+
+	var total uint64
+	for _, p := range cfg.Prefixes {
+		if !p.Addr().Is4() {
+			return nil, fmt.Errorf("ARP only supports IPv4 prefixes, got %s", p)
+		}
+		total += uint64(1) << (32 - p.Bits())
+	}
+	if total > uint64(cfg.MaxHosts) && !cfg.AllowLarge {
+		return nil, fmt.Errorf("refusing to ARP scan %d addresses (limit %d); set AllowLarge to override", total, cfg.MaxHosts)
+	}
+
+	seen := make(map[netip.Addr]bool)
+	var targets []netip.Addr
+	for _, p := range cfg.Prefixes {
+		p = p.Masked()
+		first := p.Addr()
+		for ip := first; p.Contains(ip); ip = ip.Next() {
+			if p.Bits() < 31 && (ip == first || !p.Contains(ip.Next())) {
+				continue // network or broadcast address
+			}
+			if seen[ip] || ip.IsMulticast() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			seen[ip] = true
+			targets = append(targets, ip)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,322 @@
+package probe
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed banner_signatures.json
+var defaultBannerSignatures []byte
+
+// BannerProbe is a hello sent to elicit a banner. Probes without ports are
+// never chosen by port, and read_first probes wait for the server to speak
+// before sending.
+type BannerProbe struct {
+	Name      string `json:"name"`
+	Ports     []int  `json:"ports"`
+	ReadFirst bool   `json:"read_first"`
+	Send      string `json:"send"` // "{host}" is replaced with the target
+}
+
+// BannerSignature maps a banner pattern to a service identification. Product,
+// Version, Info and OS may reference pattern groups as "$1".
+type BannerSignature struct {
+	Service    string `json:"service"`
+	Pattern    string `json:"pattern"`
+	Product    string `json:"product"`
+	Version    string `json:"version"`
+	Info       string `json:"info"`
+	OS         string `json:"os"`
+	DeviceType string `json:"device_type"`
+
+	re *regexp.Regexp
+}
+
+// SignatureDB holds the hello probes and banner signatures. Signatures are
+// tried in order and the first match wins.
+type SignatureDB struct {
+	Probes     []BannerProbe     `json:"probes"`
+	Signatures []BannerSignature `json:"signatures"`
+}
+
+// ServiceMatch is a banner identified against the signature database.
+type ServiceMatch struct {
+	Host       string
+	Port       int
+	Service    string
+	Product    string
+	Version    string
+	Info       string
+	OS         string
+	DeviceType string
+	Banner     string // first line of the banner, for display
+}
+
+// DefaultSignatureDB returns the built-in probes and signatures.
+func DefaultSignatureDB() *SignatureDB {
+	db, err := ParseSignatureDB(defaultBannerSignatures)
+	if err != nil {
+		panic("probe: invalid built-in banner signatures: " + err.Error())
+	}
+	return db
+}
+
+// ParseSignatureDB decodes and compiles a JSON signature database.
+func ParseSignatureDB(data []byte) (*SignatureDB, error) {
+	var db SignatureDB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("error parsing signature database: %v", err)
+	}
+	for i := range db.Signatures {
+		s := &db.Signatures[i]
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("signature %d (%s): %v", i, s.Service, err)
+		}
+		s.re = re
+	}
+	return &db, nil
+}
+
+// LoadSignatureFile reads additional probes and signatures from a JSON file
+// with the same layout as the built-in set. They take precedence over the
+// existing ones.
+func (db *SignatureDB) LoadSignatureFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	extra, err := ParseSignatureDB(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	db.Probes = append(extra.Probes, db.Probes...)
+	db.Signatures = append(extra.Signatures, db.Signatures...)
+	return nil
+}
+
+// probeFor returns the first probe registered for a port.
+func (db *SignatureDB) probeFor(port int) *BannerProbe {
+	for i := range db.Probes {
+		for _, p := range db.Probes[i].Ports {
+			if p == port {
+				return &db.Probes[i]
+			}
+		}
+	}
+	return nil
+}
+
+// probeNamed returns the probe with the given name.
+func (db *SignatureDB) probeNamed(name string) *BannerProbe {
+	for i := range db.Probes {
+		if db.Probes[i].Name == name {
+			return &db.Probes[i]
+		}
+	}
+	return nil
+}
+
+// Match identifies a banner. It returns nil when no signature matches.
+func (db *SignatureDB) Match(banner []byte) *ServiceMatch {
+	// Match on a Latin-1 view so patterns like \xff address raw bytes.
+	text := latin1(banner)
+	for i := range db.Signatures {
+		s := &db.Signatures[i]
+		m := s.re.FindStringSubmatchIndex(text)
+		if m == nil {
+			continue
+		}
+		expand := func(template string) string {
+			return strings.TrimSpace(string(s.re.ExpandString(nil, template, text, m)))
+		}
+		return &ServiceMatch{
+			Service:    s.Service,
+			Product:    expand(s.Product),
+			Version:    expand(s.Version),
+			Info:       expand(s.Info),
+			OS:         expand(s.OS),
+			DeviceType: s.DeviceType,
+			Banner:     firstLine(text),
+		}
+	}
+	return nil
+}
+
+// GrabBanner connects to host:port and returns what the service says, either
+// unprompted or in answer to the port's hello probe. Ports without a probe get
+// a passive read first and an HTTP request if that stays silent. Raw print
+// ports are never probed.
+func (db *SignatureDB) GrabBanner(ctx context.Context, host string, port int, timeout time.Duration) ([]byte, error) {
+	if IsRawPrintPort(port) {
+		return nil, nil
+	}
+	probe := db.probeFor(port)
+	if probe != nil {
+		return bannerExchange(ctx, host, port, probe, timeout)
+	}
+
+	banner, err := bannerExchange(ctx, host, port, &BannerProbe{Name: "null", ReadFirst: true}, timeout)
+	if len(banner) > 0 || err != nil {
+		return banner, err
+	}
+	if httpProbe := db.probeNamed("http"); httpProbe != nil {
+		return bannerExchange(ctx, host, port, httpProbe, timeout)
+	}
+	return nil, nil
+}
+
+// bannerExchange runs one probe on a fresh connection.
+func bannerExchange(ctx context.Context, host string, port int, probe *BannerProbe, timeout time.Duration) ([]byte, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var banner []byte
+	if probe.ReadFirst {
+		banner = readBanner(conn, timeout)
+	}
+	if probe.Send != "" {
+		hello := strings.ReplaceAll(probe.Send, "{host}", host)
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write([]byte(hello)); err != nil {
+			return banner, nil
+		}
+		banner = append(banner, readBanner(conn, timeout)...)
+	}
+	return banner, nil
+}
+
+// readBanner reads until the peer pauses, closes, or 4 KiB arrive.
+func readBanner(conn net.Conn, timeout time.Duration) []byte {
+	var out []byte
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(timeout)
+	for len(out) < len(buf) {
+		// After the first bytes, a short pause means the banner is complete.
+		wait := time.Until(deadline)
+		if len(out) > 0 && wait > 300*time.Millisecond {
+			wait = 300 * time.Millisecond
+		}
+		conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(buf[:len(buf)-len(out)])
+		out = append(out, buf[:n]...)
+		if err != nil {
+			break // EOF, timeout or reset all end the banner
+		}
+	}
+	return out
+}
+
+// IdentifyServices grabs and matches banners on the given open ports of a
+// host concurrently. Ports whose banner matches nothing are omitted.
+func (db *SignatureDB) IdentifyServices(ctx context.Context, host string, ports []int, timeout time.Duration) []ServiceMatch {
+	var mu sync.Mutex
+	var matches []ServiceMatch
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for _, port := range ports {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			banner, err := db.GrabBanner(ctx, host, port, timeout)
+			if err != nil || len(banner) == 0 {
+				return
+			}
+			m := db.Match(banner)
+			if m == nil {
+				return
+			}
+			m.Host, m.Port = host, port
+			mu.Lock()
+			matches = append(matches, *m)
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Port < matches[j].Port })
+	return matches
+}
+
+// HostOS returns the OS hint reported by the most services. Ties go to the
+// hint seen first, so the result is stable for port-ordered matches.
+func HostOS(matches []ServiceMatch) string {
+	votes := make(map[string]int)
+	best := ""
+	for _, m := range matches {
+		if m.OS == "" {
+			continue
+		}
+		votes[m.OS]++
+		if best == "" || votes[m.OS] > votes[best] {
+			best = m.OS
+		}
+	}
+	return best
+}
+
+// HostDeviceType returns the device type most signatures agree on, or "".
+func HostDeviceType(matches []ServiceMatch) string {
+	votes := make(map[string]int)
+	best := ""
+	for _, m := range matches {
+		if m.DeviceType == "" {
+			continue
+		}
+		votes[m.DeviceType]++
+		if best == "" || votes[m.DeviceType] > votes[best] {
+			best = m.DeviceType
+		}
+	}
+	return best
+}
+
+// String renders a match as "22/ssh OpenSSH 8.9p1".
+func (m ServiceMatch) String() string {
+	s := strconv.Itoa(m.Port) + "/" + m.Service
+	if product := strings.TrimSpace(m.Product + " " + m.Version); product != "" {
+		s += " " + product
+	}
+	if m.Info != "" {
+		s += " (" + m.Info + ")"
+	}
+	return s
+}
+
+func latin1(b []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(b))
+	for _, c := range b {
+		sb.WriteRune(rune(c))
+	}
+	return sb.String()
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,338 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrabBannerSkipsRawPrintPorts(t *testing.T) {
+	db := DefaultSignatureDB()
+	for _, port := range []int{9100, 9103, 9107} {
+		// Nothing listens on loopback here, so any dial would fail
+		banner, err := db.GrabBanner(context.Background(), "127.0.0.1", port, time.Second)
+		if banner != nil || err != nil {
+			t.Errorf("port %d: got %q, %v; want no connection", port, banner, err)
+		}
+	}
+	for _, port := range []int{9099, 9108} {
+		if IsRawPrintPort(port) {
+			t.Errorf("IsRawPrintPort(%d) = true", port)
+		}
+	}
+}
+
+func TestHostDeviceType(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches []ServiceMatch
+		want    string
+	}{
+		{"none", []ServiceMatch{{Service: "ssh", OS: "Ubuntu"}}, ""},
+		{"one", []ServiceMatch{{Service: "ssh", OS: "Linux"}, {Service: "ftp", DeviceType: "printer"}}, "printer"},
+		{"majority", []ServiceMatch{{DeviceType: "router"}, {DeviceType: "nas"}, {DeviceType: "nas"}}, "nas"},
+		{"tie keeps the first", []ServiceMatch{{DeviceType: "router"}, {DeviceType: "nas"}}, "router"},
+	}
+	for _, tt := range tests {
+		if got := HostDeviceType(tt.matches); got != tt.want {
+			t.Errorf("%s: HostDeviceType = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHostOS(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches []ServiceMatch
+		want    string
+	}{
+		{"none", []ServiceMatch{{Service: "http", Product: "nginx"}}, ""},
+		{"majority", []ServiceMatch{{OS: "Windows"}, {OS: "Linux"}, {OS: "Linux"}}, "Linux"},
+		{"tie keeps the first", []ServiceMatch{{OS: "Linux"}, {OS: "Linux (Ubuntu)"}}, "Linux"},
+		{"tie keeps the first, reversed", []ServiceMatch{{OS: "Linux (Ubuntu)"}, {OS: "Linux"}}, "Linux (Ubuntu)"},
+	}
+	for _, tt := range tests {
+		// Repeat to catch results that depend on map iteration order
+		for i := 0; i < 20; i++ {
+			if got := HostOS(tt.matches); got != tt.want {
+				t.Fatalf("%s: HostOS = %q, want %q", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name, banner                        string
+		service, product, version, info, os string
+	}{
+		{
+			"openssh ubuntu",
+			"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n",
+			"ssh", "OpenSSH", "8.9p1", "", "Linux (Ubuntu)",
+		},
+		{
+			"dropbear",
+			"SSH-2.0-dropbear_2020.81\r\n",
+			"ssh", "Dropbear", "2020.81", "", "Linux (embedded)",
+		},
+		{
+			"postfix ehlo",
+			"220 mail.example.com ESMTP Postfix (Ubuntu)\r\n" +
+				"250-mail.example.com\r\n250-PIPELINING\r\n250-SIZE 10240000\r\n250-STARTTLS\r\n250 8BITMIME\r\n",
+			"smtp", "Postfix smtpd", "", "", "Ubuntu",
+		},
+		{
+			"exim ehlo",
+			"220 mx.example.org ESMTP Exim 4.96 Mon, 02 Oct 2023 10:00:00 +0000\r\n" +
+				"250-mx.example.org Hello sentinel.local [192.0.2.1]\r\n250-SIZE 52428800\r\n250 HELP\r\n",
+			"smtp", "Exim smtpd", "4.96", "", "",
+		},
+		{
+			"vsftpd",
+			"220 (vsFTPd 3.0.5)\r\n",
+			"ftp", "vsftpd", "3.0.5", "", "Linux",
+		},
+		{
+			"proftpd",
+			"220 ProFTPD 1.3.8 Server (Debian) [::ffff:192.0.2.5]\r\n",
+			"ftp", "ProFTPD", "1.3.8", "", "",
+		},
+		{
+			"redis ping",
+			"+PONG\r\n",
+			"redis", "Redis key-value store", "", "", "",
+		},
+		{
+			"redis auth",
+			"-NOAUTH Authentication required.\r\n",
+			"redis", "Redis key-value store", "", "authentication required", "",
+		},
+		{
+			"mysql handshake",
+			"\x4a\x00\x00\x00\x0a8.0.35-0ubuntu0.22.04.1\x00\x0b\x00\x00\x00\x2a\x5b\x1d\x3f\x4c\x01\x66\x70\x00\xff\xff\xff\x02\x00\xff\xdf\x15",
+			"mysql", "MySQL", "8.0.35-0ubuntu0.22.04.1", "", "",
+		},
+		{
+			"mariadb handshake",
+			"\x5a\x00\x00\x00\x0a5.5.5-10.11.6-MariaDB-0+deb12u1\x00\x1f\x00\x00\x00\x3b\x55\x2b\x6e\x7d\x27\x3c\x4f\x00\xfe\xff",
+			"mysql", "MariaDB", "10.11.6", "", "",
+		},
+		{
+			"mysql host denied",
+			"\x45\x00\x00\x00\xff\x6a\x04Host '192.0.2.1' is not allowed to connect to this MySQL server",
+			"mysql", "MySQL", "", "unauthorized host", "",
+		},
+		{
+			"apache head",
+			"HTTP/1.1 200 OK\r\nDate: Mon, 02 Oct 2023 10:00:00 GMT\r\nServer: Apache/2.4.52 (Ubuntu)\r\n" +
+				"Last-Modified: Tue, 01 Aug 2023 08:00:00 GMT\r\nContent-Type: text/html\r\n\r\n",
+			"http", "Apache httpd", "2.4.52", "", "Linux (Ubuntu)",
+		},
+		{
+			"iis head",
+			"HTTP/1.1 200 OK\r\nContent-Length: 703\r\nContent-Type: text/html\r\nServer: Microsoft-IIS/10.0\r\n\r\n",
+			"http", "Microsoft IIS httpd", "10.0", "", "Windows",
+		},
+		{
+			"unknown server header",
+			"HTTP/1.0 302 Found\r\nLocation: /login\r\nServer: GoAhead-Webs\r\n\r\n",
+			"http", "GoAhead-Webs", "", "", "",
+		},
+	}
+	db := DefaultSignatureDB()
+	for _, tt := range tests {
+		m := db.Match([]byte(tt.banner))
+		if m == nil {
+			t.Errorf("%s: no match", tt.name)
+			continue
+		}
+		if m.Service != tt.service || m.Product != tt.product || m.Version != tt.version || m.Info != tt.info || m.OS != tt.os {
+			t.Errorf("%s: got %s/%q/%q/%q/%q, want %s/%q/%q/%q/%q", tt.name,
+				m.Service, m.Product, m.Version, m.Info, m.OS,
+				tt.service, tt.product, tt.version, tt.info, tt.os)
+		}
+	}
+	for _, banner := range []string{"", "hello\r\n", "\x00\x00\x00\x00"} {
+		if m := db.Match([]byte(banner)); m != nil {
+			t.Errorf("Match(%q) = %+v, want nil", banner, m)
+		}
+	}
+}
+
+func TestLoadSignatureFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	extra := `{
+  "probes": [{"name": "custom-ssh", "ports": [22], "read_first": true}],
+  "signatures": [{"service": "ssh", "pattern": "^SSH-[\\d.]+-OpenSSH_([\\w.]+)", "product": "Appliance sshd", "version": "$1", "device_type": "nas"}]
+}`
+	if err := os.WriteFile(path, []byte(extra), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := DefaultSignatureDB()
+	if err := db.LoadSignatureFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if p := db.probeFor(22); p == nil || p.Name != "custom-ssh" {
+		t.Errorf("probe for port 22 = %+v, want custom-ssh", p)
+	}
+	if p := db.probeFor(21); p == nil || p.Name != "ftp" {
+		t.Errorf("probe for port 21 = %+v, want built-in ftp", p)
+	}
+	m := db.Match([]byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n"))
+	if m == nil || m.Product != "Appliance sshd" || m.Version != "8.9p1" || m.DeviceType != "nas" {
+		t.Errorf("OpenSSH banner: got %+v, want the loaded signature", m)
+	}
+	if m := db.Match([]byte("SSH-2.0-dropbear_2020.81\r\n")); m == nil || m.Product != "Dropbear" {
+		t.Errorf("dropbear banner: got %+v, want the built-in signature", m)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"signatures": [{"service": "x", "pattern": "("}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	n := len(db.Signatures)
+	if err := db.LoadSignatureFile(bad); err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("invalid pattern: err = %v, want an error naming the file", err)
+	}
+	if err := db.LoadSignatureFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file: err = nil")
+	}
+	if len(db.Signatures) != n {
+		t.Errorf("failed loads changed the database: %d signatures, want %d", len(db.Signatures), n)
+	}
+}
+
+// serveBanner accepts connections on a loopback listener and hands each to
+// handle. It returns the listener's port.
+func serveBanner(t *testing.T, handle func(conn net.Conn)) int {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// readHello reads what the client sent, up to the end of its first message.
+func readHello(conn net.Conn) string {
+	buf := make([]byte, 1024)
+	n, _ := conn.Read(buf)
+	return string(buf[:n])
+}
+
+func TestGrabBanner(t *testing.T) {
+	hellos := make(chan string, 4)
+
+	// SSH-style: the server speaks first, then answers the client's hello.
+	sshPort := serveBanner(t, func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+		hellos <- readHello(conn)
+	})
+	// Redis-style: silent until sent a command.
+	redisPort := serveBanner(t, func(conn net.Conn) {
+		hellos <- readHello(conn)
+		conn.Write([]byte("+PONG\r\n"))
+	})
+	// A port without a probe that only answers HTTP: the passive read on the
+	// first connection stays silent, the HTTP probe on the second gets a reply.
+	httpPort := serveBanner(t, func(conn net.Conn) {
+		hello := readHello(conn)
+		if hello == "" {
+			return
+		}
+		hellos <- hello
+		conn.Write([]byte("HTTP/1.0 200 OK\r\nServer: lighttpd/1.4.59\r\n\r\n"))
+	})
+
+	db, err := ParseSignatureDB([]byte(fmt.Sprintf(`{
+  "probes": [
+    {"name": "http", "send": "HEAD / HTTP/1.0\r\nHost: {host}\r\n\r\n"},
+    {"name": "ssh", "ports": [%d], "read_first": true, "send": "SSH-2.0-sentinel\r\n"},
+    {"name": "redis", "ports": [%d], "send": "PING\r\n"}
+  ]
+}`, sshPort, redisPort)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		port   int
+		banner string
+		hello  string
+	}{
+		{"read first", sshPort, "SSH-2.0-OpenSSH_9.6\r\n", "SSH-2.0-sentinel\r\n"},
+		{"send", redisPort, "+PONG\r\n", "PING\r\n"},
+		{"http fallback", httpPort, "HTTP/1.0 200 OK\r\nServer: lighttpd/1.4.59\r\n\r\n", "HEAD / HTTP/1.0\r\nHost: 127.0.0.1\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		banner, err := db.GrabBanner(context.Background(), "127.0.0.1", tt.port, 500*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(banner) != tt.banner {
+			t.Errorf("%s: banner = %q, want %q", tt.name, banner, tt.banner)
+		}
+		select {
+		case hello := <-hellos:
+			if hello != tt.hello {
+				t.Errorf("%s: server got %q, want %q", tt.name, hello, tt.hello)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s: server got no hello", tt.name)
+		}
+	}
+
+	// A closed port reports the dial error.
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	if _, err := db.GrabBanner(context.Background(), "127.0.0.1", closed, 500*time.Millisecond); err == nil {
+		t.Error("closed port: err = nil")
+	}
+}
+
+func TestMatchTLS(t *testing.T) {
+	tests := []struct {
+		name, banner, product string
+	}{
+		{"alert", "\x15\x03\x01\x00\x02\x02\x46", ""},
+		{"handshake", "\x16\x03\x03\x00\x5d\x02", ""},
+		{"nginx", "HTTP/1.1 400 Bad Request\r\nServer: nginx\r\n\r\n<center>The plain HTTP request was sent to HTTPS port</center>", "nginx"},
+	}
+	db := DefaultSignatureDB()
+	for _, tt := range tests {
+		m := db.Match([]byte(tt.banner))
+		if m == nil || m.Service != "ssl" || m.Product != tt.product {
+			t.Errorf("%s: got %+v, want ssl %q", tt.name, m, tt.product)
+		}
+	}
+	if m := db.Match([]byte("HTTP/1.1 400 Bad Request\r\nServer: nginx/1.24.0\r\n\r\n")); m == nil || m.Service != "http" {
+		t.Errorf("plain 400: got %+v, want http", m)
+	}
+}
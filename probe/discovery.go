@@ -4,86 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/netip"
 
 	// "log"
 	"net"
+	"net/netip"
 	"time"
-	"os/exec"
-	"bytes"
-	"regexp"
 	"strings"
 
 	"github.com/Ullaakut/nmap/v2"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/pcap"
-	"github.com/mdlayher/arp"
 	"github.com/sofc-t/sentinel/domain/models"
 )
 
 
-func CaptureLLDP(interfaceName string, captureTimeout time.Duration) ([]models.Device, error) {
-	var devices []models.Device
-
-	handle, err := pcap.OpenLive(interfaceName, 1600, true, 100*time.Second)
-	if err != nil {
-		return nil, fmt.Errorf("error opening interface %s: %v", interfaceName, err)
-	}
-	defer handle.Close()
-
-	if err := handle.SetBPFFilter("ether proto 0x88cc"); err != nil {
-		return nil, fmt.Errorf("error setting BPF filter: %v", err)
-	}
-
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-	packets := packetSource.Packets()
-
-	timeout := time.After(captureTimeout)
-
-	LOOP:
-		for {
-			select {
-			case packet, ok := <-packets:
-				if !ok {
-					log.Println("[LLDP] Packet channel closed.")
-					break LOOP
-				}
-				if packet == nil {
-					continue
-				}
-
-				fmt.Println("[LLDP] Captured packet:", packet)
-
-				config := models.DeviceConfig{
-					Hostname: "lldp-device", // TODO: Parse real hostname from packet
-					DeviceType: "switch",
-					Status: "active",
-					MonitoringProtocols: []string{"LLDP"},
-				}
-				device := models.NewDevice(config)
-				devices = append(devices, *device)
-
-			case <-timeout:
-				fmt.Println("[LLDP] Timeout reached, finishing capture.")
-				break LOOP
-			}
-		}
-
-	log.Printf("[LLDP] Capture finished. Found %d device(s).\n", len(devices))
-	return devices, nil
-}
-
-
-
 func ScanIPRange(subnet string) ([]models.Device, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1000*time.Second)
 	defer cancel()
 
-	scanner, err := nmap.NewScanner(
+	options := []func(*nmap.Scanner){
 		nmap.WithTargets(subnet),
 		nmap.WithPingScan(),
 		nmap.WithContext(ctx),
-	)
+	}
+	if isIPv6Target(subnet) {
+		options = append(options, nmap.WithIPv6Scanning())
+	}
+
+	scanner, err := nmap.NewScanner(options...)
 	if err != nil {
 		return nil, fmt.Errorf("error creating scanner: %v", err)
 	}
@@ -95,15 +43,31 @@ func ScanIPRange(subnet string) ([]models.Device, error) {
 	var devices []models.Device
 	for _, host := range result.Hosts {
 		if len(host.Addresses) > 0 {
+			// Nmap lists IPv4, IPv6 and (on the local segment) MAC addresses
+			var ips []string
+			mac := ""
+			for _, addr := range host.Addresses {
+				switch addr.AddrType {
+				case "mac":
+					mac = strings.ToLower(addr.Addr)
+				default:
+					ips = append(ips, addr.Addr)
+				}
+			}
+			if len(ips) == 0 {
+				continue
+			}
+
 			// Create a DeviceConfig first
 			config := models.DeviceConfig{
 				Hostname: "", // Nmap Ping scan may not give hostname directly
-				IPAddress: host.Addresses[0].String(),
+				IPAddresses: ips,
 				DeviceType: "unknown", // deeper scan later
 				Vendor: "",
 				Status: "active",
 				MonitoringProtocols: []string{"Nmap"},
 				Interfaces: nil,
+				MACAddress: mac,
 			}
 			
 			// Create Device from DeviceConfig
@@ -116,120 +80,16 @@ func ScanIPRange(subnet string) ([]models.Device, error) {
 	return devices, nil
 }
 
-func ARPScan(interfaceName string) ([]models.Device, error) {
-	iface, err := net.InterfaceByName(interfaceName)
-	if err != nil {
-		return nil, fmt.Errorf("error finding interface %s: %v", interfaceName, err)
-	}
-
-	client, err := arp.Dial(iface)
-	if err != nil {
-		return nil, fmt.Errorf("error creating ARP client: %v", err)
-	}
-	defer client.Close()
-
-	var devices []models.Device
-
-	// Define subnet
-	subnet := "192.168.107.0/24"
-	ipNet, err := netip.ParsePrefix(subnet)
-	if err != nil {
-		return nil, fmt.Errorf("invalid subnet %s: %v", subnet, err)
-	}
-
-	// Channel for IPs
-	ipChan := make(chan netip.Addr, 100)
-	// Channel for found devices
-	deviceChan := make(chan models.Device, 100)
-
-	const (
-		numWorkers    = 50
-		timeoutPerIP  = 300 * time.Millisecond
-	)
-
-	// Start workers
-	for i := 0; i < numWorkers; i++ {
-		go func() {
-			for ip := range ipChan {
-				if ip.IsMulticast() || ip.IsLinkLocalUnicast() {
-					continue
-				}
-				mac, err := resolveWithTimeout(client, ip, timeoutPerIP)
-				if err == nil {
-					fmt.Printf("[ARP] Found device: IP=%s, MAC=%s\n", ip, mac)
-					config := models.DeviceConfig{
-						Hostname: "",
-						IPAddress: ip.String(),
-						DeviceType: "unknown",
-						Vendor: "",
-						Status: "active",
-						MonitoringProtocols: []string{"ARP"},
-						Interfaces: nil,
-					}
-					device := models.NewDevice(config)
-					deviceChan <- *device
-				} else {
-					fmt.Printf("[ARP] %s not found: %v\n", ip, err)
-				}
-			}
-		}()
-	}
-
-	// Feed IPs
-	go func() {
-		for ip := ipNet.Addr(); ipNet.Contains(ip); ip = ip.Next() {
-			ipChan <- ip
-		}
-		close(ipChan)
-	}()
-
-	// Collect devices
-	go func() {
-		for dev := range deviceChan {
-			devices = append(devices, dev)
-		}
-	}()
-
-	// Wait for all IPs to be scanned
-	time.Sleep(time.Duration(len(devices)/numWorkers+1) * timeoutPerIP)
-
-	close(deviceChan)
-
-	return devices, nil
-}
-
-
-
-
-
-func resolveWithTimeout(client *arp.Client, ip netip.Addr, timeout time.Duration) (net.HardwareAddr, error) {
-    type result struct {
-        mac net.HardwareAddr
-        err error
-    }
-
-    resultChan := make(chan result, 1)
-
-    go func() {
-        mac, err := client.Resolve(ip)
-        resultChan <- result{mac: mac, err: err}
-    }()
-
-    select {
-    case res := <-resultChan:
-        return res.mac, res.err
-    case <-time.After(timeout):
-        return nil, fmt.Errorf("timeout after %v", timeout)
-    }
-}
-
-
+// FindDefaultInterfaceAndSubnet returns the first active interface and its
+// subnet, preferring IPv4 and falling back to a routable IPv6 subnet.
 func FindDefaultInterfaceAndSubnet() (string, string, error) {
     interfaces, err := net.Interfaces()
     if err != nil {
         return "", "", fmt.Errorf("failed to list interfaces: %v", err)
     }
 
+    var fallbackIface, fallbackSubnet string
+
     for _, iface := range interfaces {
         if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
             continue // skip down or loopback interfaces
@@ -255,7 +115,12 @@ func FindDefaultInterfaceAndSubnet() (string, string, error) {
 
             ip4 := ipNet.IP.To4()
             if ip4 == nil {
-                continue // not an IPv4 address
+                // Remember the first routable IPv6 subnet in case there is no IPv4
+                if fallbackIface == "" && ipNet.IP.IsGlobalUnicast() {
+                    fallbackIface = iface.Name
+                    fallbackSubnet = fmt.Sprintf("%s/%d", ipNet.IP.String(), maskToPrefix(ipNet.Mask))
+                }
+                continue
             }
 
             // Found a valid interface
@@ -263,67 +128,56 @@ func FindDefaultInterfaceAndSubnet() (string, string, error) {
             return iface.Name, subnet, nil
         }
     }
+    if fallbackIface != "" {
+        return fallbackIface, fallbackSubnet, nil
+    }
     return "", "", fmt.Errorf("no active network interface found")
 }
 
-func maskToPrefix(mask net.IPMask) int {
-    ones, _ := mask.Size()
-    return ones
-}
-
-
-func NmapFingerprint(ip string) (string, string) {
-    // Use -Pn to skip host discovery (faster if ICMP is blocked),
-    // -sS for a quick SYN scan, -T4 for speed, and --open to ignore closed ports.
-    cmd := exec.Command("nmap", "-Pn", "-sS", "-sV", "-T4", "--open", "--max-retries", "2", "--host-timeout", "10s", ip)
-    var out bytes.Buffer
-    cmd.Stdout = &out
-    cmd.Stderr = &out
-
-    if err := cmd.Run(); err != nil {
-        log.Printf("[NmapFingerprint] Error running nmap on %s: %v", ip, err)
-        return "", ""
+// InterfacePrefixes returns the IPv4 and routable IPv6 prefixes configured on
+// an interface. IPv6 link-local prefixes are skipped.
+func InterfacePrefixes(interfaceName string) ([]netip.Prefix, error) {
+    iface, err := net.InterfaceByName(interfaceName)
+    if err != nil {
+        return nil, fmt.Errorf("error finding interface %s: %v", interfaceName, err)
     }
-
-    output := out.String()
-
-    // Match open TCP ports with service and version info
-    re := regexp.MustCompile(`(?m)^(\d+)/tcp\s+open\s+([\w\-]+)(?:\s+(.*))?$`)
-    matches := re.FindAllStringSubmatch(output, -1)
-
-    if len(matches) == 0 {
-        return "", ""
+    addrs, err := iface.Addrs()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list addresses on %s: %v", interfaceName, err)
     }
 
-    var protocols []string
-    var descParts []string
-    for _, m := range matches {
-        port := m[1]
-        service := m[2]
-        info := strings.TrimSpace(m[3])
-        if info != "" {
-            descParts = append(descParts, fmt.Sprintf("%s/%s(%s)", port, service, info))
-        } else {
-            descParts = append(descParts, fmt.Sprintf("%s/%s", port, service))
+    var prefixes []netip.Prefix
+    for _, addr := range addrs {
+        ipNet, ok := addr.(*net.IPNet)
+        if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+            continue
         }
-        protocols = append(protocols, service)
+        prefix, err := netip.ParsePrefix(fmt.Sprintf("%s/%d", ipNet.IP.String(), maskToPrefix(ipNet.Mask)))
+        if err != nil {
+            continue
+        }
+        prefixes = append(prefixes, prefix)
     }
-
-    return strings.Join(descParts, "; "), strings.Join(protocols, ",")
+    return prefixes, nil
 }
 
-
-func LookupVendor(mac string) string {
-    if mac == "" {
-        return ""
-    }
-    parts := strings.Split(mac, ":")
-    if len(parts) >= 2 {
-        return "Vendor-" + strings.ToUpper(parts[0]+parts[1])
+// isIPv6Target reports whether an address or CIDR target is IPv6.
+func isIPv6Target(target string) bool {
+    host, _, _ := strings.Cut(target, "/")
+    host = strings.Trim(host, "[]")
+    if i := strings.IndexByte(host, '%'); i >= 0 {
+        host = host[:i]
     }
-    return "UnknownVendor"
+    addr, err := netip.ParseAddr(host)
+    return err == nil && addr.Is6() && !addr.Is4In6()
 }
 
+func maskToPrefix(mask net.IPMask) int {
+    ones, _ := mask.Size()
+    return ones
+}
+
+
 
 func PassiveCapture(interfaceName string, duration time.Duration) []string {
     var discovered []string
@@ -0,0 +1,390 @@
+package probe
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// System group and HOST-RESOURCES-MIB (RFC 2790) objects.
+const (
+	oidSysObjectID     = ".1.3.6.1.2.1.1.2.0"
+	oidSysUpTime       = ".1.3.6.1.2.1.1.3.0"
+	oidHrSystemUptime  = ".1.3.6.1.2.1.25.1.1.0"
+	oidHrStorageEntry  = ".1.3.6.1.2.1.25.2.3.1"
+	oidHrStorageTypes  = ".1.3.6.1.2.1.25.2.1."
+	oidHrProcessorLoad = ".1.3.6.1.2.1.25.3.3.1" // hrProcessorEntry
+)
+
+// hrStorageEntry columns.
+const (
+	hrStorageColType      = 2
+	hrStorageColDescr     = 3
+	hrStorageColAllocUnit = 4
+	hrStorageColSize      = 5
+	hrStorageColUsed      = 6
+
+	hrProcessorColLoad = 2
+)
+
+// UCD-SNMP-MIB objects, implemented by net-snmp on Linux and the BSDs.
+const (
+	oidUcdSsCpuIdle    = ".1.3.6.1.4.1.2021.11.11.0"
+	oidUcdMemTotalReal = ".1.3.6.1.4.1.2021.4.5.0"
+	oidUcdMemAvailReal = ".1.3.6.1.4.1.2021.4.6.0"
+	oidUcdMemBuffer    = ".1.3.6.1.4.1.2021.4.14.0"
+	oidUcdMemCached    = ".1.3.6.1.4.1.2021.4.15.0"
+	oidUcdDskEntry     = ".1.3.6.1.4.1.2021.9.1"
+
+	ucdDskColPath  = 2
+	ucdDskColTotal = 6 // kB
+	ucdDskColUsed  = 8 // kB
+)
+
+// Vendor MIBs.
+const (
+	// CISCO-PROCESS-MIB cpmCPUTotalEntry; column 8 is cpmCPUTotal5minRev
+	oidCiscoCPUTotalEntry   = ".1.3.6.1.4.1.9.9.109.1.1.1.1"
+	ciscoCPUColTotal5min    = 8
+	oidCiscoAvgBusy5        = ".1.3.6.1.4.1.9.2.1.58.0" // OLD-CISCO-CPU-MIB, pre-12.0 IOS
+	oidCiscoMemPoolEntry    = ".1.3.6.1.4.1.9.9.48.1.1.1"
+	ciscoMemPoolColUsed     = 5
+	ciscoMemPoolColFree     = 6
+	oidCiscoEnhMemPoolEntry = ".1.3.6.1.4.1.9.9.221.1.1.1.1" // CISCO-ENHANCED-MEMPOOL-MIB, IOS XE and NX-OS
+	ciscoEnhMemColType      = 2
+	ciscoEnhMemColHCUsed    = 18
+	ciscoEnhMemColHCFree    = 20
+
+	// JUNIPER-MIB jnxOperatingEntry
+	oidJnxOperatingEntry = ".1.3.6.1.4.1.2636.3.1.13.1"
+	jnxColDescr          = 5
+	jnxColCPU            = 8
+	jnxColBuffer         = 11
+)
+
+// Enterprise prefixes of sysObjectID.
+const (
+	enterpriseCisco   = ".1.3.6.1.4.1.9."
+	enterpriseJuniper = ".1.3.6.1.4.1.2636."
+)
+
+// hrStorageTypes names the hrStorageType values under hrStorageTypes.
+var hrStorageTypes = map[string]string{
+	"1":  "other",
+	"2":  "ram",
+	"3":  "virtualMemory",
+	"4":  "fixedDisk",
+	"5":  "removableDisk",
+	"6":  "floppyDisk",
+	"7":  "compactDisc",
+	"8":  "ramDisk",
+	"9":  "flashMemory",
+	"10": "networkDisk",
+}
+
+// StorageUsage is the utilisation of one memory or disk area.
+type StorageUsage struct {
+	Index       string
+	Description string // mount point or pool name
+	Type        string // an hrStorageType name, e.g. "ram" or "fixedDisk"
+	SizeBytes   uint64
+	UsedBytes   uint64
+	Percent     float64
+}
+
+// HostResources is the CPU, memory and storage utilisation of a device.
+type HostResources struct {
+	Uptime time.Duration // host uptime, or agent uptime if the host's is unknown
+	// CPU and Mem are percentages; HasCPU and HasMem say whether the device
+	// reported them at all, since 0 is a valid load.
+	CPU    float64
+	HasCPU bool
+	Mem    float64
+	HasMem bool
+	// MemTotalBytes and MemUsedBytes are 0 when the MIB that supplied Mem
+	// only reports a percentage.
+	MemTotalBytes uint64
+	MemUsedBytes  uint64
+	Storage       []StorageUsage // disks and flash
+	// CPUSource and MemSource name the MIB each figure came from.
+	CPUSource string
+	MemSource string
+}
+
+// FetchHostResources collects CPU, memory and storage utilisation. The
+// vendor MIB matching sysObjectID is tried first (Cisco and Juniper keep
+// their real figures there), then HOST-RESOURCES-MIB, which most servers,
+// printers and MikroTik RouterOS implement, then UCD-SNMP-MIB. Missing
+// objects are not an error; only an unreachable agent is.
+func FetchHostResources(cfg SNMPConfig) (*HostResources, error) {
+	res, err := FetchMetrics(cfg, []string{oidSysObjectID, oidSysUpTime, oidHrSystemUptime})
+	if err != nil {
+		return nil, err
+	}
+	values := res.Metrics.Values
+	hr := &HostResources{}
+	if ticks, ok := parseTicks(values[oidHrSystemUptime]); ok {
+		hr.Uptime = ticks
+	} else if ticks, ok := parseTicks(values[oidSysUpTime]); ok {
+		hr.Uptime = ticks
+	}
+
+	sysObjectID := "." + strings.TrimPrefix(values[oidSysObjectID], ".")
+	collectors := []func(SNMPConfig, *HostResources){hostResourcesMIB, ucdSNMP}
+	switch {
+	case strings.HasPrefix(sysObjectID, enterpriseCisco):
+		collectors = append([]func(SNMPConfig, *HostResources){ciscoResources}, collectors...)
+	case strings.HasPrefix(sysObjectID, enterpriseJuniper):
+		collectors = append([]func(SNMPConfig, *HostResources){juniperResources}, collectors...)
+	}
+	for _, collect := range collectors {
+		collect(cfg, hr)
+	}
+	sort.Slice(hr.Storage, func(i, j int) bool { return hr.Storage[i].Description < hr.Storage[j].Description })
+	return hr, nil
+}
+
+// hostResourcesMIB reads hrProcessorTable and hrStorageTable.
+func hostResourcesMIB(cfg SNMPConfig, hr *HostResources) {
+	if !hr.HasCPU {
+		if cpus, err := walkTable(cfg, oidHrProcessorLoad); err == nil {
+			var loads []float64
+			for _, row := range cpus {
+				if v, ok := row[hrProcessorColLoad]; ok {
+					loads = append(loads, float64(atoiOrZero(v)))
+				}
+			}
+			if len(loads) > 0 {
+				hr.CPU, hr.HasCPU, hr.CPUSource = average(loads), true, "HOST-RESOURCES-MIB"
+			}
+		}
+	}
+
+	storage, err := walkTable(cfg, oidHrStorageEntry)
+	if err != nil {
+		return
+	}
+	var ramTotal, ramUsed uint64
+	for index, row := range storage {
+		s, ok := hrStorageUsage(index, row)
+		if !ok {
+			continue
+		}
+		switch s.Type {
+		case "ram":
+			// RouterOS calls it "main memory", net-snmp "Physical memory"
+			ramTotal += s.SizeBytes
+			ramUsed += s.UsedBytes
+		case "fixedDisk", "removableDisk", "flashMemory", "networkDisk":
+			hr.Storage = append(hr.Storage, s)
+		}
+	}
+	if !hr.HasMem && ramTotal > 0 {
+		hr.setMem(ramTotal, ramUsed, "HOST-RESOURCES-MIB")
+	}
+}
+
+// hrStorageUsage converts one hrStorageEntry row.
+func hrStorageUsage(index string, row map[int]string) (StorageUsage, bool) {
+	typ := strings.TrimPrefix("."+strings.TrimPrefix(row[hrStorageColType], "."), oidHrStorageTypes)
+	units := parseUint(row[hrStorageColAllocUnit])
+	size, used := parseInt32Unsigned(row[hrStorageColSize]), parseInt32Unsigned(row[hrStorageColUsed])
+	if units == 0 || size == 0 {
+		return StorageUsage{}, false
+	}
+	s := StorageUsage{
+		Index:       index,
+		Description: row[hrStorageColDescr],
+		Type:        hrStorageTypes[typ],
+		SizeBytes:   size * units,
+		UsedBytes:   used * units,
+	}
+	if s.Type == "" {
+		s.Type = "other"
+	}
+	s.Percent = percent(s.UsedBytes, s.SizeBytes)
+	return s, true
+}
+
+// ucdSNMP reads the net-snmp CPU, memory and disk objects. Its memory figure
+// is preferred over HOST-RESOURCES-MIB on the same agent, as net-snmp counts
+// buffers and page cache as used in hrStorageRam.
+func ucdSNMP(cfg SNMPConfig, hr *HostResources) {
+	res, err := FetchMetrics(cfg, []string{
+		oidUcdSsCpuIdle, oidUcdMemTotalReal, oidUcdMemAvailReal, oidUcdMemBuffer, oidUcdMemCached,
+	})
+	if err != nil {
+		return
+	}
+	values := res.Metrics.Values
+	if idle, ok := values[oidUcdSsCpuIdle]; ok && !hr.HasCPU {
+		hr.CPU, hr.HasCPU, hr.CPUSource = clampPercent(100-float64(atoiOrZero(idle))), true, "UCD-SNMP-MIB"
+	}
+	if totalKB := parseUint(values[oidUcdMemTotalReal]); totalKB > 0 && (!hr.HasMem || hr.MemSource == "HOST-RESOURCES-MIB") {
+		free := parseUint(values[oidUcdMemAvailReal]) + parseUint(values[oidUcdMemBuffer]) + parseUint(values[oidUcdMemCached])
+		usedKB := uint64(0)
+		if free < totalKB {
+			usedKB = totalKB - free
+		}
+		hr.setMem(totalKB*1024, usedKB*1024, "UCD-SNMP-MIB")
+	}
+
+	if len(hr.Storage) > 0 {
+		return
+	}
+	disks, err := walkTable(cfg, oidUcdDskEntry)
+	if err != nil {
+		return
+	}
+	for index, row := range disks {
+		total := parseUint(row[ucdDskColTotal]) * 1024
+		if total == 0 {
+			continue
+		}
+		used := parseUint(row[ucdDskColUsed]) * 1024
+		hr.Storage = append(hr.Storage, StorageUsage{
+			Index:       index,
+			Description: row[ucdDskColPath],
+			Type:        "fixedDisk",
+			SizeBytes:   total,
+			UsedBytes:   used,
+			Percent:     percent(used, total),
+		})
+	}
+}
+
+// ciscoResources reads CISCO-PROCESS-MIB and the Cisco memory pool MIBs.
+func ciscoResources(cfg SNMPConfig, hr *HostResources) {
+	if cpus, err := walkTable(cfg, oidCiscoCPUTotalEntry); err == nil {
+		var loads []float64
+		for _, row := range cpus {
+			if v, ok := row[ciscoCPUColTotal5min]; ok {
+				loads = append(loads, float64(atoiOrZero(v)))
+			}
+		}
+		if len(loads) > 0 {
+			hr.CPU, hr.HasCPU, hr.CPUSource = average(loads), true, "CISCO-PROCESS-MIB"
+		}
+	}
+	if !hr.HasCPU {
+		if res, err := FetchMetrics(cfg, []string{oidCiscoAvgBusy5}); err == nil {
+			if v, ok := res.Metrics.Values[oidCiscoAvgBusy5]; ok {
+				hr.CPU, hr.HasCPU, hr.CPUSource = float64(atoiOrZero(v)), true, "OLD-CISCO-CPU-MIB"
+			}
+		}
+	}
+
+	// IOS lists the processor pool and the I/O pool; both are DRAM
+	if pools, err := walkTable(cfg, oidCiscoMemPoolEntry); err == nil {
+		var used, free uint64
+		for _, row := range pools {
+			used += parseUint(row[ciscoMemPoolColUsed])
+			free += parseUint(row[ciscoMemPoolColFree])
+		}
+		if used+free > 0 {
+			hr.setMem(used+free, used, "CISCO-MEMORY-POOL-MIB")
+			return
+		}
+	}
+	// Platforms without CISCO-MEMORY-POOL-MIB; type 2 is the processor pool
+	if pools, err := walkTable(cfg, oidCiscoEnhMemPoolEntry); err == nil {
+		var used, free uint64
+		for _, row := range pools {
+			if row[ciscoEnhMemColType] != "2" {
+				continue
+			}
+			used += parseUint(row[ciscoEnhMemColHCUsed])
+			free += parseUint(row[ciscoEnhMemColHCFree])
+		}
+		if used+free > 0 {
+			hr.setMem(used+free, used, "CISCO-ENHANCED-MEMPOOL-MIB")
+		}
+	}
+}
+
+// juniperResources reads the routing engine rows of jnxOperatingTable,
+// which report CPU and memory as percentages.
+func juniperResources(cfg SNMPConfig, hr *HostResources) {
+	rows, err := walkTable(cfg, oidJnxOperatingEntry)
+	if err != nil {
+		return
+	}
+	var cpus, mems []float64
+	for _, row := range rows {
+		if !strings.Contains(strings.ToLower(row[jnxColDescr]), "routing engine") {
+			continue
+		}
+		cpus = append(cpus, float64(atoiOrZero(row[jnxColCPU])))
+		mems = append(mems, float64(atoiOrZero(row[jnxColBuffer])))
+	}
+	if len(cpus) == 0 {
+		return
+	}
+	hr.CPU, hr.HasCPU, hr.CPUSource = average(cpus), true, "JUNIPER-MIB"
+	hr.Mem, hr.HasMem, hr.MemSource = average(mems), true, "JUNIPER-MIB"
+}
+
+func (hr *HostResources) setMem(total, used uint64, source string) {
+	hr.Mem, hr.HasMem, hr.MemSource = percent(used, total), true, source
+	hr.MemTotalBytes, hr.MemUsedBytes = total, used
+}
+
+// FormatUptime renders an uptime for tables, e.g. "12d 3h 4m".
+func FormatUptime(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// parseTicks converts TimeTicks (hundredths of a second) to a duration.
+func parseTicks(s string) (time.Duration, bool) {
+	ticks, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || ticks == 0 {
+		return 0, false
+	}
+	return time.Duration(ticks) * 10 * time.Millisecond, true
+}
+
+// parseInt32Unsigned reads an Integer32 that agents overflow for large
+// disks, such as hrStorageSize, as its unsigned 32-bit value.
+func parseInt32Unsigned(s string) uint64 {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint64(uint32(v))
+}
+
+func percent(used, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return clampPercent(float64(used) / float64(total) * 100)
+}
+
+func clampPercent(p float64) float64 {
+	switch {
+	case p < 0:
+		return 0
+	case p > 100:
+		return 100
+	}
+	return p
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,543 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+//go:embed http_fingerprints.json
+var defaultHTTPRules []byte
+
+// maxHTTPBody caps how much of each page is read for matching.
+const maxHTTPBody = 256 << 10
+
+// HTTPRule identifies a web interface. Every condition that is set must hold;
+// conditions are regular expressions except Favicon, which lists favicon
+// hashes. Version, if set, is a pattern whose first group is searched for in
+// the headers, title and body.
+type HTTPRule struct {
+	Product    string            `json:"product"`
+	Vendor     string            `json:"vendor"`
+	DeviceType string            `json:"device_type"`
+	Path       string            `json:"path"` // page the rule looks at (default "/")
+	Title      string            `json:"title"`
+	Server     string            `json:"server"`
+	PoweredBy  string            `json:"powered_by"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Location   string            `json:"location"` // any URL in the redirect chain
+	Favicon    []int32           `json:"favicon"`
+	Version    string            `json:"version"`
+
+	title, server, poweredBy, body, location, version *regexp.Regexp
+	headers                                           map[string]*regexp.Regexp
+}
+
+// HTTPRuleSet holds the web fingerprint rules and the extra paths fetched for
+// them. Rules are tried in order and the first match wins.
+type HTTPRuleSet struct {
+	Paths []string   `json:"paths"`
+	Rules []HTTPRule `json:"rules"`
+}
+
+// HTTPFingerprint is what a web server revealed about itself.
+type HTTPFingerprint struct {
+	Host        string
+	Port        int
+	URL         string   // URL of the root page
+	Redirects   []string // URLs the root page redirected through, in order
+	Status      int
+	Server      string
+	PoweredBy   string
+	Title       string
+	FaviconHash int32 // Shodan-style mmh3 hash, valid when HasFavicon
+	HasFavicon  bool
+
+	Product    string
+	Vendor     string
+	DeviceType string
+	Version    string
+}
+
+// httpPage is one fetched path.
+type httpPage struct {
+	path      string
+	status    int
+	header    http.Header
+	title     string
+	icon      string // favicon link from the page, if any
+	body      string
+	finalURL  *url.URL
+	redirects []string
+}
+
+// DefaultHTTPRules returns the built-in web fingerprint rules.
+func DefaultHTTPRules() *HTTPRuleSet {
+	rs, err := ParseHTTPRules(defaultHTTPRules)
+	if err != nil {
+		panic("probe: invalid built-in HTTP rules: " + err.Error())
+	}
+	return rs
+}
+
+// ParseHTTPRules decodes and compiles a JSON rule set.
+func ParseHTTPRules(data []byte) (*HTTPRuleSet, error) {
+	var rs HTTPRuleSet
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("error parsing HTTP rules: %v", err)
+	}
+	for i := range rs.Rules {
+		if err := rs.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %v", i, rs.Rules[i].Product, err)
+		}
+	}
+	return &rs, nil
+}
+
+// LoadRuleFile reads additional rules from a JSON file with the same layout as
+// the built-in set. They take precedence over the existing ones.
+func (rs *HTTPRuleSet) LoadRuleFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	extra, err := ParseHTTPRules(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	rs.Paths = append(rs.Paths, extra.Paths...)
+	rs.Rules = append(extra.Rules, rs.Rules...)
+	return nil
+}
+
+func (r *HTTPRule) compile() error {
+	var err error
+	compile := func(pattern string) *regexp.Regexp {
+		if pattern == "" || err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		re, err = regexp.Compile(pattern)
+		return re
+	}
+	r.title = compile(r.Title)
+	r.server = compile(r.Server)
+	r.poweredBy = compile(r.PoweredBy)
+	r.body = compile(r.Body)
+	r.location = compile(r.Location)
+	r.version = compile(r.Version)
+	r.headers = make(map[string]*regexp.Regexp, len(r.Headers))
+	for name, pattern := range r.Headers {
+		r.headers[http.CanonicalHeaderKey(name)] = compile(pattern)
+	}
+	return err
+}
+
+// matches reports whether every condition of the rule holds for the page.
+func (r *HTTPRule) matches(p *httpPage, fp *HTTPFingerprint) bool {
+	want := r.Path
+	if want == "" {
+		want = "/"
+	}
+	if want != p.path {
+		return false
+	}
+	if r.title != nil && !r.title.MatchString(p.title) ||
+		r.server != nil && !r.server.MatchString(p.header.Get("Server")) ||
+		r.poweredBy != nil && !r.poweredBy.MatchString(p.header.Get("X-Powered-By")) ||
+		r.body != nil && !r.body.MatchString(p.body) {
+		return false
+	}
+	for name, re := range r.headers {
+		values, ok := p.header[name]
+		if !ok || !re.MatchString(strings.Join(values, ", ")) {
+			return false
+		}
+	}
+	if r.location != nil {
+		found := false
+		for _, u := range p.redirects {
+			if r.location.MatchString(u) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Favicon) > 0 {
+		found := false
+		for _, h := range r.Favicon {
+			if fp.HasFavicon && h == fp.FaviconHash {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// paths returns the paths to fetch: "/" first, then any the rules need.
+func (rs *HTTPRuleSet) paths() []string {
+	paths := []string{"/"}
+	for _, p := range rs.Paths {
+		paths = appendUnique(paths, p)
+	}
+	for _, r := range rs.Rules {
+		if r.Path != "" {
+			paths = appendUnique(paths, r.Path)
+		}
+	}
+	return paths
+}
+
+// Fingerprint fetches the root page and the rule paths from host:port and
+// matches them against the rules. Ports that normally speak TLS are tried
+// over HTTPS first, others over HTTP first; the other scheme is the fallback.
+func (rs *HTTPRuleSet) Fingerprint(ctx context.Context, host string, port int, timeout time.Duration) (*HTTPFingerprint, error) {
+	schemes := []string{"http", "https"}
+	if TLSPorts[port] {
+		schemes = []string{"https", "http"}
+	}
+
+	client := newHTTPClient(timeout)
+	defer client.CloseIdleConnections()
+
+	var root *httpPage
+	var base string
+	var err error
+	for _, scheme := range schemes {
+		base = scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+		root, err = fetchPage(ctx, client, base, "/")
+		if err == nil && !plainHTTPToTLSPort(root) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fp := &HTTPFingerprint{
+		Host:      host,
+		Port:      port,
+		URL:       base + "/",
+		Redirects: root.redirects,
+		Status:    root.status,
+		Server:    root.header.Get("Server"),
+		PoweredBy: root.header.Get("X-Powered-By"),
+		Title:     root.title,
+	}
+	fp.FaviconHash, fp.HasFavicon = fetchFavicon(ctx, client, root)
+
+	pages := []*httpPage{root}
+	for _, path := range rs.paths()[1:] {
+		if page, err := fetchPage(ctx, client, base, path); err == nil {
+			pages = append(pages, page)
+		}
+	}
+	for _, page := range pages {
+		for i := range rs.Rules {
+			r := &rs.Rules[i]
+			if !r.matches(page, fp) {
+				continue
+			}
+			fp.Product, fp.Vendor, fp.DeviceType = r.Product, r.Vendor, r.DeviceType
+			if r.version != nil {
+				fp.Version = findVersion(r.version, page)
+			}
+			return fp, nil
+		}
+	}
+	return fp, nil
+}
+
+// FingerprintPorts fingerprints several web ports of a host concurrently,
+// skipping ports that do not answer HTTP.
+func (rs *HTTPRuleSet) FingerprintPorts(ctx context.Context, host string, ports []int, timeout time.Duration) []HTTPFingerprint {
+	var mu sync.Mutex
+	var results []HTTPFingerprint
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 4)
+	for _, port := range ports {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			fp, err := rs.Fingerprint(ctx, host, port, timeout)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			results = append(results, *fp)
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+	return results
+}
+
+// Identified returns the first fingerprint that matched a rule, or nil.
+func Identified(fps []HTTPFingerprint) *HTTPFingerprint {
+	for i := range fps {
+		if fps[i].Product != "" {
+			return &fps[i]
+		}
+	}
+	return nil
+}
+
+// String renders a fingerprint as `8080 "Title" Server: nginx -> Product 1.2`.
+func (fp HTTPFingerprint) String() string {
+	parts := []string{strconv.Itoa(fp.Port)}
+	if fp.Title != "" {
+		parts = append(parts, strconv.Quote(fp.Title))
+	}
+	if fp.Server != "" {
+		parts = append(parts, "Server: "+fp.Server)
+	}
+	if fp.PoweredBy != "" {
+		parts = append(parts, "X-Powered-By: "+fp.PoweredBy)
+	}
+	if fp.HasFavicon {
+		parts = append(parts, fmt.Sprintf("favicon %d", fp.FaviconHash))
+	}
+	if fp.Product != "" {
+		parts = append(parts, "-> "+strings.TrimSpace(fp.Product+" "+fp.Version))
+	}
+	return strings.Join(parts, " ")
+}
+
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       (&net.Dialer{Timeout: timeout}).DialContext,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, // appliances use self-signed certificates
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Stay on the device: a redirect to another host, such as a
+			// vendor cloud portal, is recorded by fetchPage but not followed.
+			if from := via[0].URL.Hostname(); !strings.EqualFold(req.URL.Hostname(), from) && !sameHost(req.URL.Hostname(), from) {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 5 {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+// fetchPage GETs base+path, following redirects on the same host, and parses
+// the HTML.
+func fetchPage(ctx context.Context, client *http.Client, base, path string) (*httpPage, error) {
+	var redirects []string
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		redirects = append(redirects, req.URL.String())
+		return client.CheckRedirect(req, via)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; sentinel)")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	if err != nil && len(body) == 0 {
+		return nil, err
+	}
+
+	page := &httpPage{
+		path:      path,
+		status:    resp.StatusCode,
+		header:    resp.Header,
+		body:      string(body),
+		finalURL:  resp.Request.URL,
+		redirects: redirects,
+	}
+	page.title, page.icon = parseHTMLHead(page.body)
+	return page, nil
+}
+
+// plainHTTPToTLSPort spots the 400 servers send when plain HTTP reaches a TLS
+// listener, e.g. nginx's "The plain HTTP request was sent to HTTPS port".
+func plainHTTPToTLSPort(p *httpPage) bool {
+	return p.status == http.StatusBadRequest && p.finalURL.Scheme == "http" &&
+		strings.Contains(strings.ToLower(p.body), "https")
+}
+
+// parseHTMLHead returns the page title and favicon link.
+func parseHTMLHead(body string) (title, icon string) {
+	z := html.NewTokenizer(strings.NewReader(body))
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(title), icon
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "title":
+				inTitle = title == ""
+			case "link":
+				var rel, href string
+				for _, a := range tok.Attr {
+					switch strings.ToLower(a.Key) {
+					case "rel":
+						rel = strings.ToLower(a.Val)
+					case "href":
+						href = a.Val
+					}
+				}
+				if icon == "" && strings.Contains(rel, "icon") && href != "" {
+					icon = href
+				}
+			case "body":
+				// Everything of interest lives in <head>.
+				if title != "" {
+					return strings.TrimSpace(title), icon
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			if tok := z.Token(); tok.Data == "title" {
+				inTitle = false
+			}
+		}
+	}
+}
+
+// fetchFavicon downloads the page's favicon, or /favicon.ico, and hashes it.
+func fetchFavicon(ctx context.Context, client *http.Client, page *httpPage) (int32, bool) {
+	ref, err := url.Parse(page.icon)
+	if page.icon == "" || err != nil {
+		ref = &url.URL{Path: "/favicon.ico"}
+	}
+	target := page.finalURL.ResolveReference(ref)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return 0, false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	if err != nil || len(data) == 0 {
+		return 0, false
+	}
+	return FaviconHash(data), true
+}
+
+// findVersion searches the page headers, title and body for the first group
+// of the version pattern.
+func findVersion(re *regexp.Regexp, p *httpPage) string {
+	var sb strings.Builder
+	for name, values := range p.header {
+		for _, v := range values {
+			sb.WriteString(name + ": " + v + "\n")
+		}
+	}
+	for _, text := range []string{sb.String(), p.title, p.body} {
+		if m := re.FindStringSubmatch(text); len(m) > 1 {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// FaviconHash computes the favicon hash used by Shodan and similar search
+// engines: MurmurHash3 (x86, 32-bit, seed 0) of the base64 encoding wrapped
+// at 76 characters with a trailing newline, as Python's encodebytes does.
+func FaviconHash(data []byte) int32 {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var sb strings.Builder
+	for len(enc) > 76 {
+		sb.WriteString(enc[:76])
+		sb.WriteByte('\n')
+		enc = enc[76:]
+	}
+	sb.WriteString(enc)
+	sb.WriteByte('\n')
+	return int32(murmur3(sb.String()))
+}
+
+func murmur3(s string) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+	data := []byte(s)
+	var h uint32
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	tail := data[n*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,192 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testFavicon is 512 bytes, long enough that its base64 wraps over several
+// lines before hashing.
+var testFavicon = func() []byte {
+	b := make([]byte, 512)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}()
+
+// testFaviconHash is mmh3.hash(base64.encodebytes(testFavicon)), Shodan's
+// http.favicon.hash, from a reference MurmurHash3 implementation.
+const testFaviconHash = -1173581353
+
+// newRouterServer serves a router login page reached through two redirects.
+func newRouterServer(t *testing.T) (host string, port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/cgi-bin/luci/", http.StatusFound)
+	})
+	mux.HandleFunc("/cgi-bin/luci/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "uhttpd/2.0")
+		w.Header().Set("X-Powered-By", "PHP/8.1.2")
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Write([]byte(`<!DOCTYPE html><html><head>
+<link rel="stylesheet" href="/static/main.css">
+<link rel="shortcut icon" href="/static/fav.ico">
+<title> Router login </title></head>
+<body><p>Firmware 23.05.2</p></body></html>`))
+	})
+	mux.HandleFunc("/static/fav.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testFavicon)
+	})
+	mux.HandleFunc("/api/info", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"model":"NAS-100"}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ = strconv.Atoi(portStr)
+	return host, port
+}
+
+func TestFingerprint(t *testing.T) {
+	host, port := newRouterServer(t)
+	fp, err := DefaultHTTPRules().Fingerprint(context.Background(), host, port, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := "http://" + net.JoinHostPort(host, strconv.Itoa(port))
+	want := HTTPFingerprint{
+		Host:        host,
+		Port:        port,
+		URL:         base + "/",
+		Redirects:   []string{base + "/login", base + "/cgi-bin/luci/"},
+		Status:      http.StatusOK,
+		Server:      "uhttpd/2.0",
+		PoweredBy:   "PHP/8.1.2",
+		Title:       "Router login",
+		FaviconHash: testFaviconHash,
+		HasFavicon:  true,
+		// Only the redirect through /cgi-bin/luci identifies it
+		Product:    "OpenWrt LuCI",
+		Vendor:     "OpenWrt",
+		DeviceType: "router",
+	}
+	if !reflect.DeepEqual(*fp, want) {
+		t.Errorf("got  %+v\nwant %+v", *fp, want)
+	}
+}
+
+func TestFingerprintOffHostRedirect(t *testing.T) {
+	var portalHits int32
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&portalHits, 1)
+		w.Write([]byte(`<html><head><title>Cloud portal</title></head></html>`))
+	}))
+	t.Cleanup(portal.Close)
+	_, portalPort, _ := net.SplitHostPort(portal.Listener.Addr().String())
+	// Same listener, but a different host name from the device's address
+	portalURL := "http://localhost:" + portalPort + "/signin"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, portalURL, http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	fp, err := DefaultHTTPRules().Fingerprint(context.Background(), host, port, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + net.JoinHostPort(host, portStr)
+	if want := []string{base + "/login", portalURL}; !reflect.DeepEqual(fp.Redirects, want) {
+		t.Errorf("Redirects = %q, want %q", fp.Redirects, want)
+	}
+	if fp.Status != http.StatusFound || fp.Title != "" {
+		t.Errorf("got status %d, title %q; want the device's 302", fp.Status, fp.Title)
+	}
+	if n := atomic.LoadInt32(&portalHits); n != 0 {
+		t.Errorf("off-host redirect was followed (%d requests)", n)
+	}
+}
+
+func TestHTTPRuleMatching(t *testing.T) {
+	host, port := newRouterServer(t)
+
+	tests := []struct {
+		name    string
+		rule    string
+		match   bool
+		version string
+	}{
+		{"title", `{"title": "^Router"}`, true, ""},
+		{"title mismatch", `{"title": "^Switch"}`, false, ""},
+		{"server", `{"server": "^uhttpd/"}`, true, ""},
+		{"powered by", `{"powered_by": "^PHP/8"}`, true, ""},
+		{"powered by mismatch", `{"powered_by": "ASP\\.NET"}`, false, ""},
+		{"header", `{"headers": {"x-frame-options": "^SAMEORIGIN$"}}`, true, ""},
+		{"missing header", `{"headers": {"X-Generator": "."}}`, false, ""},
+		{"favicon", `{"favicon": [1, ` + strconv.Itoa(testFaviconHash) + `]}`, true, ""},
+		{"favicon mismatch", `{"favicon": [1]}`, false, ""},
+		{"redirect", `{"location": "/login$"}`, true, ""},
+		{"redirect mismatch", `{"location": "/admin"}`, false, ""},
+		{"every condition must hold", `{"title": "^Router", "server": "^nginx"}`, false, ""},
+		{"extra path", `{"path": "/api/info", "body": "\"model\":\"NAS-100\""}`, true, ""},
+		{"extra path mismatch", `{"path": "/api/info", "body": "NAS-200"}`, false, ""},
+		{"version", `{"title": "^Router", "version": "Firmware ([\\d.]+)"}`, true, "23.05.2"},
+		{"version from header", `{"server": "uhttpd", "version": "uhttpd/([\\d.]+)"}`, true, "2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := ParseHTTPRules([]byte(`{"rules": [` + tt.rule[:len(tt.rule)-1] + `, "product": "Test"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fp, err := rs.Fingerprint(context.Background(), host, port, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched := fp.Product == "Test"; matched != tt.match || fp.Version != tt.version {
+				t.Errorf("matched = %v version %q, want %v version %q", matched, fp.Version, tt.match, tt.version)
+			}
+		})
+	}
+}
+
+func TestFaviconHash(t *testing.T) {
+	if got := FaviconHash(testFavicon); got != testFaviconHash {
+		t.Errorf("FaviconHash = %d, want %d", got, testFaviconHash)
+	}
+
+	// Reference MurmurHash3 x86_32 values with seed 0
+	tests := []struct {
+		in   string
+		want uint32
+	}{
+		{"", 0},
+		{"hello", 0x248bfa47},
+		{"The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+	for _, tt := range tests {
+		if got := murmur3(tt.in); got != tt.want {
+			t.Errorf("murmur3(%q) = %#08x, want %#08x", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package probe
 
 import (
-	"fmt"
+	"log"
 	"net"
 	// "net/netip"
 	"time"
@@ -10,25 +10,30 @@ import (
 	"github.com/sofc-t/sentinel/domain/models"
 )
 
-// PingDevice sends an ICMP echo request using digineo/go-ping
+// PingDevice sends an ICMP or ICMPv6 echo request using digineo/go-ping.
+// Link-local IPv6 targets need a zone, e.g. "fe80::1%eth0".
 func PingDevice(deviceID, ipAddress string, timeout time.Duration) models.PingResult {
-	pinger, err := ping.New(ipAddress, "")
+	ip, err := net.ResolveIPAddr("ip", ipAddress)
 	if err != nil {
-		fmt.Printf("Error creating pinger for %s: %v\n", ipAddress, err)
+		log.Printf("[Ping] Error resolving IP address for %s: %v", ipAddress, err)
 		return *models.NewPingResult(deviceID, ipAddress, false, -1, time.Now().Unix())
 	}
-	defer pinger.Close()
-
 
-	ip, err := net.ResolveIPAddr("ip", ipAddress)
+	// Only open the raw socket for the address family being pinged
+	bind4, bind6 := "0.0.0.0", ""
+	if ip.IP.To4() == nil {
+		bind4, bind6 = "", "::"
+	}
+	pinger, err := ping.New(bind4, bind6)
 	if err != nil {
-		fmt.Printf("Error resolving IP address for %s: %v\n", ipAddress, err)
+		log.Printf("[Ping] Error creating pinger for %s: %v", ipAddress, err)
 		return *models.NewPingResult(deviceID, ipAddress, false, -1, time.Now().Unix())
 	}
+	defer pinger.Close()
 
 	rtt, err := pinger.Ping(ip, timeout)
 	if err != nil {
-		fmt.Printf("Ping failed for %s: %v\n", ipAddress, err)
+		log.Printf("[Ping] Ping failed for %s: %v", ipAddress, err)
 		return *models.NewPingResult(deviceID, ipAddress, false, -1, time.Now().Unix())
 	}
 
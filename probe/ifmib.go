@@ -0,0 +1,179 @@
+package probe
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// IF-MIB tables (RFC 2863).
+const (
+	oidIfEntry  = ".1.3.6.1.2.1.2.2.1"
+	oidIfXEntry = ".1.3.6.1.2.1.31.1.1.1"
+)
+
+// ifEntry columns.
+const (
+	ifColDescr       = 2
+	ifColType        = 3
+	ifColMtu         = 4
+	ifColSpeed       = 5
+	ifColPhysAddress = 6
+	ifColAdminStatus = 7
+	ifColOperStatus  = 8
+	ifColInOctets    = 10
+	ifColInUcast     = 11
+	ifColInDiscards  = 13
+	ifColInErrors    = 14
+	ifColOutOctets   = 16
+	ifColOutUcast    = 17
+	ifColOutDiscards = 19
+	ifColOutErrors   = 20
+)
+
+// ifXEntry columns.
+const (
+	ifXColName           = 1
+	ifXColInMulticast    = 2
+	ifXColInBroadcast    = 3
+	ifXColOutMulticast   = 4
+	ifXColOutBroadcast   = 5
+	ifXColHCInOctets     = 6
+	ifXColHCInUcast      = 7
+	ifXColHCInMulticast  = 8
+	ifXColHCInBroadcast  = 9
+	ifXColHCOutOctets    = 10
+	ifXColHCOutUcast     = 11
+	ifXColHCOutMulticast = 12
+	ifXColHCOutBroadcast = 13
+	ifXColHighSpeed      = 15
+	ifXColAlias          = 18
+)
+
+// IfTypeSoftwareLoopback is the IANAifType of loopback interfaces.
+const IfTypeSoftwareLoopback = 24
+
+// ifStatusNames maps ifAdminStatus/ifOperStatus values to names.
+var ifStatusNames = map[string]string{
+	"1": "up",
+	"2": "down",
+	"3": "testing",
+	"4": "unknown",
+	"5": "dormant",
+	"6": "notPresent",
+	"7": "lowerLayerDown",
+}
+
+// FetchInterfaces walks ifTable and ifXTable and returns one interface per
+// ifIndex, ordered by ifIndex. Counters come from the 64-bit ifXTable columns
+// when the agent has them (SNMPv2c/v3 on most gear) and from the 32-bit
+// ifTable ones otherwise.
+func FetchInterfaces(cfg SNMPConfig) ([]models.Interface, error) {
+	ifTable, err := walkTable(cfg, oidIfEntry)
+	if err != nil {
+		return nil, err
+	}
+	ifXTable, err := walkTable(cfg, oidIfXEntry)
+	if err != nil {
+		// Agents without IF-MIB v2 still have a usable ifTable
+		log.Printf("[IF-MIB] ifXTable walk failed for %s: %v", cfg.Target, err)
+	}
+
+	var ifaces []models.Interface
+	for index, row := range ifTable {
+		ifIndex, err := strconv.Atoi(index)
+		if err != nil {
+			continue
+		}
+		ifaces = append(ifaces, interfaceFromRows(ifIndex, row, ifXTable[index]))
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].GetIfIndex() < ifaces[j].GetIfIndex() })
+	return ifaces, nil
+}
+
+// interfaceFromRows builds an interface from its ifEntry and ifXEntry rows;
+// xrow may be nil.
+func interfaceFromRows(ifIndex int, row, xrow map[int]string) models.Interface {
+	var iface models.Interface
+	iface.SetIfIndex(ifIndex)
+
+	name := xrow[ifXColName]
+	if name == "" {
+		name = row[ifColDescr]
+	}
+	if name == "" {
+		name = strconv.Itoa(ifIndex)
+	}
+	iface.SetID(name)
+	iface.SetName(name)
+	iface.SetDescription(row[ifColDescr])
+	iface.SetAlias(xrow[ifXColAlias])
+	iface.SetIfType(atoiOrZero(row[ifColType]))
+	iface.SetMTU(atoiOrZero(row[ifColMtu]))
+	iface.SetAdminStatus(ifStatusNames[row[ifColAdminStatus]])
+	iface.SetStatus(ifStatusNames[row[ifColOperStatus]])
+	if mac := parseOctets(row[ifColPhysAddress]); len(mac) == 6 {
+		iface.SetMACAddress(net.HardwareAddr(mac).String())
+	}
+
+	// ifSpeed saturates at 4294967295; ifHighSpeed is in Mbit/s
+	speed := parseUint(row[ifColSpeed])
+	if high := parseUint(xrow[ifXColHighSpeed]); high*1000000 > speed {
+		speed = high * 1000000
+	}
+	iface.SetSpeedBps(speed)
+	iface.SetSpeed(formatBps(speed))
+
+	counters := models.InterfaceCounters{
+		InOctets:         parseUint(row[ifColInOctets]),
+		OutOctets:        parseUint(row[ifColOutOctets]),
+		InUcastPkts:      parseUint(row[ifColInUcast]),
+		OutUcastPkts:     parseUint(row[ifColOutUcast]),
+		InMulticastPkts:  parseUint(xrow[ifXColInMulticast]),
+		OutMulticastPkts: parseUint(xrow[ifXColOutMulticast]),
+		InBroadcastPkts:  parseUint(xrow[ifXColInBroadcast]),
+		OutBroadcastPkts: parseUint(xrow[ifXColOutBroadcast]),
+		InErrors:         parseUint(row[ifColInErrors]),
+		OutErrors:        parseUint(row[ifColOutErrors]),
+		InDiscards:       parseUint(row[ifColInDiscards]),
+		OutDiscards:      parseUint(row[ifColOutDiscards]),
+	}
+	if _, ok := xrow[ifXColHCInOctets]; ok {
+		counters.HighCapacity = true
+		counters.InOctets = parseUint(xrow[ifXColHCInOctets])
+		counters.OutOctets = parseUint(xrow[ifXColHCOutOctets])
+		counters.InUcastPkts = parseUint(xrow[ifXColHCInUcast])
+		counters.OutUcastPkts = parseUint(xrow[ifXColHCOutUcast])
+		counters.InMulticastPkts = parseUint(xrow[ifXColHCInMulticast])
+		counters.OutMulticastPkts = parseUint(xrow[ifXColHCOutMulticast])
+		counters.InBroadcastPkts = parseUint(xrow[ifXColHCInBroadcast])
+		counters.OutBroadcastPkts = parseUint(xrow[ifXColHCOutBroadcast])
+	}
+	iface.SetCounters(counters)
+	return iface
+}
+
+// formatBps renders a bandwidth the way mauTypeSpeed does, e.g. "10Gbps".
+func formatBps(bps uint64) string {
+	switch {
+	case bps == 0:
+		return ""
+	case bps >= 1000000000 && bps%1000000000 == 0:
+		return fmt.Sprintf("%dGbps", bps/1000000000)
+	case bps >= 1000000 && bps%1000000 == 0:
+		return fmt.Sprintf("%dMbps", bps/1000000)
+	case bps >= 1000 && bps%1000 == 0:
+		return fmt.Sprintf("%dkbps", bps/1000)
+	default:
+		return fmt.Sprintf("%dbps", bps)
+	}
+}
+
+func parseUint(s string) uint64 {
+	v, _ := strconv.ParseUint(s, 10, 64)
+	return v
+}
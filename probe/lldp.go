@@ -0,0 +1,459 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// LLDPNeighbor holds the TLVs decoded from a single LLDPDU.
+type LLDPNeighbor struct {
+	ChassisID       string
+	ChassisSubtype  string
+	PortID          string
+	PortSubtype     string
+	PortDescription string
+	SysName         string
+	SysDescription  string
+	Capabilities    []string // enabled system capabilities
+	MgmtAddress     string
+	TTL             uint16
+	PortVLAN        int
+	VLANNames       map[int]string
+	MAUType         string
+	AutoNegEnabled  bool
+	SourceMAC       string
+	SeenOn          string // local interface the frame was received on
+}
+
+// ErrNotLLDP is returned by DecodeLLDP for packets without an LLDP layer.
+var ErrNotLLDP = errors.New("packet does not contain an LLDPDU")
+
+// DecodeLLDP extracts the mandatory and optional LLDP TLVs from a packet.
+func DecodeLLDP(packet gopacket.Packet) (*LLDPNeighbor, error) {
+	lldpLayer := packet.Layer(layers.LayerTypeLinkLayerDiscovery)
+	if lldpLayer == nil {
+		return nil, ErrNotLLDP
+	}
+	lldp := lldpLayer.(*layers.LinkLayerDiscovery)
+
+	n := &LLDPNeighbor{
+		ChassisID:      formatChassisID(lldp.ChassisID),
+		ChassisSubtype: lldp.ChassisID.Subtype.String(),
+		PortID:         formatPortID(lldp.PortID),
+		PortSubtype:    lldp.PortID.Subtype.String(),
+		TTL:            lldp.TTL,
+	}
+	if n.ChassisID == "" {
+		return nil, fmt.Errorf("LLDPDU without chassis ID")
+	}
+
+	if eth, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok {
+		n.SourceMAC = eth.SrcMAC.String()
+	}
+
+	infoLayer := packet.Layer(layers.LayerTypeLinkLayerDiscoveryInfo)
+	if infoLayer == nil {
+		return n, nil
+	}
+	info := infoLayer.(*layers.LinkLayerDiscoveryInfo)
+
+	n.PortDescription = info.PortDescription
+	n.SysName = info.SysName
+	n.SysDescription = info.SysDescription
+	n.Capabilities = capabilityNames(info.SysCapabilities.EnabledCap)
+	n.MgmtAddress = formatMgmtAddress(info.MgmtAddress)
+
+	if dot1, err := decode8021(info); err == nil {
+		n.PortVLAN = int(dot1.PVID)
+		for _, v := range dot1.VLANNames {
+			if n.VLANNames == nil {
+				n.VLANNames = make(map[int]string)
+			}
+			n.VLANNames[int(v.ID)] = v.Name
+		}
+	} else {
+		log.Printf("[LLDP] Skipping malformed 802.1 TLV from %s: %v", n.ChassisID, err)
+	}
+
+	if dot3, err := decode8023(info); err == nil {
+		n.MAUType = mauTypeName(dot3.MACPHYConfigStatus.MAUType)
+		n.AutoNegEnabled = dot3.MACPHYConfigStatus.AutoNegEnabled
+	} else {
+		log.Printf("[LLDP] Skipping malformed 802.3 TLV from %s: %v", n.ChassisID, err)
+	}
+
+	return n, nil
+}
+
+// decode8021 and decode8023 turn the panics gopacket raises on some short
+// organizationally specific TLVs (a VLAN name TLV without a name) into
+// errors, so one bad frame cannot stop a capture.
+func decode8021(info *layers.LinkLayerDiscoveryInfo) (dot1 layers.LLDPInfo8021, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed TLV: %v", r)
+		}
+	}()
+	return info.Decode8021()
+}
+
+func decode8023(info *layers.LinkLayerDiscoveryInfo) (dot3 layers.LLDPInfo8023, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed TLV: %v", r)
+		}
+	}()
+	return info.Decode8023()
+}
+
+// DeviceType maps the enabled LLDP capabilities onto a device type.
+func (n *LLDPNeighbor) DeviceType() string {
+	caps := make(map[string]bool, len(n.Capabilities))
+	for _, c := range n.Capabilities {
+		caps[c] = true
+	}
+	switch {
+	case caps["router"]:
+		return "router"
+	case caps["bridge"]:
+		return "switch"
+	case caps["wlan-ap"]:
+		return "access-point"
+	case caps["phone"]:
+		return "phone"
+	case caps["docsis"]:
+		return "cable-modem"
+	case caps["station"]:
+		return "host"
+	default:
+		return "unknown"
+	}
+}
+
+// Interface returns the advertising port as a models.Interface.
+func (n *LLDPNeighbor) Interface() models.Interface {
+	var iface models.Interface
+	iface.SetID(n.PortID)
+	iface.SetDeviceID(n.ChassisID)
+	iface.SetName(n.PortID)
+	iface.SetDescription(n.PortDescription)
+	iface.SetVLANID(n.PortVLAN)
+	iface.SetMAUType(n.MAUType)
+	iface.SetSpeed(mauTypeSpeed(n.MAUType))
+	iface.SetStatus("up")
+	if n.PortSubtype == layers.LLDPPortIDSubtypeMACAddr.String() {
+		iface.SetMACAddress(n.PortID)
+	} else if n.SourceMAC != "" {
+		iface.SetMACAddress(n.SourceMAC)
+	}
+	return iface
+}
+
+// ToDevice converts the neighbor into a models.Device.
+func (n *LLDPNeighbor) ToDevice() *models.Device {
+	mac := ""
+	if n.ChassisSubtype == layers.LLDPChassisIDSubTypeMACAddr.String() {
+		mac = n.ChassisID
+	}
+	device := models.NewDevice(models.DeviceConfig{
+		Hostname:            n.SysName,
+		IPAddress:           n.MgmtAddress,
+		DeviceType:          n.DeviceType(),
+		Status:              "active",
+		MonitoringProtocols: []string{"LLDP"},
+		Interfaces:          []models.Interface{n.Interface()},
+		MACAddress:          mac,
+		ChassisID:           n.ChassisID,
+		Description:         n.SysDescription,
+	})
+	return device
+}
+
+// CaptureLLDP listens on an interface for LLDP frames and returns one device per chassis ID.
+func CaptureLLDP(interfaceName string, captureTimeout time.Duration) ([]models.Device, error) {
+	neighbors, err := CaptureLLDPNeighbors(interfaceName, captureTimeout)
+	if err != nil {
+		return nil, err
+	}
+	devices := LLDPDevices(neighbors)
+	log.Printf("[LLDP] Capture finished. Found %d device(s).\n", len(devices))
+	return devices, nil
+}
+
+// CaptureLLDPNeighbors listens on an interface and returns every decoded LLDPDU.
+func CaptureLLDPNeighbors(interfaceName string, captureTimeout time.Duration) ([]LLDPNeighbor, error) {
+	handle, err := pcap.OpenLive(interfaceName, 1600, true, 100*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("error opening interface %s: %v", interfaceName, err)
+	}
+	defer handle.Close()
+
+	if err := handle.SetBPFFilter("ether proto 0x88cc"); err != nil {
+		return nil, fmt.Errorf("error setting BPF filter: %v", err)
+	}
+
+	neighbors := readLLDP(gopacket.NewPacketSource(handle, handle.LinkType()), time.After(captureTimeout))
+	for i := range neighbors {
+		neighbors[i].SeenOn = interfaceName
+	}
+	return neighbors, nil
+}
+
+// ReadLLDPFile decodes the LLDP frames stored in a pcap or pcapng file. The
+// file is read without libpcap, so captures can be decoded on any host.
+func ReadLLDPFile(path string) ([]LLDPNeighbor, error) {
+	f, source, err := openCaptureFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLLDP(source, nil), nil
+}
+
+// openCaptureFile opens a pcap or pcapng file with the pure-Go readers. The
+// caller closes the file once the packet source is drained.
+func openCaptureFile(path string) (*os.File, *gopacket.PacketSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening pcap file %s: %v", path, err)
+	}
+	r, pcapErr := pcapgo.NewReader(f)
+	if pcapErr == nil {
+		return f, gopacket.NewPacketSource(r, r.LinkType()), nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	ng, ngErr := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	if ngErr != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("error reading pcap file %s: not pcap (%v) nor pcapng (%v)", path, pcapErr, ngErr)
+	}
+	return f, gopacket.NewPacketSource(ng, ng.LinkType()), nil
+}
+
+// LLDPDevices folds neighbors into devices, deduplicated by chassis ID.
+// Every distinct port advertised by a chassis becomes one of its interfaces.
+func LLDPDevices(neighbors []LLDPNeighbor) []models.Device {
+	var devices []models.Device
+	index := make(map[string]int)
+	ports := make(map[string]bool)
+
+	for i := range neighbors {
+		n := &neighbors[i]
+		portKey := n.ChassisID + "|" + n.PortID
+
+		pos, seen := index[n.ChassisID]
+		if !seen {
+			index[n.ChassisID] = len(devices)
+			devices = append(devices, *n.ToDevice())
+			ports[portKey] = true
+			continue
+		}
+
+		device := &devices[pos]
+		if !ports[portKey] {
+			ports[portKey] = true
+			device.SetInterfaces(append(device.GetInterfaces(), n.Interface()))
+		}
+		// Later frames may carry optional TLVs the first one lacked.
+		if device.GetHostname() == "" {
+			device.SetHostname(n.SysName)
+		}
+		if device.GetIPAddress() == "" {
+			device.SetIPAddress(n.MgmtAddress)
+		}
+		if device.GetDescription() == "" {
+			device.SetDescription(n.SysDescription)
+		}
+		if device.GetDeviceType() == "unknown" {
+			device.SetDeviceType(n.DeviceType())
+		}
+	}
+	return devices
+}
+
+// readLLDP drains a packet source until it closes or timeout fires.
+// A nil timeout reads until the source is exhausted.
+func readLLDP(source *gopacket.PacketSource, timeout <-chan time.Time) []LLDPNeighbor {
+	var neighbors []LLDPNeighbor
+	packets := source.Packets()
+
+LOOP:
+	for {
+		select {
+		case packet, ok := <-packets:
+			if !ok {
+				break LOOP
+			}
+			if packet == nil {
+				continue
+			}
+			n, err := DecodeLLDP(packet)
+			if err != nil {
+				if !errors.Is(err, ErrNotLLDP) {
+					log.Printf("[LLDP] Dropping frame: %v", err)
+				}
+				continue
+			}
+			log.Printf("[LLDP] Neighbor %s (%s) on port %s", n.ChassisID, n.SysName, n.PortID)
+			neighbors = append(neighbors, *n)
+
+		case <-timeout:
+			log.Println("[LLDP] Timeout reached, finishing capture.")
+			break LOOP
+		}
+	}
+	return neighbors
+}
+
+func formatChassisID(c layers.LLDPChassisID) string {
+	switch c.Subtype {
+	case layers.LLDPChassisIDSubTypeMACAddr:
+		if len(c.ID) == 6 {
+			return net.HardwareAddr(c.ID).String()
+		}
+	case layers.LLDPChassisIDSubTypeNetworkAddr:
+		if addr := formatNetworkAddr(c.ID); addr != "" {
+			return addr
+		}
+	}
+	return printableID(c.ID)
+}
+
+func formatPortID(p layers.LLDPPortID) string {
+	switch p.Subtype {
+	case layers.LLDPPortIDSubtypeMACAddr:
+		if len(p.ID) == 6 {
+			return net.HardwareAddr(p.ID).String()
+		}
+	case layers.LLDPPortIDSubtypeNetworkAddr:
+		if addr := formatNetworkAddr(p.ID); addr != "" {
+			return addr
+		}
+	}
+	return printableID(p.ID)
+}
+
+// formatNetworkAddr decodes an IANA address family byte followed by the address.
+func formatNetworkAddr(b []byte) string {
+	if len(b) < 1 {
+		return ""
+	}
+	switch layers.IANAAddressFamily(b[0]) {
+	case layers.IANAAddressFamilyIPV4:
+		if len(b) == 1+net.IPv4len {
+			return net.IP(b[1:]).String()
+		}
+	case layers.IANAAddressFamilyIPV6:
+		if len(b) == 1+net.IPv6len {
+			return net.IP(b[1:]).String()
+		}
+	}
+	return ""
+}
+
+func formatMgmtAddress(m layers.LLDPMgmtAddress) string {
+	switch m.Subtype {
+	case layers.IANAAddressFamilyIPV4, layers.IANAAddressFamilyIPV6:
+		if len(m.Address) == net.IPv4len || len(m.Address) == net.IPv6len {
+			return net.IP(m.Address).String()
+		}
+	}
+	return ""
+}
+
+// printableID renders locally assigned IDs as text, falling back to hex.
+func printableID(b []byte) string {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Sprintf("%x", b)
+		}
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func capabilityNames(c layers.LLDPCapabilities) []string {
+	var names []string
+	add := func(enabled bool, name string) {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	add(c.Other, "other")
+	add(c.Repeater, "repeater")
+	add(c.Bridge, "bridge")
+	add(c.WLANAP, "wlan-ap")
+	add(c.Router, "router")
+	add(c.Phone, "phone")
+	add(c.DocSis, "docsis")
+	add(c.StationOnly, "station")
+	add(c.CVLAN, "c-vlan")
+	add(c.SVLAN, "s-vlan")
+	add(c.TMPR, "tpmr")
+	return names
+}
+
+var mauTypeNames = map[uint16]string{
+	layers.LLDPMAUType10BaseT:       "10BaseT",
+	layers.LLDPMAUType10BaseT_HD:    "10BaseT_HD",
+	layers.LLDPMAUType10BaseT_FD:    "10BaseT_FD",
+	layers.LLDPMAUType100BaseTX_HD:  "100BaseTX_HD",
+	layers.LLDPMAUType100BaseTX_FD:  "100BaseTX_FD",
+	layers.LLDPMAUType100BaseFX_HD:  "100BaseFX_HD",
+	layers.LLDPMAUType100BaseFX_FD:  "100BaseFX_FD",
+	layers.LLDPMAUType1000BaseX_HD:  "1000BaseX_HD",
+	layers.LLDPMAUType1000BaseX_FD:  "1000BaseX_FD",
+	layers.LLDPMAUType1000BaseLX_HD: "1000BaseLX_HD",
+	layers.LLDPMAUType1000BaseLX_FD: "1000BaseLX_FD",
+	layers.LLDPMAUType1000BaseSX_HD: "1000BaseSX_HD",
+	layers.LLDPMAUType1000BaseSX_FD: "1000BaseSX_FD",
+	layers.LLDPMAUType1000BaseT_HD:  "1000BaseT_HD",
+	layers.LLDPMAUType1000BaseT_FD:  "1000BaseT_FD",
+	layers.LLDPMAUType10GBaseX:      "10GBaseX",
+	layers.LLDPMAUType10GBaseR:      "10GBaseR",
+	layers.LLDPMAUType10GBaseER:     "10GBaseER",
+	layers.LLDPMAUType10GBaseLR:     "10GBaseLR",
+	layers.LLDPMAUType10GBaseSR:     "10GBaseSR",
+	layers.LLDPMAUType10GBaseCX4:    "10GBaseCX4",
+	layers.LLDPMAUType10GBaseT:      "10GBaseT",
+	layers.LLDPMAUType10GBaseLRM:    "10GBaseLRM",
+}
+
+func mauTypeName(t uint16) string {
+	if t == layers.LLDPMAUTypeUnknown {
+		return ""
+	}
+	if name, ok := mauTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("mau-%d", t)
+}
+
+// mauTypeSpeed derives the link speed from a MAU type name.
+func mauTypeSpeed(mau string) string {
+	switch {
+	case strings.HasPrefix(mau, "10GBase"):
+		return "10Gbps"
+	case strings.HasPrefix(mau, "1000Base"):
+		return "1Gbps"
+	case strings.HasPrefix(mau, "100Base"):
+		return "100Mbps"
+	case strings.HasPrefix(mau, "10Base"):
+		return "10Mbps"
+	}
+	return ""
+}
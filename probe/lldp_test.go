@@ -0,0 +1,154 @@
+package probe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLLDPFile(t *testing.T) {
+	neighbors, err := ReadLLDPFile("testdata/lldp.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CDP frame in the capture is not LLDP and is skipped
+	want := []LLDPNeighbor{
+		{
+			ChassisID:       "00:1b:54:aa:bb:00",
+			ChassisSubtype:  "MAC Address",
+			PortID:          "Gi1/0/1",
+			PortSubtype:     "Interface Name",
+			PortDescription: "uplink to core",
+			SysName:         "sw-access-1",
+			SysDescription:  "Cisco IOS Software, C2960X",
+			Capabilities:    []string{"bridge"},
+			MgmtAddress:     "192.0.2.10",
+			TTL:             120,
+			PortVLAN:        10,
+			VLANNames:       map[int]string{10: "users"},
+			MAUType:         "1000BaseT_FD",
+			AutoNegEnabled:  true,
+			SourceMAC:       "00:1b:54:aa:bb:81",
+		},
+		{
+			ChassisID:      "00:1b:54:aa:bb:00",
+			ChassisSubtype: "MAC Address",
+			PortID:         "Gi1/0/2",
+			PortSubtype:    "Interface Name",
+			TTL:            120,
+			SourceMAC:      "00:1b:54:aa:bb:82",
+		},
+		{
+			ChassisID:      "router-a",
+			ChassisSubtype: "Local",
+			PortID:         "00:1b:54:cc:dd:01",
+			PortSubtype:    "MAC Address",
+			SysName:        "rtr-1",
+			Capabilities:   []string{"router"},
+			MgmtAddress:    "2001:db8::1",
+			TTL:            120,
+			SourceMAC:      "00:1b:54:cc:dd:01",
+		},
+	}
+	if !reflect.DeepEqual(neighbors, want) {
+		t.Errorf("ReadLLDPFile:\n got %+v\nwant %+v", neighbors, want)
+	}
+}
+
+func TestReadLLDPFileMalformed(t *testing.T) {
+	neighbors, err := ReadLLDPFile("testdata/lldp_malformed.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A TLV running past the end of the frame, a missing End TLV and a blank
+	// chassis ID drop the frame; bad optional TLVs only lose their own data.
+	want := []LLDPNeighbor{
+		{
+			ChassisID:      "bad-vlan",
+			ChassisSubtype: "Local",
+			PortID:         "eth0",
+			PortSubtype:    "Interface Name",
+			SysName:        "bad-vlan",
+			TTL:            120,
+			MAUType:        "100BaseTX_FD",
+			AutoNegEnabled: true,
+			SourceMAC:      "00:1b:54:aa:bb:93",
+		},
+		{
+			ChassisID:      "bad-power",
+			ChassisSubtype: "Local",
+			PortID:         "eth0",
+			PortSubtype:    "Interface Name",
+			SysName:        "bad-power",
+			TTL:            120,
+			PortVLAN:       30,
+			SourceMAC:      "00:1b:54:aa:bb:94",
+		},
+		{
+			ChassisID:      "short-caps",
+			ChassisSubtype: "Local",
+			PortID:         "eth0",
+			PortSubtype:    "Interface Name",
+			SysName:        "short-caps",
+			TTL:            120,
+			SourceMAC:      "00:1b:54:aa:bb:95",
+		},
+	}
+	if !reflect.DeepEqual(neighbors, want) {
+		t.Errorf("ReadLLDPFile:\n got %+v\nwant %+v", neighbors, want)
+	}
+}
+
+func TestReadLLDPFileMissing(t *testing.T) {
+	if _, err := ReadLLDPFile("testdata/does-not-exist.pcap"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestLLDPDevices(t *testing.T) {
+	neighbors, err := ReadLLDPFile("testdata/lldp.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := LLDPDevices(neighbors)
+	if len(devices) != 2 {
+		t.Fatalf("got %d devices, want 2", len(devices))
+	}
+
+	tests := []struct {
+		hostname, ip, mac, chassis, deviceType string
+		ports                                  []string
+	}{
+		{"sw-access-1", "192.0.2.10", "00:1b:54:aa:bb:00", "00:1b:54:aa:bb:00", "switch", []string{"Gi1/0/1", "Gi1/0/2"}},
+		{"rtr-1", "2001:db8::1", "", "router-a", "router", []string{"00:1b:54:cc:dd:01"}},
+	}
+	for i, tt := range tests {
+		d := devices[i]
+		if d.GetHostname() != tt.hostname || d.GetIPAddress() != tt.ip || d.GetMACAddress() != tt.mac ||
+			d.GetChassisID() != tt.chassis || d.GetDeviceType() != tt.deviceType {
+			t.Errorf("device %d = %s/%s/%s/%s/%s, want %s/%s/%s/%s/%s", i,
+				d.GetHostname(), d.GetIPAddress(), d.GetMACAddress(), d.GetChassisID(), d.GetDeviceType(),
+				tt.hostname, tt.ip, tt.mac, tt.chassis, tt.deviceType)
+		}
+		var ports []string
+		for _, iface := range d.GetInterfaces() {
+			ports = append(ports, iface.GetName())
+		}
+		if !reflect.DeepEqual(ports, tt.ports) {
+			t.Errorf("device %d ports = %v, want %v", i, ports, tt.ports)
+		}
+	}
+}
+
+func TestReadLLDPFileNotACapture(t *testing.T) {
+	_, err := ReadLLDPFile("lldp.go")
+	if err == nil {
+		t.Fatal("expected an error for a file that is not a capture")
+	}
+	// Both readers' complaints are reported
+	if msg := err.Error(); !strings.Contains(msg, "pcap (") || !strings.Contains(msg, "pcapng (") {
+		t.Errorf("error %q does not carry both reader errors", msg)
+	}
+}
@@ -0,0 +1,233 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// LLMNR (RFC 4795) multicast groups; port 5355 for both queries and replies.
+var (
+	llmnrGroup4 = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 252), Port: 5355}
+	llmnrGroup6 = &net.UDPAddr{IP: net.ParseIP("ff02::1:3"), Port: 5355}
+)
+
+// LLMNRResolve resolves a single-label host name by multicasting A and AAAA
+// queries on the local link, returning every address that answered.
+func LLMNRResolve(name string, timeout time.Duration) ([]string, error) {
+	var addrs []string
+	var lastErr error
+	for _, group := range []*net.UDPAddr{llmnrGroup4, llmnrGroup6} {
+		network := "udp4"
+		if group.IP.To4() == nil {
+			network = "udp6"
+		}
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			answers, err := llmnrQuery(network, group, name, qtype, timeout, true)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			for _, a := range answers {
+				addrs = appendUnique(addrs, a)
+			}
+		}
+	}
+	if len(addrs) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return addrs, nil
+}
+
+// LLMNRReverse looks up the host name of an address. Reverse queries are sent
+// by unicast to the address itself (RFC 4795 section 2.4).
+func LLMNRReverse(ip string, timeout time.Duration) (string, error) {
+	addr := net.ParseIP(stripZone(ip))
+	if addr == nil {
+		return "", fmt.Errorf("invalid address %q", ip)
+	}
+	arpa, err := reverseName(addr)
+	if err != nil {
+		return "", err
+	}
+
+	network := "udp4"
+	if addr.To4() == nil {
+		network = "udp6"
+	}
+	_, zone, _ := strings.Cut(ip, "%")
+	names, err := llmnrQuery(network, &net.UDPAddr{IP: addr, Port: 5355, Zone: zone}, arpa, dnsmessage.TypePTR, timeout, false)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("LLMNR: no name for %s", ip)
+	}
+	return names[0], nil
+}
+
+// CaptureLLMNR reverse-resolves a list of IPs and returns Device objects for
+// the hosts that answered.
+func CaptureLLMNR(ips []string) []models.Device {
+	devices := []models.Device{}
+	for _, ip := range ips {
+		name, err := LLMNRReverse(ip, 500*time.Millisecond)
+		if err != nil {
+			continue
+		}
+		device := models.NewDevice(models.DeviceConfig{
+			Hostname:            name,
+			IPAddress:           ip,
+			DeviceType:          "unknown",
+			Status:              "active",
+			MonitoringProtocols: []string{"LLMNR"},
+		})
+		devices = append(devices, *device)
+	}
+	return devices
+}
+
+// llmnrQuery sends one question and collects the answers until the timeout.
+// Multicast queries gather replies from every responder; unicast queries
+// return after the first.
+func llmnrQuery(network string, dst *net.UDPAddr, name string, qtype dnsmessage.Type, timeout time.Duration, multicast bool) ([]string, error) {
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, fmt.Errorf("LLMNR listen: %v", err)
+	}
+	defer conn.Close()
+
+	id := uint16(time.Now().UnixNano())
+	query, err := buildLLMNRQuery(id, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, dst); err != nil {
+		return nil, fmt.Errorf("LLMNR write %s: %v", dst, err)
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var answers []string
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return answers, nil
+			}
+			return answers, fmt.Errorf("LLMNR read: %v", err)
+		}
+		got, err := parseLLMNRResponse(buf[:n], id, qtype)
+		if err != nil {
+			continue
+		}
+		for _, a := range got {
+			answers = appendUnique(answers, a)
+		}
+		if !multicast && len(answers) > 0 {
+			return answers, nil
+		}
+	}
+}
+
+func buildLLMNRQuery(id uint16, name string, qtype dnsmessage.Type) ([]byte, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("LLMNR name %q: %v", name, err)
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// parseLLMNRResponse returns the A/AAAA addresses or PTR names answering the
+// query with the given ID.
+func parseLLMNRResponse(b []byte, id uint16, qtype dnsmessage.Type) ([]string, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil {
+		return nil, err
+	}
+	if !h.Response || h.ID != id {
+		return nil, errors.New("not a response to our query")
+	}
+	if h.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("rcode %v", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for {
+		ah, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		if ah.Type != qtype {
+			if err := p.SkipAnswer(); err != nil {
+				return out, err
+			}
+			continue
+		}
+		switch ah.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return out, err
+			}
+			out = append(out, net.IP(r.A[:]).String())
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return out, err
+			}
+			out = append(out, net.IP(r.AAAA[:]).String())
+		case dnsmessage.TypePTR:
+			r, err := p.PTRResource()
+			if err != nil {
+				return out, err
+			}
+			out = append(out, strings.TrimSuffix(r.PTR.String(), "."))
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return out, err
+			}
+		}
+	}
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name for an address.
+func reverseName(ip net.IP) (string, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0]), nil
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return "", fmt.Errorf("invalid address %v", ip)
+	}
+	const hexDigits = "0123456789abcdef"
+	var sb strings.Builder
+	for i := len(ip16) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[ip16[i]&0x0f])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[ip16[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa")
+	return sb.String(), nil
+}
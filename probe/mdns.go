@@ -2,47 +2,267 @@ package probe
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/grandcat/zeroconf"
 	"github.com/sofc-t/sentinel/domain/models"
 )
 
-// CaptureMDNS discovers devices broadcasting mDNS/Bonjour services
+// mdnsMetaQuery is the DNS-SD service type enumeration name (RFC 6763 section 9).
+const mdnsMetaQuery = "_services._dns-sd._udp"
+
+// MDNSService is one resolved DNS-SD service instance.
+type MDNSService struct {
+	Instance string // e.g. "Office Printer"
+	Type     string // e.g. "_ipp._tcp"
+	Port     int
+	Text     map[string]string // TXT record key/value pairs
+}
+
+// MDNSHost groups every service a host advertises.
+type MDNSHost struct {
+	HostName string // e.g. "printer.local"
+	IPv4     []string
+	IPv6     []string
+	Services []MDNSService
+}
+
+// CaptureMDNS discovers devices advertising mDNS/Bonjour services.
 func CaptureMDNS(timeout time.Duration) ([]models.Device, error) {
-	resolver, err := zeroconf.NewResolver(nil)
+	hosts, err := BrowseMDNS(timeout)
 	if err != nil {
 		return nil, err
 	}
+	devices := make([]models.Device, 0, len(hosts))
+	for i := range hosts {
+		devices = append(devices, *hosts[i].ToDevice())
+	}
+	log.Printf("[mDNS] Found %d device(s)\n", len(devices))
+	return devices, nil
+}
 
-	entries := make(chan *zeroconf.ServiceEntry)
-	devices := []models.Device{}
+// BrowseMDNS runs a two-stage DNS-SD browse: the first third of the timeout
+// enumerates the advertised service types, the rest browses and resolves every
+// type concurrently. Services are grouped by host name.
+func BrowseMDNS(timeout time.Duration) ([]MDNSHost, error) {
+	typeWindow := timeout / 3
+	types, err := mdnsServiceTypes(typeWindow)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[mDNS] %d service type(s) advertised\n", len(types))
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout-typeWindow)
 	defer cancel()
 
-	go func(results <-chan *zeroconf.ServiceEntry) {
-		for entry := range results {
-			device := models.NewDevice(models.DeviceConfig{
-				Hostname: entry.HostName,
-				IPAddress: "", // Can fill from entry.AddrIPv4 later
-				DeviceType: "unknown",
-				Vendor: "",
-				Status: "active",
-				MonitoringProtocols: []string{"mDNS"},
+	var mu sync.Mutex
+	var collected []*zeroconf.ServiceEntry
+	var wg sync.WaitGroup
+	for _, serviceType := range types {
+		wg.Add(1)
+		go func(serviceType string) {
+			defer wg.Done()
+			err := mdnsBrowse(ctx, serviceType, func(e *zeroconf.ServiceEntry) {
+				mu.Lock()
+				collected = append(collected, e)
+				mu.Unlock()
 			})
-			devices = append(devices, *device)
+			if err != nil {
+				log.Printf("[mDNS] Browse %s failed: %v", serviceType, err)
+			}
+		}(serviceType)
+	}
+	wg.Wait()
+
+	return mdnsHosts(collected), nil
+}
+
+// mdnsServiceTypes enumerates service types via the meta-query. Entries come
+// back as "_ipp._tcp.local"; the domain is trimmed.
+func mdnsServiceTypes(timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	var types []string
+	err := mdnsBrowse(ctx, mdnsMetaQuery, func(e *zeroconf.ServiceEntry) {
+		t := strings.TrimSuffix(strings.TrimSuffix(e.Instance, "."), ".local")
+		if t != "" && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
 		}
-	}(entries)
+	})
+	sort.Strings(types)
+	return types, err
+}
 
-	if err := resolver.Browse(ctx, "_services._dns-sd._udp", "local.", entries); err != nil {
-		log.Println("mDNS browse error:", err)
+// mdnsBrowse browses one service type until ctx is done, passing each entry to
+// fn. Each browse needs its own resolver: the library's listeners hand every
+// message to a single browse loop.
+func mdnsBrowse(ctx context.Context, service string, fn func(*zeroconf.ServiceEntry)) error {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return fmt.Errorf("error creating mDNS resolver: %v", err)
 	}
 
-	<-ctx.Done()
-	close(entries)
+	// The library closes the channel once ctx is done, but blocks while
+	// sending, so drain it until it is closed rather than stopping at
+	// ctx.Done and leaving the browse loop stuck on a send.
+	entries := make(chan *zeroconf.ServiceEntry, 32)
+	if err := resolver.Browse(ctx, service, "local.", entries); err != nil {
+		return err
+	}
+	for e := range entries {
+		if e != nil {
+			fn(e)
+		}
+	}
+	return nil
+}
 
-	log.Printf("[mDNS] Found %d device(s)\n", len(devices))
-	return devices, nil
+// mdnsHosts groups service entries by host name.
+func mdnsHosts(entries []*zeroconf.ServiceEntry) []MDNSHost {
+	byName := make(map[string]*MDNSHost)
+	var names []string
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.HostName, ".")
+		if name == "" {
+			continue
+		}
+		h, ok := byName[name]
+		if !ok {
+			h = &MDNSHost{HostName: name}
+			byName[name] = h
+			names = append(names, name)
+		}
+		for _, ip := range e.AddrIPv4 {
+			h.IPv4 = appendUnique(h.IPv4, ip.String())
+		}
+		for _, ip := range e.AddrIPv6 {
+			h.IPv6 = appendUnique(h.IPv6, ip.String())
+		}
+
+		svc := MDNSService{
+			Instance: e.Instance,
+			Type:     strings.TrimSuffix(e.Service, "."),
+			Port:     e.Port,
+			Text:     parseTXT(e.Text),
+		}
+		if !hasService(h.Services, svc) {
+			h.Services = append(h.Services, svc)
+		}
+	}
+	sort.Strings(names)
+
+	hosts := make([]MDNSHost, 0, len(names))
+	for _, name := range names {
+		h := byName[name]
+		sort.Slice(h.Services, func(i, j int) bool {
+			if h.Services[i].Type != h.Services[j].Type {
+				return h.Services[i].Type < h.Services[j].Type
+			}
+			return h.Services[i].Instance < h.Services[j].Instance
+		})
+		hosts = append(hosts, *h)
+	}
+	return hosts
+}
+
+func hasService(services []MDNSService, svc MDNSService) bool {
+	for _, s := range services {
+		if s.Type == svc.Type && s.Instance == svc.Instance {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// parseTXT splits "key=value" TXT strings; bare keys map to "".
+func parseTXT(txt []string) map[string]string {
+	if len(txt) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(txt))
+	for _, t := range txt {
+		k, v, _ := strings.Cut(t, "=")
+		if k != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// mdnsTypeClasses maps advertised service types to a device type, most
+// specific first.
+var mdnsTypeClasses = []struct {
+	deviceType string
+	services   []string
+}{
+	{"printer", []string{"_ipp._tcp", "_ipps._tcp", "_printer._tcp", "_pdl-datastream._tcp", "_uscan._tcp", "_scanner._tcp"}},
+	{"media-device", []string{"_airplay._tcp", "_raop._tcp", "_googlecast._tcp", "_spotify-connect._tcp", "_sonos._tcp"}},
+	{"camera", []string{"_axis-video._tcp", "_rtsp._tcp"}},
+	{"iot", []string{"_hap._tcp", "_hap._udp", "_homekit._tcp", "_matter._tcp", "_matterc._udp"}},
+	{"nas", []string{"_afpovertcp._tcp", "_adisk._tcp", "_nfs._tcp"}},
+	{"workstation", []string{"_companion-link._tcp", "_rdlink._tcp", "_workstation._tcp"}},
+}
+
+// DeviceType classifies the host from the services it advertises.
+func (h *MDNSHost) DeviceType() string {
+	for _, class := range mdnsTypeClasses {
+		for _, want := range class.services {
+			for _, s := range h.Services {
+				if s.Type == want {
+					return class.deviceType
+				}
+			}
+		}
+	}
+	return "unknown"
+}
+
+// ToDevice maps the host onto a models.Device. The advertised services are
+// listed in the description.
+func (h *MDNSHost) ToDevice() *models.Device {
+	services := make([]string, 0, len(h.Services))
+	for _, s := range h.Services {
+		services = append(services, fmt.Sprintf("%s:%d", s.Type, s.Port))
+	}
+
+	vendor := ""
+	for _, s := range h.Services {
+		// Printers publish their make in the "usb_MFG" TXT key (Bonjour Printing spec).
+		if mfg := s.Text["usb_MFG"]; mfg != "" {
+			vendor = mfg
+			break
+		}
+	}
+
+	addrs := append(append([]string(nil), h.IPv4...), h.IPv6...)
+	description := ""
+	if len(services) > 0 {
+		description = "mDNS services: " + strings.Join(services, ", ")
+	}
+	return models.NewDevice(models.DeviceConfig{
+		Hostname:            strings.TrimSuffix(h.HostName, ".local"),
+		IPAddresses:         addrs,
+		DeviceType:          h.DeviceType(),
+		Vendor:              vendor,
+		Status:              "active",
+		MonitoringProtocols: []string{"mDNS"},
+		Description:         description,
+	})
 }
@@ -0,0 +1,328 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMPv6 = 58
+	ndpOptSourceLL = 1 // source link-layer address option
+	ndpOptTargetLL = 2 // target link-layer address option
+)
+
+var allNodesMulticast = net.ParseIP("ff02::1")
+
+// NDPScanConfig holds settings for IPv6 neighbor discovery.
+type NDPScanConfig struct {
+	Interface string
+	Timeout   time.Duration // reply window for each phase (default 2s)
+}
+
+// NDPScan discovers IPv6 neighbors on an interface. It pings the all-nodes
+// multicast group ff02::1 from every local IPv6 address (so hosts answer from
+// both link-local and global addresses), then sends a Neighbor Solicitation to
+// each responder to learn its MAC address. Addresses sharing a MAC are
+// grouped into one device.
+func NDPScan(ctx context.Context, cfg NDPScanConfig) ([]models.Device, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+
+	iface, err := net.InterfaceByName(cfg.Interface)
+	if err != nil {
+		return nil, fmt.Errorf("error finding interface %s: %v", cfg.Interface, err)
+	}
+
+	linkLocal, sources := localIPv6Addrs(iface)
+	if linkLocal == nil {
+		return nil, fmt.Errorf("interface %s has no IPv6 link-local address", cfg.Interface)
+	}
+
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, fmt.Errorf("error opening ICMPv6 socket: %v", err)
+	}
+	defer conn.Close()
+
+	pc := conn.IPv6PacketConn()
+	// NDP messages must be sent with hop limit 255 (RFC 4861 section 7.1).
+	pc.SetMulticastInterface(iface)
+	pc.SetMulticastHopLimit(255)
+	pc.SetHopLimit(255)
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeEchoReply)
+	filter.Accept(ipv6.ICMPTypeNeighborAdvertisement)
+	pc.SetICMPFilter(&filter)
+
+	echoID := os.Getpid() & 0xffff
+	var mu sync.Mutex
+	neighbors := make(map[string]string) // address -> MAC ("" until solicited)
+
+	stop := make(chan struct{})
+	var readerWG sync.WaitGroup
+	readerWG.Add(1)
+	go func() {
+		defer readerWG.Done()
+		buf := make([]byte, 1500)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			pc.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, src, err := pc.ReadFrom(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					continue
+				}
+				return
+			}
+			addr, mac, ok := parseNDPReply(buf[:n], src, echoID, iface.Name)
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			if known, seen := neighbors[addr]; !seen || (known == "" && mac != "") {
+				neighbors[addr] = mac
+			}
+			mu.Unlock()
+		}
+	}()
+
+	wait := func() {
+		select {
+		case <-time.After(cfg.Timeout):
+		case <-ctx.Done():
+		}
+	}
+
+	// Phase 1: multicast echo from every local address.
+	for seq, src := range sources {
+		echo := icmp.Message{
+			Type: ipv6.ICMPTypeEchoRequest,
+			Body: &icmp.Echo{ID: echoID, Seq: seq + 1, Data: []byte("sentinel")},
+		}
+		if err := sendICMPv6(pc, echo, src, &net.IPAddr{IP: allNodesMulticast, Zone: iface.Name}, iface); err != nil {
+			log.Printf("[NDP] Echo to ff02::1 from %s failed: %v", src, err)
+		}
+	}
+	wait()
+
+	// Phase 2: solicit the link-layer address of every responder.
+	mu.Lock()
+	var unresolved []net.IP
+	for addr, mac := range neighbors {
+		if mac == "" {
+			unresolved = append(unresolved, net.ParseIP(stripZone(addr)))
+		}
+	}
+	mu.Unlock()
+
+	for _, target := range unresolved {
+		if ctx.Err() != nil {
+			break
+		}
+		src := linkLocal
+		if !target.IsLinkLocalUnicast() {
+			src = sourceFor(target, sources, linkLocal)
+		}
+		ns := icmp.Message{
+			Type: ipv6.ICMPTypeNeighborSolicitation,
+			Body: &icmp.RawBody{Data: neighborSolicitation(target, iface.HardwareAddr)},
+		}
+		dst := &net.IPAddr{IP: solicitedNodeMulticast(target), Zone: iface.Name}
+		if err := sendICMPv6(pc, ns, src, dst, iface); err != nil {
+			log.Printf("[NDP] Solicitation for %s failed: %v", target, err)
+		}
+	}
+	if len(unresolved) > 0 {
+		wait()
+	}
+
+	close(stop)
+	pc.SetReadDeadline(time.Now())
+	readerWG.Wait()
+
+	devices := ndpDevices(neighbors)
+	log.Printf("[NDP] Found %d IPv6 device(s) on %s\n", len(devices), cfg.Interface)
+	return devices, ctx.Err()
+}
+
+// localIPv6Addrs returns the interface's link-local address and all IPv6
+// addresses usable as an echo source.
+func localIPv6Addrs(iface *net.Interface) (net.IP, []net.IP) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, nil
+	}
+	var linkLocal net.IP
+	var sources []net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.To4() != nil || ipNet.IP.IsLoopback() {
+			continue
+		}
+		if ipNet.IP.IsLinkLocalUnicast() && linkLocal == nil {
+			linkLocal = ipNet.IP
+		}
+		sources = append(sources, ipNet.IP)
+	}
+	return linkLocal, sources
+}
+
+// sourceFor picks the local address sharing the longest prefix with target.
+func sourceFor(target net.IP, sources []net.IP, fallback net.IP) net.IP {
+	best, bestLen := fallback, -1
+	for _, s := range sources {
+		if s.IsLinkLocalUnicast() {
+			continue
+		}
+		n := 0
+		for i := 0; i < net.IPv6len && s[i] == target[i]; i++ {
+			n++
+		}
+		if n > bestLen {
+			best, bestLen = s, n
+		}
+	}
+	return best
+}
+
+func sendICMPv6(pc *ipv6.PacketConn, msg icmp.Message, src net.IP, dst *net.IPAddr, iface *net.Interface) error {
+	// The kernel fills in the ICMPv6 checksum on raw sockets.
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	cm := &ipv6.ControlMessage{Src: src, IfIndex: iface.Index, HopLimit: 255}
+	_, err = pc.WriteTo(b, cm, dst)
+	return err
+}
+
+// neighborSolicitation builds an NS body: reserved, target, source link-layer option.
+func neighborSolicitation(target net.IP, mac net.HardwareAddr) []byte {
+	b := make([]byte, 4, 4+net.IPv6len+2+len(mac))
+	b = append(b, target.To16()...)
+	if len(mac) == 6 {
+		b = append(b, ndpOptSourceLL, 1)
+		b = append(b, mac...)
+	}
+	return b
+}
+
+// solicitedNodeMulticast returns ff02::1:ffXX:XXXX for an address.
+func solicitedNodeMulticast(ip net.IP) net.IP {
+	ip16 := ip.To16()
+	return net.IP{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, ip16[13], ip16[14], ip16[15]}
+}
+
+// parseNDPReply extracts the responder address, and MAC for advertisements.
+func parseNDPReply(b []byte, src net.Addr, echoID int, zone string) (string, string, bool) {
+	msg, err := icmp.ParseMessage(protocolICMPv6, b)
+	if err != nil {
+		return "", "", false
+	}
+
+	switch msg.Type {
+	case ipv6.ICMPTypeEchoReply:
+		echo, ok := msg.Body.(*icmp.Echo)
+		ipAddr, isIP := src.(*net.IPAddr)
+		if !ok || !isIP || echo.ID != echoID {
+			return "", "", false
+		}
+		return ndpAddress(ipAddr.IP, zone), "", true
+
+	case ipv6.ICMPTypeNeighborAdvertisement:
+		raw, ok := msg.Body.(*icmp.RawBody)
+		if !ok || len(raw.Data) < 4+net.IPv6len {
+			return "", "", false
+		}
+		target := net.IP(raw.Data[4 : 4+net.IPv6len])
+		mac := ""
+		for opts := raw.Data[4+net.IPv6len:]; len(opts) >= 8; {
+			length := int(opts[1]) * 8
+			if length == 0 || length > len(opts) {
+				break
+			}
+			if opts[0] == ndpOptTargetLL && length >= 8 {
+				mac = net.HardwareAddr(opts[2:8]).String()
+			}
+			opts = opts[length:]
+		}
+		return ndpAddress(target, zone), mac, true
+	}
+	return "", "", false
+}
+
+// ndpAddress keeps the zone on link-local addresses so they stay reachable.
+func ndpAddress(ip net.IP, zone string) string {
+	if ip.IsLinkLocalUnicast() {
+		return ip.String() + "%" + zone
+	}
+	return ip.String()
+}
+
+func stripZone(addr string) string {
+	host, _, _ := strings.Cut(addr, "%")
+	return host
+}
+
+// ndpDevices groups neighbor addresses by MAC into devices.
+func ndpDevices(neighbors map[string]string) []models.Device {
+	byMAC := make(map[string][]string)
+	var keys []string
+	for addr, mac := range neighbors {
+		key := mac
+		if key == "" {
+			key = "addr:" + addr // unresolved, keep on its own
+		}
+		if _, ok := byMAC[key]; !ok {
+			keys = append(keys, key)
+		}
+		byMAC[key] = append(byMAC[key], addr)
+	}
+	sort.Strings(keys)
+
+	devices := make([]models.Device, 0, len(keys))
+	for _, key := range keys {
+		addrs := byMAC[key]
+		// Routable addresses first so they become the primary address.
+		sort.Slice(addrs, func(i, j int) bool {
+			li := net.ParseIP(stripZone(addrs[i])).IsLinkLocalUnicast()
+			lj := net.ParseIP(stripZone(addrs[j])).IsLinkLocalUnicast()
+			if li != lj {
+				return !li
+			}
+			return addrs[i] < addrs[j]
+		})
+		mac := ""
+		if neighbors[addrs[0]] != "" {
+			mac = neighbors[addrs[0]]
+		}
+		device := models.NewDevice(models.DeviceConfig{
+			IPAddresses:         addrs,
+			DeviceType:          "unknown",
+			Status:              "active",
+			MonitoringProtocols: []string{"NDP"},
+			MACAddress:          mac,
+		})
+		devices = append(devices, *device)
+	}
+	return devices
+}
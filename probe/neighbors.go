@@ -0,0 +1,285 @@
+package probe
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// LLDP-MIB (IEEE 802.1AB) and CISCO-CDP-MIB objects used for neighbor discovery.
+const (
+	oidLLDPLocChassisIDSubtype = ".1.0.8802.1.1.2.1.3.1.0"
+	oidLLDPLocChassisID        = ".1.0.8802.1.1.2.1.3.2.0"
+	oidLLDPLocSysName          = ".1.0.8802.1.1.2.1.3.3.0"
+	oidLLDPLocPortEntry        = ".1.0.8802.1.1.2.1.3.7.1"
+	oidLLDPRemEntry            = ".1.0.8802.1.1.2.1.4.1.1"
+	oidLLDPRemManAddrEntry     = ".1.0.8802.1.1.2.1.4.2.1"
+	oidCDPCacheEntry           = ".1.3.6.1.4.1.9.9.23.1.2.1.1"
+	oidIfName                  = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfDescr                 = ".1.3.6.1.2.1.2.2.1.2"
+	oidSysName                 = ".1.3.6.1.2.1.1.5.0"
+)
+
+// NeighborEntry is one adjacency seen from a local device port.
+type NeighborEntry struct {
+	LocalChassisID  string
+	LocalSysName    string
+	LocalAddress    string
+	LocalPort       string
+	RemoteChassisID string
+	RemoteSysName   string
+	RemoteAddress   string
+	RemotePort      string
+	RemotePortDescr string
+	RemotePlatform  string
+	Protocol        string // LLDP, LLDP-MIB or CDP-MIB
+}
+
+// NeighborEntry converts a captured frame into an adjacency between the
+// capturing host and the advertising device.
+func (n *LLDPNeighbor) NeighborEntry(localSysName, localAddress string) NeighborEntry {
+	return NeighborEntry{
+		LocalSysName:    localSysName,
+		LocalAddress:    localAddress,
+		LocalPort:       n.SeenOn,
+		RemoteChassisID: n.ChassisID,
+		RemoteSysName:   n.SysName,
+		RemoteAddress:   n.MgmtAddress,
+		RemotePort:      n.PortID,
+		RemotePortDescr: n.PortDescription,
+		RemotePlatform:  n.SysDescription,
+		Protocol:        "LLDP",
+	}
+}
+
+// WalkLLDPNeighbors reads lldpRemTable from a device and returns its neighbors.
+func WalkLLDPNeighbors(cfg SNMPConfig) ([]NeighborEntry, error) {
+	local, err := FetchMetrics(cfg, []string{oidLLDPLocChassisIDSubtype, oidLLDPLocChassisID, oidLLDPLocSysName})
+	if err != nil {
+		return nil, err
+	}
+	localChassis := lldpChassisFromSNMP(local.Metrics.Values[oidLLDPLocChassisIDSubtype], local.Metrics.Values[oidLLDPLocChassisID])
+	localName := local.Metrics.Values[oidLLDPLocSysName]
+
+	remotes, err := walkTable(cfg, oidLLDPRemEntry)
+	if err != nil {
+		return nil, err
+	}
+	if len(remotes) == 0 {
+		return nil, nil
+	}
+
+	localPorts, err := walkTable(cfg, oidLLDPLocPortEntry)
+	if err != nil {
+		log.Printf("[LLDP-MIB] lldpLocPortTable walk failed for %s: %v", cfg.Target, err)
+	}
+	mgmtAddrs := lldpRemoteAddresses(cfg)
+
+	var entries []NeighborEntry
+	for index, row := range remotes {
+		// INDEX { lldpRemTimeMark, lldpRemLocalPortNum, lldpRemIndex }
+		parts := strings.Split(index, ".")
+		if len(parts) != 3 {
+			continue
+		}
+		localPortNum, remIndex := parts[1], parts[2]
+
+		entry := NeighborEntry{
+			LocalChassisID:  localChassis,
+			LocalSysName:    localName,
+			LocalAddress:    cfg.Target,
+			LocalPort:       lldpLocalPortName(localPorts[localPortNum], localPortNum),
+			RemoteChassisID: lldpChassisFromSNMP(row[4], row[5]),
+			RemotePort:      lldpPortFromSNMP(row[6], row[7]),
+			RemotePortDescr: row[8],
+			RemoteSysName:   row[9],
+			RemotePlatform:  row[10],
+			RemoteAddress:   mgmtAddrs[localPortNum+"."+remIndex],
+			Protocol:        "LLDP-MIB",
+		}
+		if entry.RemoteChassisID == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	log.Printf("[LLDP-MIB] %s reports %d neighbor(s)", cfg.Target, len(entries))
+	return entries, nil
+}
+
+// WalkCDPNeighbors reads cdpCacheTable from a Cisco device and returns its neighbors.
+func WalkCDPNeighbors(cfg SNMPConfig) ([]NeighborEntry, error) {
+	cache, err := walkTable(cfg, oidCDPCacheEntry)
+	if err != nil {
+		return nil, err
+	}
+	if len(cache) == 0 {
+		return nil, nil
+	}
+
+	localName := ""
+	if res, err := FetchMetrics(cfg, []string{oidSysName}); err == nil {
+		localName = res.Metrics.Values[oidSysName]
+	}
+	ifNames := walkIfNames(cfg)
+
+	var entries []NeighborEntry
+	for index, row := range cache {
+		// INDEX { cdpCacheIfIndex, cdpCacheDeviceIndex }
+		ifIndex, _, ok := strings.Cut(index, ".")
+		if !ok {
+			continue
+		}
+
+		localPort := ifNames[ifIndex]
+		if localPort == "" {
+			localPort = "ifIndex " + ifIndex
+		}
+
+		remoteAddr := ""
+		// cdpCacheAddressType 1 is ip(1); the address is four raw octets.
+		if row[3] == "1" {
+			if raw := parseOctets(row[4]); len(raw) == net.IPv4len {
+				remoteAddr = net.IP(raw).String()
+			}
+		}
+
+		entry := NeighborEntry{
+			LocalSysName:   localName,
+			LocalAddress:   cfg.Target,
+			LocalPort:      localPort,
+			RemoteSysName:  row[6],
+			RemoteAddress:  remoteAddr,
+			RemotePort:     row[7],
+			RemotePlatform: row[8],
+			Protocol:       "CDP-MIB",
+		}
+		if entry.RemoteSysName == "" && entry.RemoteAddress == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	log.Printf("[CDP-MIB] %s reports %d neighbor(s)", cfg.Target, len(entries))
+	return entries, nil
+}
+
+// lldpRemoteAddresses maps "localPortNum.remIndex" to the first IP management
+// address found in lldpRemManAddrTable.
+func lldpRemoteAddresses(cfg SNMPConfig) map[string]string {
+	addrs := make(map[string]string)
+	table, err := walkTable(cfg, oidLLDPRemManAddrEntry)
+	if err != nil {
+		return addrs
+	}
+
+	for index := range table {
+		// INDEX { lldpRemTimeMark, lldpRemLocalPortNum, lldpRemIndex,
+		//         lldpRemManAddrSubtype, lldpRemManAddr (length-prefixed) }
+		parts := strings.Split(index, ".")
+		if len(parts) < 6 {
+			continue
+		}
+		key := parts[1] + "." + parts[2]
+		if _, ok := addrs[key]; ok {
+			continue
+		}
+		length, err := strconv.Atoi(parts[4])
+		if err != nil || len(parts) != 5+length {
+			continue
+		}
+		raw := make([]byte, length)
+		for i, p := range parts[5:] {
+			b, err := strconv.Atoi(p)
+			if err != nil {
+				raw = nil
+				break
+			}
+			raw[i] = byte(b)
+		}
+		if addr := formatNetworkAddr(append([]byte{byte(atoiOrZero(parts[3]))}, raw...)); addr != "" {
+			addrs[key] = addr
+		}
+	}
+	return addrs
+}
+
+// walkIfNames maps ifIndex to ifName, falling back to ifDescr.
+func walkIfNames(cfg SNMPConfig) map[string]string {
+	names := make(map[string]string)
+	for _, base := range []string{oidIfDescr, oidIfName} {
+		values, err := BulkWalkMetrics(cfg, base)
+		if err != nil {
+			continue
+		}
+		prefix := base + "."
+		for name, value := range values {
+			ifIndex := strings.TrimPrefix("."+strings.TrimPrefix(name, "."), prefix)
+			if value != "" {
+				names[ifIndex] = value
+			}
+		}
+	}
+	return names
+}
+
+// lldpChassisFromSNMP renders an LLDP chassis ID the same way DecodeLLDP does
+// so that captured and polled neighbors can be matched.
+func lldpChassisFromSNMP(subtype, value string) string {
+	if value == "" {
+		return ""
+	}
+	raw := parseOctets(value)
+	switch subtype {
+	case "4": // macAddress
+		if len(raw) == 6 {
+			return net.HardwareAddr(raw).String()
+		}
+	case "5": // networkAddress
+		if addr := formatNetworkAddr(raw); addr != "" {
+			return addr
+		}
+	}
+	return printableID(raw)
+}
+
+// lldpPortFromSNMP renders an LLDP port ID the same way DecodeLLDP does.
+func lldpPortFromSNMP(subtype, value string) string {
+	if value == "" {
+		return ""
+	}
+	raw := parseOctets(value)
+	switch subtype {
+	case "3": // macAddress
+		if len(raw) == 6 {
+			return net.HardwareAddr(raw).String()
+		}
+	case "4": // networkAddress
+		if addr := formatNetworkAddr(raw); addr != "" {
+			return addr
+		}
+	}
+	return printableID(raw)
+}
+
+// lldpLocalPortName prefers the port ID and falls back to the description.
+func lldpLocalPortName(row map[int]string, portNum string) string {
+	if row == nil {
+		return fmt.Sprintf("port %s", portNum)
+	}
+	// lldpLocPortIdSubtype 3 is macAddress, which is useless as a port name.
+	if row[2] != "3" && row[3] != "" {
+		return lldpPortFromSNMP(row[2], row[3])
+	}
+	if row[4] != "" {
+		return row[4]
+	}
+	return fmt.Sprintf("port %s", portNum)
+}
+
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -1,15 +1,65 @@
 package probe
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/sofc-t/sentinel/domain/models"
 )
 
-// NetBIOSScan tries to resolve NetBIOS names for devices in subnet
-func NetBIOSScan(ip string) string {
+const (
+	nbstatType  = 0x0021 // NBSTAT resource record type
+	nbClassIN   = 0x0001
+	nbNameLen   = 16
+	nbEntrySize = 18 // 15-byte name, suffix, 2-byte flags
+)
+
+// NetBIOS name flags (RFC 1002 section 4.2.18).
+const (
+	NetBIOSFlagGroup       uint16 = 0x8000
+	NetBIOSFlagDeregister  uint16 = 0x1000
+	NetBIOSFlagConflict    uint16 = 0x0800
+	NetBIOSFlagActive      uint16 = 0x0400
+	NetBIOSFlagPermanent   uint16 = 0x0200
+	netBIOSOwnerNodeMask   uint16 = 0x6000
+	netBIOSOwnerNodeOffset        = 13
+)
+
+// NetBIOSName is one entry of a node status name table.
+type NetBIOSName struct {
+	Name   string
+	Suffix byte
+	Flags  uint16
+}
+
+// IsGroup reports whether the name is a group name.
+func (n NetBIOSName) IsGroup() bool {
+	return n.Flags&NetBIOSFlagGroup != 0
+}
+
+// NodeType returns the owner node type: B, P, M or H.
+func (n NetBIOSName) NodeType() string {
+	return [...]string{"B", "P", "M", "H"}[(n.Flags&netBIOSOwnerNodeMask)>>netBIOSOwnerNodeOffset]
+}
+
+// NetBIOSInfo is a parsed NBSTAT (node status) response.
+type NetBIOSInfo struct {
+	IP                 string
+	ComputerName       string // unique <00> name
+	Workgroup          string // group <00> name: workgroup or domain
+	User               string // unique <03> messenger name other than the computer name
+	IsDomainController bool   // registers the domain <1C> group
+	Names              []NetBIOSName
+	MAC                string // unit ID from the statistics block
+}
+
+// NetBIOSScan sends an NBSTAT query for the wildcard name to a host and
+// parses its name table.
+func NetBIOSScan(ip string) (*NetBIOSInfo, error) {
 	timeout := 500 * time.Millisecond
 	addr := net.UDPAddr{
 		IP:   net.ParseIP(ip),
@@ -17,53 +67,208 @@ func NetBIOSScan(ip string) string {
 	}
 	conn, err := net.DialUDP("udp", nil, &addr)
 	if err != nil {
-		return ""
+		return nil, fmt.Errorf("NetBIOS dial %s: %v", ip, err)
 	}
 	defer conn.Close()
 
 	conn.SetDeadline(time.Now().Add(timeout))
 
-	// minimal query (broadcast), ignoring response parsing for now
-	buf := []byte{
-		0x00, 0x00, // Transaction ID
-		0x00, 0x10, // Flags
-		0x00, 0x01, // Questions
-		0x00, 0x00, // Answer RRs
-		0x00, 0x00, // Authority RRs
-		0x00, 0x00, // Additional RRs
-		// NetBIOS name query header follows...
-	}
-	_, err = conn.Write(buf)
-	if err != nil {
-		return ""
+	txID := uint16(time.Now().UnixNano())
+	if _, err := conn.Write(BuildNBSTATQuery(txID)); err != nil {
+		return nil, fmt.Errorf("NetBIOS write %s: %v", ip, err)
 	}
 
 	resp := make([]byte, 1024)
-	_, err = conn.Read(resp)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, fmt.Errorf("NetBIOS read %s: %v", ip, err)
+	}
+
+	info, err := parseNBSTATReply(resp[:n], txID)
+	if err != nil {
+		return nil, fmt.Errorf("NetBIOS response from %s: %v", ip, err)
+	}
+	info.IP = ip
+	return info, nil
+}
+
+// parseNBSTATReply checks that a response answers the query with the given
+// transaction ID before decoding it.
+func parseNBSTATReply(b []byte, txID uint16) (*NetBIOSInfo, error) {
+	if len(b) < 2 {
+		return nil, errNBSTATShort
+	}
+	if got := binary.BigEndian.Uint16(b[0:2]); got != txID {
+		return nil, fmt.Errorf("transaction ID %#04x, want %#04x", got, txID)
+	}
+	return ParseNBSTATResponse(b)
+}
+
+// BuildNBSTATQuery builds a node status request for the wildcard name "*".
+func BuildNBSTATQuery(txID uint16) []byte {
+	buf := make([]byte, 12, 12+34+4)
+	binary.BigEndian.PutUint16(buf[0:2], txID)
+	// Flags 0: query, no recursion; one question, no other records.
+	binary.BigEndian.PutUint16(buf[4:6], 1)
+
+	buf = append(buf, encodeNetBIOSName("*", 0x00)...)
+	buf = binary.BigEndian.AppendUint16(buf, nbstatType)
+	buf = binary.BigEndian.AppendUint16(buf, nbClassIN)
+	return buf
+}
+
+// encodeNetBIOSName applies RFC 1001 first-level encoding. The wildcard name
+// is padded with NULs, any other name with spaces.
+func encodeNetBIOSName(name string, suffix byte) []byte {
+	raw := make([]byte, nbNameLen)
+	pad := byte(' ')
+	if name == "*" {
+		pad = 0x00
+	}
+	for i := range raw[:nbNameLen-1] {
+		raw[i] = pad
+	}
+	copy(raw, strings.ToUpper(name))
+	raw[nbNameLen-1] = suffix
+
+	out := make([]byte, 0, 34)
+	out = append(out, 0x20)
+	for _, b := range raw {
+		out = append(out, 'A'+(b>>4), 'A'+(b&0x0f))
+	}
+	return append(out, 0x00)
+}
+
+var errNBSTATShort = errors.New("truncated NBSTAT response")
+
+// ParseNBSTATResponse decodes a node status response into its name table,
+// computer name, workgroup/domain and adapter MAC.
+func ParseNBSTATResponse(b []byte) (*NetBIOSInfo, error) {
+	if len(b) < 12 {
+		return nil, errNBSTATShort
+	}
+	flags := binary.BigEndian.Uint16(b[2:4])
+	if flags&0x8000 == 0 {
+		return nil, errors.New("not a response")
+	}
+	if rcode := flags & 0x000f; rcode != 0 {
+		return nil, fmt.Errorf("error rcode %d", rcode)
+	}
+	if binary.BigEndian.Uint16(b[6:8]) == 0 {
+		return nil, errors.New("no answer records")
+	}
+
+	off, err := skipNetBIOSName(b, 12)
 	if err != nil {
-		return ""
+		return nil, err
 	}
+	if off+10 > len(b) {
+		return nil, errNBSTATShort
+	}
+	if rrType := binary.BigEndian.Uint16(b[off : off+2]); rrType != nbstatType {
+		return nil, fmt.Errorf("unexpected record type %#04x", rrType)
+	}
+	rdLen := int(binary.BigEndian.Uint16(b[off+8 : off+10]))
+	off += 10
+	if off+rdLen > len(b) || rdLen < 1 {
+		return nil, errNBSTATShort
+	}
+	rdata := b[off : off+rdLen]
+
+	count := int(rdata[0])
+	if 1+count*nbEntrySize > len(rdata) {
+		return nil, errNBSTATShort
+	}
+
+	info := &NetBIOSInfo{}
+	for i := 0; i < count; i++ {
+		entry := rdata[1+i*nbEntrySize : 1+(i+1)*nbEntrySize]
+		name := NetBIOSName{
+			Name:   strings.TrimRight(string(entry[:15]), " \x00"),
+			Suffix: entry[15],
+			Flags:  binary.BigEndian.Uint16(entry[16:18]),
+		}
+		info.Names = append(info.Names, name)
 
-	// crude: just return "NetBIOS-device" placeholder
-	return fmt.Sprintf("NetBIOS-%s", ip)
+		switch {
+		case name.Suffix == 0x00 && !name.IsGroup() && info.ComputerName == "":
+			info.ComputerName = name.Name
+		case name.Suffix == 0x00 && name.IsGroup() && info.Workgroup == "":
+			info.Workgroup = name.Name
+		case name.Suffix == 0x1c && name.IsGroup():
+			info.IsDomainController = true
+			if info.Workgroup == "" {
+				info.Workgroup = name.Name
+			}
+		}
+	}
+	for _, name := range info.Names {
+		if name.Suffix == 0x03 && !name.IsGroup() && name.Name != info.ComputerName {
+			info.User = name.Name
+			break
+		}
+	}
+
+	// The statistics block starts with the 6-byte unit ID (adapter MAC).
+	stats := rdata[1+count*nbEntrySize:]
+	if len(stats) >= 6 {
+		mac := net.HardwareAddr(stats[:6])
+		if mac.String() != "00:00:00:00:00:00" {
+			info.MAC = mac.String()
+		}
+	}
+	return info, nil
+}
+
+// skipNetBIOSName returns the offset just past an encoded or compressed name.
+func skipNetBIOSName(b []byte, off int) (int, error) {
+	for {
+		if off >= len(b) {
+			return 0, errNBSTATShort
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			return off + 2, nil // compression pointer ends the name
+		default:
+			off += 1 + l
+		}
+	}
 }
 
 // CaptureNetBIOS scans a list of IPs and returns Device objects
 func CaptureNetBIOS(ips []string) []models.Device {
 	devices := []models.Device{}
 	for _, ip := range ips {
-		name := NetBIOSScan(ip)
-		if name != "" {
-			device := models.NewDevice(models.DeviceConfig{
-				Hostname: name,
-				IPAddress: ip,
-				DeviceType: "unknown",
-				Vendor: "",
-				Status: "active",
-				MonitoringProtocols: []string{"NetBIOS"},
-			})
-			devices = append(devices, *device)
+		info, err := NetBIOSScan(ip)
+		if err != nil || info.ComputerName == "" {
+			continue
 		}
+		devices = append(devices, *info.ToDevice())
 	}
 	return devices
 }
+
+// ToDevice maps the node status onto a models.Device.
+func (info *NetBIOSInfo) ToDevice() *models.Device {
+	deviceType := "unknown"
+	if info.IsDomainController {
+		deviceType = "domain-controller"
+	}
+	description := ""
+	if info.Workgroup != "" {
+		description = "NetBIOS workgroup/domain: " + info.Workgroup
+	}
+	return models.NewDevice(models.DeviceConfig{
+		Hostname:            info.ComputerName,
+		IPAddress:           info.IP,
+		DeviceType:          deviceType,
+		Vendor:              "",
+		Status:              "active",
+		MonitoringProtocols: []string{"NetBIOS"},
+		MACAddress:          info.MAC,
+		Description:         description,
+	})
+}
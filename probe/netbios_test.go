@@ -0,0 +1,155 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// nbstatResponse builds a node status response the way Windows and Samba
+// send it: the wildcard question name, one NBSTAT record, the name table and
+// a 46-byte statistics block starting with the unit ID.
+func nbstatResponse(txID uint16, names []NetBIOSName, unitID []byte) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:2], txID)
+	binary.BigEndian.PutUint16(b[2:4], 0x8400) // response, authoritative
+	binary.BigEndian.PutUint16(b[6:8], 1)
+
+	b = append(b, encodeNetBIOSName("*", 0x00)...)
+	b = binary.BigEndian.AppendUint16(b, nbstatType)
+	b = binary.BigEndian.AppendUint16(b, nbClassIN)
+	b = binary.BigEndian.AppendUint32(b, 0) // TTL
+
+	rdata := []byte{byte(len(names))}
+	for _, n := range names {
+		entry := []byte("               ")
+		copy(entry, n.Name)
+		entry = append(entry, n.Suffix)
+		rdata = binary.BigEndian.AppendUint16(append(rdata, entry...), n.Flags)
+	}
+	stats := make([]byte, 46)
+	copy(stats, unitID)
+	rdata = append(rdata, stats...)
+
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+	return append(b, rdata...)
+}
+
+const (
+	nbUnique = NetBIOSFlagActive | 0x6000 // H-node
+	nbGroup  = NetBIOSFlagGroup | NetBIOSFlagActive | 0x6000
+)
+
+func TestParseNBSTATResponse(t *testing.T) {
+	workstation := nbstatResponse(0x1234, []NetBIOSName{
+		{Name: "WORKSTATION1", Suffix: 0x00, Flags: nbUnique},
+		{Name: "WORKSTATION1", Suffix: 0x20, Flags: nbUnique},
+		{Name: "WORKGROUP", Suffix: 0x00, Flags: nbGroup},
+		{Name: "WORKGROUP", Suffix: 0x1e, Flags: nbGroup},
+		{Name: "WORKSTATION1", Suffix: 0x03, Flags: nbUnique},
+		{Name: "ALICE", Suffix: 0x03, Flags: nbUnique},
+	}, []byte{0x00, 0x0c, 0x29, 0x12, 0x34, 0x56})
+
+	// A domain controller lists its group names first; none of them may be
+	// taken for the computer name.
+	controller := nbstatResponse(0x1234, []NetBIOSName{
+		{Name: "CORP", Suffix: 0x00, Flags: nbGroup},
+		{Name: "CORP", Suffix: 0x1c, Flags: nbGroup},
+		{Name: "\x01\x02__MSBROWSE__\x02", Suffix: 0x01, Flags: nbGroup},
+		{Name: "DC01", Suffix: 0x00, Flags: nbUnique},
+		{Name: "CORP", Suffix: 0x1b, Flags: nbUnique},
+	}, []byte{0x00, 0x15, 0x5d, 0x01, 0x02, 0x03})
+
+	// Samba reports an all-zero unit ID
+	samba := nbstatResponse(0x1234, []NetBIOSName{
+		{Name: "FILESERVER", Suffix: 0x00, Flags: nbUnique},
+		{Name: "HOME", Suffix: 0x00, Flags: nbGroup},
+	}, nil)
+
+	tests := []struct {
+		name         string
+		resp         []byte
+		computerName string
+		workgroup    string
+		user         string
+		dc           bool
+		mac          string
+		names        int
+	}{
+		{"workstation", workstation, "WORKSTATION1", "WORKGROUP", "ALICE", false, "00:0c:29:12:34:56", 6},
+		{"domain controller", controller, "DC01", "CORP", "", true, "00:15:5d:01:02:03", 5},
+		{"samba", samba, "FILESERVER", "HOME", "", false, "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseNBSTATReply(tt.resp, 0x1234)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ComputerName != tt.computerName || info.Workgroup != tt.workgroup || info.User != tt.user ||
+				info.IsDomainController != tt.dc || info.MAC != tt.mac || len(info.Names) != tt.names {
+				t.Errorf("got %q/%q/%q dc=%v mac=%q %d names, want %q/%q/%q dc=%v mac=%q %d names",
+					info.ComputerName, info.Workgroup, info.User, info.IsDomainController, info.MAC, len(info.Names),
+					tt.computerName, tt.workgroup, tt.user, tt.dc, tt.mac, tt.names)
+			}
+		})
+	}
+
+	if got := (NetBIOSName{Flags: nbGroup}).NodeType(); got != "H" {
+		t.Errorf("NodeType = %q, want H", got)
+	}
+}
+
+func TestParseNBSTATResponseErrors(t *testing.T) {
+	full := nbstatResponse(0x1234, []NetBIOSName{
+		{Name: "HOST", Suffix: 0x00, Flags: nbUnique},
+		{Name: "WORKGROUP", Suffix: 0x00, Flags: nbGroup},
+	}, []byte{0x00, 0x0c, 0x29, 0x12, 0x34, 0x56})
+	headerEnd := 12 + 34 + 10
+
+	// The same response with its name count raised past the names present
+	overcount := append([]byte(nil), full...)
+	overcount[headerEnd] = 9
+
+	tests := []struct {
+		name  string
+		resp  []byte
+		short bool
+	}{
+		{"empty", nil, true},
+		{"header only", full[:12], true},
+		{"cut in question name", full[:30], true},
+		{"cut in record header", full[:headerEnd-4], true},
+		{"cut in name table", full[:headerEnd+20], true},
+		{"name count past rdata", overcount, true},
+		{"wrong transaction ID", nbstatResponse(0x4321, nil, nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseNBSTATReply(tt.resp, 0x1234)
+			if err == nil {
+				t.Fatalf("got %+v, want an error", info)
+			}
+			if short := errors.Is(err, errNBSTATShort); short != tt.short {
+				t.Errorf("error %v: truncation = %v, want %v", err, short, tt.short)
+			}
+		})
+	}
+}
+
+func TestBuildNBSTATQuery(t *testing.T) {
+	q := BuildNBSTATQuery(0xbeef)
+	if len(q) != 50 {
+		t.Fatalf("query is %d bytes, want 50", len(q))
+	}
+	if id := binary.BigEndian.Uint16(q[0:2]); id != 0xbeef {
+		t.Errorf("transaction ID %#04x, want 0xbeef", id)
+	}
+	// "*" followed by 15 NULs encodes as "CK" and 30 "A"s
+	if name := string(q[13:45]); name != "CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA" {
+		t.Errorf("encoded name %q", name)
+	}
+	if typ := binary.BigEndian.Uint16(q[46:48]); typ != nbstatType {
+		t.Errorf("query type %#04x, want NBSTAT", typ)
+	}
+}
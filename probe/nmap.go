@@ -0,0 +1,215 @@
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/Ullaakut/nmap/v2"
+)
+
+// NmapPort is one scanned port with its service detection results.
+type NmapPort struct {
+	Port      uint16
+	Protocol  string // tcp, udp, sctp
+	State     string // open, closed, filtered, open|filtered
+	Service   string // e.g. "http"
+	Product   string // e.g. "nginx"
+	Version   string
+	ExtraInfo string
+	Tunnel    string // "ssl" when the service is wrapped in TLS
+	CPEs      []string
+}
+
+// NmapOSMatch is one OS detection candidate.
+type NmapOSMatch struct {
+	Name     string
+	Accuracy int
+	Vendor   string
+	Family   string
+	Gen      string
+	Type     string // e.g. "general purpose", "router", "printer"
+	CPEs     []string
+}
+
+// NmapHop is one traceroute hop.
+type NmapHop struct {
+	TTL  int
+	IP   string
+	Host string
+	RTT  string // milliseconds, as reported by nmap
+}
+
+// NmapHostResult is the structured result for one scanned host.
+type NmapHostResult struct {
+	Status    string // up or down
+	Addresses []string
+	MAC       string
+	MACVendor string
+	Hostnames []string
+	Ports     []NmapPort
+	OSMatches []NmapOSMatch // best match first
+	Hops      []NmapHop
+}
+
+// NmapFingerprint runs a service and OS detection scan against one host and
+// returns the parsed XML result.
+func NmapFingerprint(ip string) (*NmapHostResult, error) {
+	// Use -Pn to skip host discovery (faster if ICMP is blocked),
+	// -sS for a quick SYN scan, -T4 for speed, and --open to ignore closed ports.
+	args := []string{"-Pn", "-sS", "-sV", "-O", "--osscan-limit", "--traceroute",
+		"-T4", "--open", "--max-retries", "2", "--host-timeout", "30s", "-oX", "-"}
+	if isIPv6Target(ip) {
+		args = append(args, "-6")
+	}
+	cmd := exec.Command("nmap", append(args, ip)...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		log.Printf("[NmapFingerprint] Error running nmap on %s: %v: %s", ip, err, strings.TrimSpace(stderr.String()))
+		return nil, fmt.Errorf("nmap %s: %v", ip, err)
+	}
+
+	hosts, err := ParseNmapXML(out.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("nmap %s: no host in result", ip)
+	}
+	return &hosts[0], nil
+}
+
+// ParseNmapXML converts nmap -oX output into per-host results.
+func ParseNmapXML(data []byte) ([]NmapHostResult, error) {
+	run, err := nmap.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing nmap XML: %v", err)
+	}
+
+	results := make([]NmapHostResult, 0, len(run.Hosts))
+	for _, h := range run.Hosts {
+		r := NmapHostResult{Status: h.Status.State}
+		for _, a := range h.Addresses {
+			if a.AddrType == "mac" {
+				r.MAC = strings.ToLower(a.Addr)
+				r.MACVendor = a.Vendor
+				continue
+			}
+			r.Addresses = append(r.Addresses, a.Addr)
+		}
+		for _, hn := range h.Hostnames {
+			r.Hostnames = appendUnique(r.Hostnames, hn.Name)
+		}
+
+		for _, p := range h.Ports {
+			r.Ports = append(r.Ports, NmapPort{
+				Port:      p.ID,
+				Protocol:  p.Protocol,
+				State:     p.State.State,
+				Service:   p.Service.Name,
+				Product:   p.Service.Product,
+				Version:   p.Service.Version,
+				ExtraInfo: p.Service.ExtraInfo,
+				Tunnel:    p.Service.Tunnel,
+				CPEs:      cpeStrings(p.Service.CPEs),
+			})
+		}
+
+		for _, m := range h.OS.Matches {
+			match := NmapOSMatch{Name: m.Name, Accuracy: m.Accuracy}
+			if len(m.Classes) > 0 {
+				c := m.Classes[0]
+				match.Vendor = c.Vendor
+				match.Family = c.Family
+				match.Gen = c.OSGeneration
+				match.Type = c.Type
+			}
+			for _, c := range m.Classes {
+				for _, cpe := range cpeStrings(c.CPEs) {
+					match.CPEs = appendUnique(match.CPEs, cpe)
+				}
+			}
+			r.OSMatches = append(r.OSMatches, match)
+		}
+
+		for _, hop := range h.Trace.Hops {
+			r.Hops = append(r.Hops, NmapHop{
+				TTL:  int(hop.TTL),
+				IP:   hop.IPAddr,
+				Host: hop.Host,
+				RTT:  hop.RTT,
+			})
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func cpeStrings(cpes []nmap.CPE) []string {
+	out := make([]string, 0, len(cpes))
+	for _, c := range cpes {
+		out = append(out, string(c))
+	}
+	return out
+}
+
+// OpenPorts returns the ports nmap reported as open.
+func (r *NmapHostResult) OpenPorts() []NmapPort {
+	var open []NmapPort
+	for _, p := range r.Ports {
+		if p.State == "open" {
+			open = append(open, p)
+		}
+	}
+	return open
+}
+
+// BestOS returns the most accurate OS match, or nil when OS detection did not
+// produce one. Nmap already orders matches by accuracy.
+func (r *NmapHostResult) BestOS() *NmapOSMatch {
+	if len(r.OSMatches) == 0 {
+		return nil
+	}
+	return &r.OSMatches[0]
+}
+
+// String renders a port as "443/tcp https (nginx 1.25.3; TLS)".
+func (p NmapPort) String() string {
+	s := strconv.Itoa(int(p.Port)) + "/" + p.Protocol
+	if p.Service != "" {
+		s += " " + p.Service
+	}
+	info := strings.TrimSpace(p.Product + " " + p.Version)
+	if p.ExtraInfo != "" {
+		info = strings.TrimSpace(info + " " + p.ExtraInfo)
+	}
+	if p.Tunnel == "ssl" {
+		info = strings.TrimSpace(info + "; TLS")
+	}
+	if info != "" {
+		s += " (" + info + ")"
+	}
+	return s
+}
+
+// Summary renders the open ports as a description and the detected service
+// names as a comma-separated protocol list.
+func (r *NmapHostResult) Summary() (string, string) {
+	var descParts, protocols []string
+	for _, p := range r.OpenPorts() {
+		descParts = append(descParts, p.String())
+		if p.Service != "" {
+			protocols = appendUnique(protocols, p.Service)
+		}
+	}
+	if os := r.BestOS(); os != nil {
+		descParts = append(descParts, fmt.Sprintf("OS: %s (%d%%)", os.Name, os.Accuracy))
+	}
+	return strings.Join(descParts, "; "), strings.Join(protocols, ",")
+}
@@ -0,0 +1,128 @@
+package probe
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readNmapFixture(t *testing.T, name string) []NmapHostResult {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := ParseNmapXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hosts
+}
+
+func TestParseNmapXML(t *testing.T) {
+	hosts := readNmapFixture(t, "nmap_host.xml")
+	if len(hosts) != 1 {
+		t.Fatalf("got %d hosts, want 1", len(hosts))
+	}
+	linux := []string{"cpe:/o:linux:linux_kernel"}
+	nginx := []string{"cpe:/a:igor_sysoev:nginx:1.18.0", "cpe:/o:linux:linux_kernel"}
+	want := NmapHostResult{
+		Status:    "up",
+		Addresses: []string{"192.0.2.10"},
+		MAC:       "00:1b:21:3a:4f:10",
+		MACVendor: "Intel Corporate",
+		Hostnames: []string{"web01.example.net", "www.example.net"},
+		Ports: []NmapPort{
+			{Port: 22, Protocol: "tcp", State: "open", Service: "ssh", Product: "OpenSSH", Version: "8.9p1 Ubuntu 3ubuntu0.6",
+				ExtraInfo: "Ubuntu Linux; protocol 2.0", CPEs: append([]string{"cpe:/a:openbsd:openssh:8.9p1"}, linux...)},
+			{Port: 25, Protocol: "tcp", State: "closed", Service: "smtp", CPEs: []string{}},
+			{Port: 80, Protocol: "tcp", State: "open", Service: "http", Product: "nginx", Version: "1.18.0", ExtraInfo: "Ubuntu", CPEs: nginx},
+			{Port: 139, Protocol: "tcp", State: "filtered", Service: "netbios-ssn", CPEs: []string{}},
+			{Port: 443, Protocol: "tcp", State: "open", Service: "http", Product: "nginx", Version: "1.18.0", ExtraInfo: "Ubuntu",
+				Tunnel: "ssl", CPEs: nginx},
+			{Port: 8080, Protocol: "tcp", State: "open", Service: "http-proxy", CPEs: []string{}},
+		},
+		OSMatches: []NmapOSMatch{
+			{Name: "Linux 5.0 - 5.14", Accuracy: 98, Vendor: "Linux", Family: "Linux", Gen: "5.X", Type: "general purpose",
+				CPEs: []string{"cpe:/o:linux:linux_kernel:5"}},
+			{Name: "Linux 4.15 - 5.8", Accuracy: 94, Vendor: "Linux", Family: "Linux", Gen: "4.X", Type: "general purpose",
+				CPEs: []string{"cpe:/o:linux:linux_kernel:4", "cpe:/o:linux:linux_kernel:5"}},
+			{Name: "MikroTik RouterOS 7.2 - 7.5 (Linux 5.6.3)", Accuracy: 91, Vendor: "MikroTik", Family: "RouterOS", Gen: "7.X",
+				Type: "router", CPEs: []string{"cpe:/o:mikrotik:routeros:7", "cpe:/o:linux:linux_kernel:5.6.3"}},
+		},
+		Hops: []NmapHop{
+			{TTL: 1, IP: "192.0.2.1", Host: "gw.example.net", RTT: "0.45"},
+			{TTL: 2, IP: "192.0.2.10", Host: "web01.example.net", RTT: "0.62"},
+		},
+	}
+	if !reflect.DeepEqual(hosts[0], want) {
+		t.Errorf("got  %+v\nwant %+v", hosts[0], want)
+	}
+	if best := hosts[0].BestOS(); best == nil || best.Name != "Linux 5.0 - 5.14" {
+		t.Errorf("BestOS = %+v", best)
+	}
+}
+
+func TestParseNmapXMLSweep(t *testing.T) {
+	hosts := readNmapFixture(t, "nmap_sweep.xml")
+	if len(hosts) != 2 {
+		t.Fatalf("got %d hosts, want 2", len(hosts))
+	}
+	tests := []struct {
+		status, addr, mac, vendor string
+		ports                     []string
+	}{
+		{"up", "192.0.2.1", "4c:5e:0c:12:34:56", "Routerboard.com",
+			[]string{"53/udp domain (dnsmasq 2.89)", "123/udp ntp", "161/udp snmp (MikroTik RouterOS SNMPd)"}},
+		{"down", "192.0.2.99", "", "", nil},
+	}
+	for i, tt := range tests {
+		h := hosts[i]
+		if h.Status != tt.status || !reflect.DeepEqual(h.Addresses, []string{tt.addr}) || h.MAC != tt.mac || h.MACVendor != tt.vendor {
+			t.Errorf("host %d = %s %v %s %q, want %s %s %s %q", i, h.Status, h.Addresses, h.MAC, h.MACVendor,
+				tt.status, tt.addr, tt.mac, tt.vendor)
+		}
+		var ports []string
+		for _, p := range h.Ports {
+			ports = append(ports, p.String())
+		}
+		if !reflect.DeepEqual(ports, tt.ports) {
+			t.Errorf("host %d ports = %q, want %q", i, ports, tt.ports)
+		}
+		if len(h.Hostnames) != 0 || len(h.OSMatches) != 0 || h.BestOS() != nil || len(h.Hops) != 0 {
+			t.Errorf("host %d has hostnames, OS matches or hops: %+v", i, h)
+		}
+	}
+}
+
+func TestNmapSummary(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		host      int
+		desc      string
+		protocols string
+	}{
+		{
+			"nmap_host.xml", 0,
+			"22/tcp ssh (OpenSSH 8.9p1 Ubuntu 3ubuntu0.6 Ubuntu Linux; protocol 2.0); 80/tcp http (nginx 1.18.0 Ubuntu); " +
+				"443/tcp http (nginx 1.18.0 Ubuntu; TLS); 8080/tcp http-proxy; OS: Linux 5.0 - 5.14 (98%)",
+			"ssh,http,http-proxy",
+		},
+		// open|filtered is not open
+		{"nmap_sweep.xml", 0, "53/udp domain (dnsmasq 2.89); 161/udp snmp (MikroTik RouterOS SNMPd)", "domain,snmp"},
+		{"nmap_sweep.xml", 1, "", ""},
+	}
+	for _, tt := range tests {
+		h := readNmapFixture(t, tt.fixture)[tt.host]
+		desc, protocols := h.Summary()
+		if desc != tt.desc || protocols != tt.protocols {
+			t.Errorf("%s host %d: Summary() =\n %q, %q\nwant\n %q, %q", tt.fixture, tt.host, desc, protocols, tt.desc, tt.protocols)
+		}
+	}
+}
+
+func TestParseNmapXMLInvalid(t *testing.T) {
+	if _, err := ParseNmapXML([]byte("Starting Nmap 7.94 ( https://nmap.org )\n")); err == nil {
+		t.Error("expected an error for non-XML output")
+	}
+}
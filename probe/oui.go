@@ -0,0 +1,241 @@
+package probe
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ouiRegistryData is the IEEE registry as a gzipped CSV with the columns
+// Registry, Assignment and Organization Name. The current copy holds the MA-L
+// assignments only, so addresses in MA-M and MA-S blocks resolve to their
+// MA-L entry, "IEEE Registration Authority". Rebuild it from all three IEEE
+// files with cmd/oui-update, or merge mam.csv and oui36.csv at run time with
+// LoadOUIFile.
+//
+//go:embed oui.csv.gz
+var ouiRegistryData []byte
+
+// MACVendor describes who a MAC address belongs to.
+type MACVendor struct {
+	Organization string // empty when the prefix is not registered
+	Registry     string // MA-L, MA-M or MA-S
+	Prefix       string // registered prefix, e.g. "00:0C:42" or "70:B3:D5:1C:4"
+	// Local is set for locally administered addresses: randomized privacy
+	// addresses (phones, Windows, macOS) and virtual interfaces. They are
+	// not registered, so Organization is always empty.
+	Local     bool
+	Multicast bool
+}
+
+// OUIRegistry maps MAC prefixes to organizations with longest-prefix
+// matching across the 24-bit (MA-L), 28-bit (MA-M) and 36-bit (MA-S) blocks.
+type OUIRegistry struct {
+	prefixes map[int]map[uint64]ouiEntry // prefix length in bits -> prefix -> entry
+}
+
+type ouiEntry struct {
+	registry     string
+	organization string
+}
+
+// ouiPrefixBits are the assignment sizes, longest first.
+var ouiPrefixBits = []int{36, 28, 24}
+
+var (
+	defaultOUIOnce     sync.Once
+	defaultOUIRegistry *OUIRegistry
+)
+
+// DefaultOUIRegistry returns the embedded registry, decoded on first use.
+func DefaultOUIRegistry() *OUIRegistry {
+	defaultOUIOnce.Do(func() {
+		zr, err := gzip.NewReader(bytes.NewReader(ouiRegistryData))
+		if err != nil {
+			panic("probe: invalid embedded OUI registry: " + err.Error())
+		}
+		reg := NewOUIRegistry()
+		if err := reg.ReadCSV(zr); err != nil {
+			panic("probe: invalid embedded OUI registry: " + err.Error())
+		}
+		defaultOUIRegistry = reg
+	})
+	return defaultOUIRegistry
+}
+
+// NewOUIRegistry returns an empty registry.
+func NewOUIRegistry() *OUIRegistry {
+	reg := &OUIRegistry{prefixes: make(map[int]map[uint64]ouiEntry)}
+	for _, bits := range ouiPrefixBits {
+		reg.prefixes[bits] = make(map[uint64]ouiEntry)
+	}
+	return reg
+}
+
+// ReadCSV adds the assignments of a CSV in the IEEE layout (oui.csv, mam.csv,
+// oui36.csv).
+func (reg *OUIRegistry) ReadCSV(r io.Reader) error {
+	return ReadOUICSV(r, reg.Add)
+}
+
+// ReadOUICSV calls fn with the Assignment, Registry and Organization Name of
+// each row of a CSV in the IEEE layout. Columns are found by header name, so
+// the full IEEE files and the trimmed embedded copy both load.
+func ReadOUICSV(r io.Reader, fn func(assignment, registry, organization string) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("error reading OUI header: %v", err)
+	}
+	col := map[string]int{"Registry": -1, "Assignment": -1, "Organization Name": -1}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if _, ok := col[name]; ok {
+			col[name] = i
+		}
+	}
+	for name, i := range col {
+		if i < 0 {
+			return fmt.Errorf("OUI CSV is missing the %q column", name)
+		}
+	}
+
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) <= col["Organization Name"] || len(rec) <= col["Assignment"] || len(rec) <= col["Registry"] {
+			return fmt.Errorf("OUI CSV line %d: too few columns", line)
+		}
+		if err := fn(rec[col["Assignment"]], rec[col["Registry"]], rec[col["Organization Name"]]); err != nil {
+			return fmt.Errorf("OUI CSV line %d: %v", line, err)
+		}
+	}
+}
+
+// Add registers an assignment given as hex digits: 6 for MA-L, 7 for MA-M and
+// 9 for MA-S.
+func (reg *OUIRegistry) Add(assignment, registry, organization string) error {
+	assignment = strings.ToUpper(strings.TrimSpace(assignment))
+	bits := len(assignment) * 4
+	table, ok := reg.prefixes[bits]
+	if !ok {
+		return fmt.Errorf("assignment %q has an unsupported length", assignment)
+	}
+	prefix, err := strconv.ParseUint(assignment, 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid assignment %q", assignment)
+	}
+	table[prefix] = ouiEntry{
+		registry:     strings.TrimSpace(registry),
+		organization: strings.TrimSpace(organization),
+	}
+	return nil
+}
+
+// Len returns the number of assignments.
+func (reg *OUIRegistry) Len() int {
+	n := 0
+	for _, table := range reg.prefixes {
+		n += len(table)
+	}
+	return n
+}
+
+// Lookup identifies the owner of a MAC address in any notation net.ParseMAC
+// accepts.
+func (reg *OUIRegistry) Lookup(mac string) (MACVendor, error) {
+	hw, err := net.ParseMAC(strings.TrimSpace(mac))
+	if err != nil {
+		return MACVendor{}, err
+	}
+	if len(hw) < 6 {
+		return MACVendor{}, fmt.Errorf("invalid MAC address %q", mac)
+	}
+	v := MACVendor{
+		Local:     hw[0]&0x02 != 0,
+		Multicast: hw[0]&0x01 != 0,
+	}
+	if v.Local {
+		return v, nil
+	}
+
+	// The first 36 bits of the address as an integer.
+	var addr uint64
+	for _, b := range hw[:5] {
+		addr = addr<<8 | uint64(b)
+	}
+	addr >>= 4
+	for _, bits := range ouiPrefixBits {
+		prefix := addr >> (36 - bits)
+		if e, ok := reg.prefixes[bits][prefix]; ok {
+			v.Organization = e.organization
+			v.Registry = e.registry
+			v.Prefix = formatMACPrefix(prefix, bits)
+			return v, nil
+		}
+	}
+	return v, nil
+}
+
+// formatMACPrefix renders a prefix as colon-separated hex, with a trailing
+// nibble for MA-M and MA-S blocks.
+func formatMACPrefix(prefix uint64, bits int) string {
+	digits := fmt.Sprintf("%0*X", bits/4, prefix)
+	var sb strings.Builder
+	for i := 0; i < len(digits); i += 2 {
+		if i > 0 {
+			sb.WriteByte(':')
+		}
+		end := i + 2
+		if end > len(digits) {
+			end = len(digits)
+		}
+		sb.WriteString(digits[i:end])
+	}
+	return sb.String()
+}
+
+// LookupVendor returns the organization a MAC address is registered to,
+// "Locally administered" for randomized and virtual addresses, or "" when
+// the address is invalid or unregistered.
+func LookupVendor(mac string) string {
+	v, err := DefaultOUIRegistry().Lookup(mac)
+	switch {
+	case err != nil:
+		return ""
+	case v.Local:
+		return "Locally administered"
+	default:
+		return v.Organization
+	}
+}
+
+// LoadOUIFile merges an IEEE CSV into the default registry, so a freshly
+// downloaded registry can be used without rebuilding. Call it before any
+// lookups start.
+func LoadOUIFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := DefaultOUIRegistry().ReadCSV(f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
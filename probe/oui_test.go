@@ -0,0 +1,110 @@
+package probe
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// loadOUISample reads testdata/oui_sample.csv, a few rows from each IEEE
+// registry in the layout of the published files.
+func loadOUISample(t *testing.T) *OUIRegistry {
+	t.Helper()
+	f, err := os.Open("testdata/oui_sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reg := NewOUIRegistry()
+	if err := reg.ReadCSV(f); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestOUIRegistryLookup(t *testing.T) {
+	reg := loadOUISample(t)
+	if n := reg.Len(); n != 5 {
+		t.Errorf("Len = %d, want 5", n)
+	}
+
+	tests := []struct {
+		name string
+		mac  string
+		want MACVendor
+	}{
+		{
+			"MA-S inside an MA-M block", "70:B3:D5:1C:40:01",
+			MACVendor{Organization: "Example Sensors, Ltd.", Registry: "MA-S", Prefix: "70:B3:D5:1C:4"},
+		},
+		{
+			"MA-M", "70:B3:D5:1F:00:01",
+			MACVendor{Organization: "Example Industrial Controls", Registry: "MA-M", Prefix: "70:B3:D5:1"},
+		},
+		{
+			"MA-M without an MA-L entry", "8c-1f-64-a1-23-45",
+			MACVendor{Organization: "Example Cameras", Registry: "MA-M", Prefix: "8C:1F:64:A"},
+		},
+		{
+			"MA-L fallback", "70:B3:D5:2A:00:01",
+			MACVendor{Organization: "IEEE Registration Authority", Registry: "MA-L", Prefix: "70:B3:D5"},
+		},
+		{
+			"MA-L", "000c.4211.2233",
+			MACVendor{Organization: "Routerboard.com", Registry: "MA-L", Prefix: "00:0C:42"},
+		},
+		{"unregistered", "00:11:32:00:00:01", MACVendor{}},
+		{"locally administered", "da:a1:19:12:34:56", MACVendor{Local: true}},
+		// The locally administered bit wins even over a registered prefix
+		{"locally administered, registered bits", "02:0c:42:11:22:33", MACVendor{Local: true}},
+		{"multicast", "01:00:5e:00:00:fb", MACVendor{Multicast: true}},
+	}
+	for _, tt := range tests {
+		got, err := reg.Lookup(tt.mac)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Lookup(%q) = %+v, want %+v", tt.name, tt.mac, got, tt.want)
+		}
+	}
+
+	if _, err := reg.Lookup("not-a-mac"); err == nil {
+		t.Error("invalid MAC: err = nil")
+	}
+}
+
+func TestReadOUICSVErrors(t *testing.T) {
+	tests := []struct {
+		name, csv, want string
+	}{
+		{"empty", "", "error reading OUI header"},
+		{"missing column", "Registry,Assignment\nMA-L,000C42\n", `missing the "Organization Name" column`},
+		{"short row", "Registry,Assignment,Organization Name\nMA-L,000C42\n", "line 2: too few columns"},
+		{"bad length", "Registry,Assignment,Organization Name\nMA-L,000C42,A\nMA-L,000C4,B\n", "line 3: assignment \"000C4\" has an unsupported length"},
+		{"bad hex", "Registry,Assignment,Organization Name\nMA-L,00GC42,A\n", "line 2: invalid assignment"},
+	}
+	for _, tt := range tests {
+		err := NewOUIRegistry().ReadCSV(strings.NewReader(tt.csv))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLookupVendor(t *testing.T) {
+	tests := []struct {
+		mac, want string
+	}{
+		{"00:0C:42:11:22:33", "Routerboard.com"},
+		{"b8:27:eb:12:34:56", "Raspberry Pi Foundation"},
+		{"da:a1:19:12:34:56", "Locally administered"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := LookupVendor(tt.mac); got != tt.want {
+			t.Errorf("LookupVendor(%q) = %q, want %q", tt.mac, got, tt.want)
+		}
+	}
+}
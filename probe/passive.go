@@ -0,0 +1,243 @@
+package probe
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// passiveBPF selects the traffic PassiveDiscover learns addresses from.
+const passiveBPF = "arp or icmp6 or (udp and (port 67 or port 68))"
+
+// PassiveConfig holds settings for passive discovery.
+type PassiveConfig struct {
+	Interface string        // live interface to listen on
+	PcapFile  string        // read from a capture file instead of Interface
+	Duration  time.Duration // how long to listen on a live interface
+}
+
+// passiveHost accumulates sightings of one IP/MAC pair.
+type passiveHost struct {
+	ip        string
+	mac       string
+	hostname  string
+	firstSeen time.Time
+	lastSeen  time.Time
+	protocols []string
+}
+
+// PassiveDiscover builds an IP-to-MAC inventory from ARP, gratuitous ARP,
+// IPv6 neighbor discovery and DHCP traffic without sending any packets.
+func PassiveDiscover(cfg PassiveConfig) ([]models.Device, error) {
+	var source *gopacket.PacketSource
+	var timeout <-chan time.Time
+	if cfg.PcapFile != "" {
+		// Capture files are read without libpcap; passiveSightings ignores
+		// what the live BPF filter would have dropped.
+		f, fileSource, err := openCaptureFile(cfg.PcapFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		source = fileSource
+	} else {
+		handle, err := pcap.OpenLive(cfg.Interface, 1600, true, 100*time.Millisecond)
+		if err != nil {
+			return nil, fmt.Errorf("error opening interface %s: %v", cfg.Interface, err)
+		}
+		defer handle.Close()
+		if err := handle.SetBPFFilter(passiveBPF); err != nil {
+			return nil, fmt.Errorf("error setting BPF filter: %v", err)
+		}
+		source = gopacket.NewPacketSource(handle, handle.LinkType())
+		timeout = time.After(cfg.Duration)
+	}
+
+	hosts := make(map[string]*passiveHost)
+	packets := source.Packets()
+
+LOOP:
+	for {
+		select {
+		case packet, ok := <-packets:
+			if !ok {
+				break LOOP
+			}
+			for _, s := range passiveSightings(packet) {
+				recordSighting(hosts, s, packet.Metadata().Timestamp)
+			}
+		case <-timeout:
+			break LOOP
+		}
+	}
+
+	devices := passiveDevices(hosts)
+	log.Printf("[Passive] Learned %d address(es).\n", len(devices))
+	return devices, nil
+}
+
+// sighting is one IP/MAC binding observed in a packet.
+type sighting struct {
+	ip       net.IP
+	mac      net.HardwareAddr
+	hostname string
+	protocol string
+}
+
+// passiveSightings extracts the address bindings a packet reveals.
+func passiveSightings(packet gopacket.Packet) []sighting {
+	var ethSrc net.HardwareAddr
+	if eth, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok {
+		ethSrc = eth.SrcMAC
+	}
+
+	if arpLayer, ok := packet.Layer(layers.LayerTypeARP).(*layers.ARP); ok {
+		srcIP := net.IP(arpLayer.SourceProtAddress)
+		// Sender 0.0.0.0 is an ARP probe for duplicate address detection.
+		if srcIP.IsUnspecified() {
+			return nil
+		}
+		protocol := "ARP"
+		if srcIP.Equal(net.IP(arpLayer.DstProtAddress)) {
+			protocol = "GARP"
+		}
+		return []sighting{{ip: srcIP, mac: net.HardwareAddr(arpLayer.SourceHwAddress), protocol: protocol}}
+	}
+
+	if ns, ok := packet.Layer(layers.LayerTypeICMPv6NeighborSolicitation).(*layers.ICMPv6NeighborSolicitation); ok {
+		ip6, _ := packet.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+		// Solicitations from :: are duplicate address detection, not a binding.
+		if ip6 == nil || ip6.SrcIP.IsUnspecified() {
+			return nil
+		}
+		mac := ndpLinkAddress(ns.Options, layers.ICMPv6OptSourceAddress, ethSrc)
+		return []sighting{{ip: ip6.SrcIP, mac: mac, protocol: "NDP"}}
+	}
+
+	if na, ok := packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement).(*layers.ICMPv6NeighborAdvertisement); ok {
+		mac := ndpLinkAddress(na.Options, layers.ICMPv6OptTargetAddress, ethSrc)
+		return []sighting{{ip: na.TargetAddress, mac: mac, protocol: "NDP"}}
+	}
+
+	if dhcp, ok := packet.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4); ok {
+		return dhcpSightings(dhcp)
+	}
+
+	return nil
+}
+
+// dhcpSightings learns the client binding from DHCP requests and acks.
+func dhcpSightings(dhcp *layers.DHCPv4) []sighting {
+	var msgType layers.DHCPMsgType
+	var requested net.IP
+	var hostname string
+	for _, opt := range dhcp.Options {
+		switch opt.Type {
+		case layers.DHCPOptMessageType:
+			if len(opt.Data) == 1 {
+				msgType = layers.DHCPMsgType(opt.Data[0])
+			}
+		case layers.DHCPOptRequestIP:
+			if len(opt.Data) == net.IPv4len {
+				requested = net.IP(opt.Data)
+			}
+		case layers.DHCPOptHostname:
+			hostname = string(opt.Data)
+		}
+	}
+
+	var ip net.IP
+	switch msgType {
+	case layers.DHCPMsgTypeAck:
+		ip = dhcp.YourClientIP
+	case layers.DHCPMsgTypeRequest, layers.DHCPMsgTypeInform:
+		ip = requested
+		if ip == nil || ip.IsUnspecified() {
+			ip = dhcp.ClientIP
+		}
+	}
+	if ip == nil || ip.IsUnspecified() {
+		return nil
+	}
+	return []sighting{{ip: ip, mac: dhcp.ClientHWAddr, hostname: hostname, protocol: "DHCP"}}
+}
+
+// ndpLinkAddress returns the link-layer address option, falling back to the
+// Ethernet source.
+func ndpLinkAddress(opts layers.ICMPv6Options, want layers.ICMPv6Opt, fallback net.HardwareAddr) net.HardwareAddr {
+	for _, o := range opts {
+		if o.Type == want && len(o.Data) == 6 {
+			return net.HardwareAddr(o.Data)
+		}
+	}
+	return fallback
+}
+
+func recordSighting(hosts map[string]*passiveHost, s sighting, ts time.Time) {
+	if s.ip == nil || len(s.mac) == 0 {
+		return
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	ip, mac := s.ip.String(), s.mac.String()
+	key := ip + "|" + mac
+	h, ok := hosts[key]
+	if !ok {
+		h = &passiveHost{ip: ip, mac: mac, firstSeen: ts, lastSeen: ts}
+		hosts[key] = h
+		log.Printf("[Passive] %s is at %s (%s)", ip, mac, s.protocol)
+	}
+	if ts.Before(h.firstSeen) {
+		h.firstSeen = ts
+	}
+	if ts.After(h.lastSeen) {
+		h.lastSeen = ts
+	}
+	if s.hostname != "" {
+		h.hostname = s.hostname
+	}
+	for _, p := range h.protocols {
+		if p == s.protocol {
+			return
+		}
+	}
+	h.protocols = append(h.protocols, s.protocol)
+}
+
+func passiveDevices(hosts map[string]*passiveHost) []models.Device {
+	list := make([]*passiveHost, 0, len(hosts))
+	for _, h := range hosts {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].firstSeen.Equal(list[j].firstSeen) {
+			return list[i].firstSeen.Before(list[j].firstSeen)
+		}
+		return list[i].ip < list[j].ip
+	})
+
+	devices := make([]models.Device, 0, len(list))
+	for _, h := range list {
+		device := models.NewDevice(models.DeviceConfig{
+			Hostname:            h.hostname,
+			IPAddress:           h.ip,
+			DeviceType:          "unknown",
+			Status:              "active",
+			MonitoringProtocols: h.protocols,
+			MACAddress:          h.mac,
+			FirstSeen:           h.firstSeen,
+			LastSeen:            h.lastSeen,
+		})
+		devices = append(devices, *device)
+	}
+	return devices
+}
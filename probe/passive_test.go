@@ -0,0 +1,57 @@
+package probe
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPassiveDiscoverFile(t *testing.T) {
+	devices, err := PassiveDiscover(PassiveConfig{PcapFile: "testdata/passive.pcap"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The capture starts at 12:00:00 with one packet per second. The ARP
+	// probe from 0.0.0.0 and the neighbor solicitation from :: are duplicate
+	// address detection and teach nothing.
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(secs int) time.Time { return t0.Add(time.Duration(secs) * time.Second) }
+	want := []struct {
+		ip, mac, hostname   string
+		firstSeen, lastSeen time.Time
+		protocols           []string
+	}{
+		{"192.0.2.10", "00:11:22:33:44:01", "", at(1), at(10), []string{"ARP"}},
+		{"192.0.2.1", "00:11:22:33:44:02", "", at(2), at(2), []string{"ARP"}},
+		{"192.0.2.20", "00:11:22:33:44:03", "", at(3), at(3), []string{"GARP"}},
+		{"fe80::211:22ff:fe33:4405", "00:11:22:33:44:05", "", at(5), at(5), []string{"NDP"}},
+		{"2001:db8::6", "00:11:22:33:44:06", "", at(6), at(6), []string{"NDP"}},
+		{"192.0.2.50", "00:11:22:33:44:08", "printer", at(8), at(11), []string{"DHCP", "ARP"}},
+	}
+	if len(devices) != len(want) {
+		for _, d := range devices {
+			t.Logf("%s %s %v", d.GetIPAddress(), d.GetMACAddress(), d.GetMonitoringProtocols())
+		}
+		t.Fatalf("got %d devices, want %d", len(devices), len(want))
+	}
+	for i, w := range want {
+		d := devices[i]
+		if d.GetIPAddress() != w.ip || d.GetMACAddress() != w.mac || d.GetHostname() != w.hostname {
+			t.Errorf("device %d = %s/%s/%q, want %s/%s/%q", i,
+				d.GetIPAddress(), d.GetMACAddress(), d.GetHostname(), w.ip, w.mac, w.hostname)
+		}
+		if !d.GetFirstSeen().Equal(w.firstSeen) || !d.GetLastSeen().Equal(w.lastSeen) {
+			t.Errorf("%s seen %v to %v, want %v to %v", w.ip, d.GetFirstSeen(), d.GetLastSeen(), w.firstSeen, w.lastSeen)
+		}
+		if got := d.GetMonitoringProtocols(); !reflect.DeepEqual(got, w.protocols) {
+			t.Errorf("%s protocols = %v, want %v", w.ip, got, w.protocols)
+		}
+	}
+}
+
+func TestPassiveDiscoverMissingFile(t *testing.T) {
+	if _, err := PassiveDiscover(PassiveConfig{PcapFile: "testdata/does-not-exist.pcap"}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,249 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PortState is the scan verdict for one port.
+type PortState string
+
+const (
+	PortOpen     PortState = "open"
+	PortClosed   PortState = "closed"   // host answered with a reset
+	PortFiltered PortState = "filtered" // no answer within the timeout
+
+	// PortOpenFiltered is a UDP port that neither answered nor returned an
+	// ICMP port unreachable.
+	PortOpenFiltered PortState = "open|filtered"
+)
+
+// Port scan methods.
+const (
+	ScanAuto    = "auto"    // SYN when privileged, connect otherwise
+	ScanSYN     = "syn"     // raw SYN (half-open) scan, IPv4 only
+	ScanConnect = "connect" // full TCP handshake through the OS
+)
+
+// CheckScanMethod reports whether method is one of the port scan methods.
+// An empty method means ScanAuto.
+func CheckScanMethod(method string) error {
+	switch method {
+	case "", ScanAuto, ScanSYN, ScanConnect:
+		return nil
+	}
+	return fmt.Errorf("unknown port scan method %q (want %s, %s or %s)", method, ScanAuto, ScanSYN, ScanConnect)
+}
+
+// PortScanConfig holds settings for a TCP port scan.
+type PortScanConfig struct {
+	Ports   []int         // ports to scan (default DefaultPortProfile)
+	Method  string        // ScanAuto (default), ScanSYN or ScanConnect
+	Timeout time.Duration // per-probe reply timeout (default 1s)
+	Workers int           // concurrent connect probes (default 256)
+	Rate    int           // probes per second across all hosts (default 1000)
+	Retries int           // extra SYN probes for unanswered ports (default 1, negative for none)
+}
+
+// PortResult is the state of one host:port.
+type PortResult struct {
+	Host     string
+	Port     int
+	Protocol string // "tcp" or "udp"
+	State    PortState
+	Service  string // service the UDP payload targets, e.g. "snmp"
+}
+
+// String renders the port as "161/udp".
+func (r PortResult) String() string {
+	return strconv.Itoa(r.Port) + "/" + r.Protocol
+}
+
+func (cfg *PortScanConfig) setDefaults() {
+	if len(cfg.Ports) == 0 {
+		cfg.Ports, _ = ParsePorts(DefaultPortProfile)
+	}
+	if cfg.Method == "" {
+		cfg.Method = ScanAuto
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 256
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = 1000
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	} else if cfg.Retries == 0 {
+		cfg.Retries = 1
+	}
+}
+
+// ScanPorts scans every host for the configured ports and reports the state
+// of each. Probes for all hosts share one rate limit. With ScanAuto a raw SYN
+// scan is used for IPv4 hosts when a raw socket can be opened, and a connect
+// scan otherwise (and always for IPv6, host names and hosts without a route).
+// On cancellation the results gathered so far are returned together with
+// ctx.Err().
+func ScanPorts(ctx context.Context, hosts []string, cfg PortScanConfig) ([]PortResult, error) {
+	if err := CheckScanMethod(cfg.Method); err != nil {
+		return nil, err
+	}
+	cfg.setDefaults()
+	limiter := newRateLimiter(cfg.Rate)
+	defer limiter.stop()
+
+	var synHosts, connectHosts []string
+	for _, h := range hosts {
+		if cfg.Method != ScanConnect && !isIPv6Target(h) {
+			synHosts = append(synHosts, h)
+		} else {
+			connectHosts = append(connectHosts, h)
+		}
+	}
+
+	var results []PortResult
+	if len(synHosts) > 0 {
+		syn, err := newSYNScanner()
+		switch {
+		case err == nil:
+			log.Printf("[PortScan] SYN scanning %d host(s), %d port(s) each\n", len(synHosts), len(cfg.Ports))
+			synResults, skipped := syn.scan(ctx, synHosts, cfg, limiter)
+			syn.close()
+			results = append(results, synResults...)
+			if len(skipped) > 0 {
+				log.Printf("[PortScan] %d host(s) cannot be SYN scanned, using connect scan", len(skipped))
+				connectHosts = append(connectHosts, skipped...)
+			}
+		case cfg.Method == ScanSYN:
+			return nil, err
+		default:
+			log.Printf("[PortScan] SYN scan unavailable (%v), using connect scan", err)
+			connectHosts = append(connectHosts, synHosts...)
+		}
+	}
+	if len(connectHosts) > 0 {
+		log.Printf("[PortScan] Connect scanning %d host(s), %d port(s) each\n", len(connectHosts), len(cfg.Ports))
+		results = append(results, connectScan(ctx, connectHosts, cfg, limiter)...)
+	}
+
+	sortPortResults(results)
+	return results, ctx.Err()
+}
+
+// OpenPorts groups the open ports in results by host.
+func OpenPorts(results []PortResult) map[string][]int {
+	open := make(map[string][]int)
+	for _, r := range results {
+		if r.State == PortOpen {
+			open[r.Host] = append(open[r.Host], r.Port)
+		}
+	}
+	return open
+}
+
+// connectScan runs full TCP connects through a worker pool.
+func connectScan(ctx context.Context, hosts []string, cfg PortScanConfig, limiter *rateLimiter) []PortResult {
+	type job struct {
+		host string
+		port int
+	}
+	jobs := make(chan job)
+	var mu sync.Mutex
+	var results []PortResult
+	var wg sync.WaitGroup
+
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dialer := net.Dialer{Timeout: cfg.Timeout}
+			for j := range jobs {
+				state := connectProbe(ctx, &dialer, j.host, j.port)
+				if state == "" {
+					continue // cancelled
+				}
+				mu.Lock()
+				results = append(results, PortResult{Host: j.host, Port: j.port, Protocol: "tcp", State: state})
+				mu.Unlock()
+			}
+		}()
+	}
+
+FEED:
+	for _, port := range cfg.Ports {
+		// Port-major order spreads consecutive probes across hosts.
+		for _, host := range hosts {
+			if err := limiter.wait(ctx); err != nil {
+				break FEED
+			}
+			select {
+			case jobs <- job{host, port}:
+			case <-ctx.Done():
+				break FEED
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// connectProbe classifies a port by dialing it. It returns "" when ctx was
+// cancelled mid-dial.
+func connectProbe(ctx context.Context, dialer *net.Dialer, host string, port int) PortState {
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err == nil {
+		conn.Close()
+		return PortOpen
+	}
+	if ctx.Err() != nil {
+		return ""
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return PortClosed
+	}
+	return PortFiltered
+}
+
+func sortPortResults(results []PortResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return results[i].Host < results[j].Host
+		}
+		return results[i].Port < results[j].Port
+	})
+}
+
+// rateLimiter hands out one probe slot per tick.
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{ticker: time.NewTicker(time.Second / time.Duration(perSecond))}
+}
+
+func (r *rateLimiter) wait(ctx context.Context) error {
+	select {
+	case <-r.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *rateLimiter) stop() {
+	r.ticker.Stop()
+}
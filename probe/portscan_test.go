@@ -0,0 +1,47 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestScanPortsHostName(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// Whether or not a raw socket is available, a host name cannot be SYN
+	// scanned and must fall back to a connect scan.
+	results, err := ScanPorts(context.Background(), []string{"localhost"}, PortScanConfig{
+		Ports:   []int{port},
+		Method:  ScanAuto,
+		Timeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Host != "localhost" || results[0].State != PortOpen {
+		t.Errorf("got %+v, want localhost:%d open", results, port)
+	}
+}
+
+func TestCheckScanMethod(t *testing.T) {
+	for _, method := range []string{"", ScanAuto, ScanSYN, ScanConnect} {
+		if err := CheckScanMethod(method); err != nil {
+			t.Errorf("CheckScanMethod(%q) = %v", method, err)
+		}
+	}
+	for _, method := range []string{"conect", "SYN", "udp"} {
+		if err := CheckScanMethod(method); err == nil {
+			t.Errorf("CheckScanMethod(%q) accepted", method)
+		}
+	}
+	if _, err := ScanPorts(context.Background(), []string{"127.0.0.1"}, PortScanConfig{Method: "conect"}); err == nil {
+		t.Error("ScanPorts accepted an unknown method")
+	}
+}
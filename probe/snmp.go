@@ -1,8 +1,10 @@
 package probe
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
@@ -17,12 +19,14 @@ type SNMPConfig struct {
 	Community string
 	Timeout   time.Duration
 	Retries   int
+	V3        SNMPv3Config // used when Version is gosnmp.Version3
 }
 
-// NewSNMPClient initializes an SNMP client.
+// NewSNMPClient initializes an SNMP client. IPv6 targets may be given with or
+// without brackets.
 func NewSNMPClient(cfg SNMPConfig) *gosnmp.GoSNMP {
-	return &gosnmp.GoSNMP{
-		Target:    cfg.Target,
+	client := &gosnmp.GoSNMP{
+		Target:    strings.Trim(cfg.Target, "[]"),
 		Port:      cfg.Port,
 		Community: cfg.Community,
 		Version:   cfg.Version,
@@ -30,14 +34,34 @@ func NewSNMPClient(cfg SNMPConfig) *gosnmp.GoSNMP {
 		Retries:   cfg.Retries,
 		MaxOids:   gosnmp.MaxOids,
 	}
+	if isIPv6Target(client.Target) {
+		client.Transport = "udp6"
+	}
+	if cfg.Version == gosnmp.Version3 {
+		cfg.V3.apply(client)
+	}
+	return client
 }
 
-// FetchMetrics queries SNMP for a list of OIDs and returns results as a map.
-func FetchMetrics(cfg SNMPConfig, oids []string) (*models.SNMPResult, error) {
+// connectSNMP validates the configuration and opens a client.
+func connectSNMP(cfg SNMPConfig) (*gosnmp.GoSNMP, error) {
+	if cfg.Version == gosnmp.Version3 {
+		if err := cfg.V3.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	client := NewSNMPClient(cfg)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
 
+// FetchMetrics queries SNMP for a list of OIDs and returns results as a map.
+func FetchMetrics(cfg SNMPConfig, oids []string) (*models.SNMPResult, error) {
 	// Establish connection
-	if err := client.Connect(); err != nil {
+	client, err := connectSNMP(cfg)
+	if err != nil {
 		return nil, fmt.Errorf("[SNMP] connection failed for %s: %v", cfg.Target, err)
 	}
 	defer client.Conn.Close()
@@ -50,7 +74,12 @@ func FetchMetrics(cfg SNMPConfig, oids []string) (*models.SNMPResult, error) {
 
 	metrics := make(map[string]string)
 	for _, variable := range pdu.Variables {
-		metrics[variable.Name] = fmt.Sprintf("%v", variable.Value)
+		switch variable.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+			// Not implemented by this agent
+			continue
+		}
+		metrics[variable.Name] = formatSNMPValue(variable)
 	}
 
 	return &models.SNMPResult{
@@ -59,46 +88,33 @@ func FetchMetrics(cfg SNMPConfig, oids []string) (*models.SNMPResult, error) {
 	}, nil
 }
 
-// FetchCommonDeviceMetrics retrieves uptime, CPU, and memory utilization if available.
+// FetchCommonDeviceMetrics retrieves uptime, CPU, and memory utilization if
+// available; see FetchHostResources for the full figures.
 func FetchCommonDeviceMetrics(cfg SNMPConfig) (uptime string, cpu, mem float64) {
-	commonOIDs := map[string]string{
-		"sysUpTime": ".1.3.6.1.2.1.1.3.0",  // Uptime
-		"cpuLoad":   ".1.3.6.1.4.1.2021.10.1.3.1", // CPU (example for UCD-SNMP)
-		"memAvail":  ".1.3.6.1.4.1.2021.4.6.0",   // Memory (example for UCD-SNMP)
-	}
-
-	res, err := FetchMetrics(cfg, []string{
-		commonOIDs["sysUpTime"], commonOIDs["cpuLoad"], commonOIDs["memAvail"],
-	})
+	hr, err := FetchHostResources(cfg)
 	if err != nil {
 		log.Printf("[SNMP] Failed to fetch common metrics from %s: %v", cfg.Target, err)
 		return "", 0, 0
 	}
-
-	if val, ok := res.Metrics.Values[commonOIDs["sysUpTime"]]; ok {
-		uptime = val
-	}
-	if val, ok := res.Metrics.Values[commonOIDs["cpuLoad"]]; ok {
-		fmt.Sscanf(val, "%f", &cpu)
-	}
-	if val, ok := res.Metrics.Values[commonOIDs["memAvail"]]; ok {
-		fmt.Sscanf(val, "%f", &mem)
-	}
-
-	return
+	return FormatUptime(hr.Uptime), hr.CPU, hr.Mem
 }
 
 // BulkWalkMetrics performs a BULK WALK for a base OID, useful for interfaces or routing tables.
 func BulkWalkMetrics(cfg SNMPConfig, baseOID string) (map[string]string, error) {
-	client := NewSNMPClient(cfg)
-	if err := client.Connect(); err != nil {
+	client, err := connectSNMP(cfg)
+	if err != nil {
 		return nil, fmt.Errorf("[SNMP] BulkWalk connection failed for %s: %v", cfg.Target, err)
 	}
 	defer client.Conn.Close()
 
 	metrics := make(map[string]string)
-	err := client.BulkWalk(baseOID, func(pdu gosnmp.SnmpPDU) error {
-		metrics[pdu.Name] = fmt.Sprintf("%v", pdu.Value)
+	walk := client.BulkWalk
+	if cfg.Version == gosnmp.Version1 {
+		// GETBULK does not exist in SNMPv1
+		walk = client.Walk
+	}
+	err = walk(baseOID, func(pdu gosnmp.SnmpPDU) error {
+		metrics[pdu.Name] = formatSNMPValue(pdu)
 		return nil
 	})
 	if err != nil {
@@ -107,3 +123,82 @@ func BulkWalkMetrics(cfg SNMPConfig, baseOID string) (map[string]string, error)
 
 	return metrics, nil
 }
+
+// snmpTable holds walked table values keyed by row index, then column number.
+type snmpTable map[string]map[int]string
+
+// walkTable walks a conceptual table entry OID (e.g. ifEntry) and groups the
+// returned columns by their row index.
+func walkTable(cfg SNMPConfig, entryOID string) (snmpTable, error) {
+	values, err := BulkWalkMetrics(cfg, entryOID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "." + strings.Trim(entryOID, ".") + "."
+	table := make(snmpTable)
+	for name, value := range values {
+		rest := strings.TrimPrefix("."+strings.TrimPrefix(name, "."), prefix)
+		col, index, ok := strings.Cut(rest, ".")
+		if !ok {
+			continue
+		}
+		var column int
+		if _, err := fmt.Sscan(col, &column); err != nil {
+			continue
+		}
+		if table[index] == nil {
+			table[index] = make(map[int]string)
+		}
+		table[index][column] = value
+	}
+	return table, nil
+}
+
+// formatSNMPValue renders a PDU value as a string. OCTET STRINGs are returned
+// as text when printable and as colon-separated hex otherwise (MACs, raw IPs).
+func formatSNMPValue(pdu gosnmp.SnmpPDU) string {
+	if b, ok := pdu.Value.([]byte); ok {
+		return formatOctets(b)
+	}
+	return fmt.Sprintf("%v", pdu.Value)
+}
+
+func formatOctets(b []byte) string {
+	trimmed := strings.TrimRight(string(b), "\x00")
+	printable := len(trimmed) > 0
+	for _, c := range []byte(trimmed) {
+		if (c < 0x20 || c > 0x7e) && c != '\t' && c != '\r' && c != '\n' {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return trimmed
+	}
+
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseOctets reverses formatOctets, returning the raw bytes of a value.
+func parseOctets(value string) []byte {
+	if len(value)%3 != 2 {
+		return []byte(value)
+	}
+	raw := make([]byte, 0, (len(value)+1)/3)
+	for i := 0; i < len(value); i += 3 {
+		if i+2 < len(value) && value[i+2] != ':' {
+			return []byte(value)
+		}
+		b, err := hex.DecodeString(value[i : i+2])
+		if err != nil {
+			return []byte(value)
+		}
+		raw = append(raw, b[0])
+	}
+	return raw
+}
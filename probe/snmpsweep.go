@@ -0,0 +1,139 @@
+package probe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// snmpProbeOID is fetched to test a credential set: sysObjectID is
+// implemented by every agent and visible to every view.
+const snmpProbeOID = ".1.3.6.1.2.1.1.2.0"
+
+// ErrNoSNMPCredentials is returned when a device accepts none of the
+// configured credential sets.
+var ErrNoSNMPCredentials = errors.New("no SNMP credentials accepted")
+
+// SNMPSweeper finds the credential set each device answers to. Sets are
+// tried SNMPv3 first, then v2c, then v1, in file order within a version, and
+// the one that worked is remembered per target so later polls try it first.
+// It is safe for concurrent use.
+type SNMPSweeper struct {
+	profiles []SNMPCredentials
+
+	mu      sync.Mutex
+	path    string
+	Targets map[string]string `json:"targets"` // target -> credentials name
+}
+
+// NewSNMPSweeper orders the credential sets and loads the per-target cache
+// from cachePath. An empty cachePath keeps the cache in memory; a missing
+// file yields an empty cache that Save will create.
+func NewSNMPSweeper(profiles []SNMPCredentials, cachePath string) (*SNMPSweeper, error) {
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no SNMP credentials configured")
+	}
+	ordered := append([]SNMPCredentials(nil), profiles...)
+	for i := range ordered {
+		if err := ordered[i].Validate(); err != nil {
+			return nil, fmt.Errorf("credentials %d: %v", i+1, err)
+		}
+		if ordered[i].Name == "" {
+			ordered[i].Name = ordered[i].defaultName(i + 1)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return snmpVersionRank(ordered[i]) < snmpVersionRank(ordered[j])
+	})
+
+	s := &SNMPSweeper{profiles: ordered, path: cachePath, Targets: make(map[string]string)}
+	if cachePath == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("error parsing SNMP credential cache %s: %v", cachePath, err)
+	}
+	if s.Targets == nil {
+		s.Targets = make(map[string]string)
+	}
+	return s, nil
+}
+
+// snmpVersionRank orders the strongest security first.
+func snmpVersionRank(c SNMPCredentials) int {
+	switch v, _ := c.SNMPVersion(); v {
+	case gosnmp.Version3:
+		return 0
+	case gosnmp.Version2c:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Find returns base configured with the first credential set the target
+// answers to, and the name of that set. The remembered set is tried first;
+// when it stops working the others are swept again.
+func (s *SNMPSweeper) Find(base SNMPConfig) (SNMPConfig, string, error) {
+	s.mu.Lock()
+	cached := s.Targets[base.Target]
+	s.mu.Unlock()
+
+	candidates := make([]SNMPCredentials, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		if p.Name == cached {
+			candidates = append([]SNMPCredentials{p}, candidates...)
+		} else {
+			candidates = append(candidates, p)
+		}
+	}
+
+	for _, p := range candidates {
+		cfg, err := p.Apply(base)
+		if err != nil {
+			continue
+		}
+		if _, err := FetchMetrics(cfg, []string{snmpProbeOID}); err != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.Targets[base.Target] = p.Name
+		s.mu.Unlock()
+		return cfg, p.Name, nil
+	}
+
+	s.mu.Lock()
+	delete(s.Targets, base.Target)
+	s.mu.Unlock()
+	return base, "", ErrNoSNMPCredentials
+}
+
+// Save writes the per-target cache back to its file.
+func (s *SNMPSweeper) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
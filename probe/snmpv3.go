@@ -0,0 +1,294 @@
+package probe
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// SNMPv3 security levels.
+const (
+	SNMPNoAuthNoPriv = "noAuthNoPriv"
+	SNMPAuthNoPriv   = "authNoPriv"
+	SNMPAuthPriv     = "authPriv"
+)
+
+// SNMPv3Config holds the user-based security model (USM) settings of an
+// SNMPv3 user.
+type SNMPv3Config struct {
+	User string `json:"user"`
+	// SecurityLevel is noAuthNoPriv, authNoPriv or authPriv. When empty it
+	// follows from which passphrases are set.
+	SecurityLevel  string `json:"security_level,omitempty"`
+	AuthProtocol   string `json:"auth_protocol,omitempty"` // MD5, SHA, SHA224, SHA256, SHA384 or SHA512
+	AuthPassphrase string `json:"auth_passphrase,omitempty"`
+	PrivProtocol   string `json:"priv_protocol,omitempty"` // DES, AES, AES192, AES256, AES192C or AES256C
+	PrivPassphrase string `json:"priv_passphrase,omitempty"`
+	ContextName    string `json:"context_name,omitempty"`
+	// ContextEngineID and EngineID are hex strings. The authoritative engine
+	// ID is discovered from the agent when EngineID is empty.
+	ContextEngineID string `json:"context_engine_id,omitempty"`
+	EngineID        string `json:"engine_id,omitempty"`
+}
+
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA1":   gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+}
+
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES128":  gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C,
+	"AES256C": gosnmp.AES256C,
+}
+
+// Level returns the security level, derived from the passphrases when it is
+// not set explicitly.
+func (c SNMPv3Config) Level() string {
+	switch {
+	case c.SecurityLevel != "":
+		return c.SecurityLevel
+	case c.PrivPassphrase != "":
+		return SNMPAuthPriv
+	case c.AuthPassphrase != "":
+		return SNMPAuthNoPriv
+	default:
+		return SNMPNoAuthNoPriv
+	}
+}
+
+// Validate checks that the settings are complete for the security level.
+func (c SNMPv3Config) Validate() error {
+	if c.User == "" {
+		return fmt.Errorf("SNMPv3 user is required")
+	}
+	level := c.Level()
+	switch level {
+	case SNMPNoAuthNoPriv:
+		return c.validateIDs()
+	case SNMPAuthNoPriv, SNMPAuthPriv:
+	default:
+		return fmt.Errorf("unknown SNMPv3 security level %q", c.SecurityLevel)
+	}
+
+	if _, ok := snmpAuthProtocols[strings.ToUpper(c.authProtocol())]; !ok {
+		return fmt.Errorf("unknown SNMPv3 auth protocol %q", c.AuthProtocol)
+	}
+	// RFC 3414 requires passphrases of at least 8 characters; agents reject
+	// shorter ones with an opaque authentication failure.
+	if len(c.AuthPassphrase) < 8 {
+		return fmt.Errorf("SNMPv3 %s needs an auth passphrase of at least 8 characters", level)
+	}
+	if level == SNMPAuthPriv {
+		if _, ok := snmpPrivProtocols[strings.ToUpper(c.privProtocol())]; !ok {
+			return fmt.Errorf("unknown SNMPv3 privacy protocol %q", c.PrivProtocol)
+		}
+		if len(c.PrivPassphrase) < 8 {
+			return fmt.Errorf("SNMPv3 authPriv needs a privacy passphrase of at least 8 characters")
+		}
+	}
+	return c.validateIDs()
+}
+
+func (c SNMPv3Config) validateIDs() error {
+	if _, err := hex.DecodeString(c.ContextEngineID); err != nil {
+		return fmt.Errorf("invalid SNMPv3 context engine ID %q: %v", c.ContextEngineID, err)
+	}
+	if _, err := hex.DecodeString(c.EngineID); err != nil {
+		return fmt.Errorf("invalid SNMPv3 engine ID %q: %v", c.EngineID, err)
+	}
+	return nil
+}
+
+// authProtocol and privProtocol default to SHA and AES, which every agent
+// that supports SNMPv3 implements.
+func (c SNMPv3Config) authProtocol() string {
+	if c.AuthProtocol == "" {
+		return "SHA"
+	}
+	return c.AuthProtocol
+}
+
+func (c SNMPv3Config) privProtocol() string {
+	if c.PrivProtocol == "" {
+		return "AES"
+	}
+	return c.PrivProtocol
+}
+
+// apply configures a client for SNMPv3 with these settings. The settings
+// must have passed Validate.
+func (c SNMPv3Config) apply(client *gosnmp.GoSNMP) {
+	params := &gosnmp.UsmSecurityParameters{
+		UserName:               c.User,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+	}
+	if id, err := hex.DecodeString(c.EngineID); err == nil {
+		params.AuthoritativeEngineID = string(id)
+	}
+
+	client.MsgFlags = gosnmp.NoAuthNoPriv
+	level := c.Level()
+	if level == SNMPAuthNoPriv || level == SNMPAuthPriv {
+		client.MsgFlags = gosnmp.AuthNoPriv
+		params.AuthenticationProtocol = snmpAuthProtocols[strings.ToUpper(c.authProtocol())]
+		params.AuthenticationPassphrase = c.AuthPassphrase
+	}
+	if level == SNMPAuthPriv {
+		client.MsgFlags = gosnmp.AuthPriv
+		params.PrivacyProtocol = snmpPrivProtocols[strings.ToUpper(c.privProtocol())]
+		params.PrivacyPassphrase = c.PrivPassphrase
+	}
+
+	client.SecurityModel = gosnmp.UserSecurityModel
+	client.SecurityParameters = params
+	client.ContextName = c.ContextName
+	if id, err := hex.DecodeString(c.ContextEngineID); err == nil {
+		client.ContextEngineID = string(id)
+	}
+}
+
+// SNMPCredentials is a credential set read from configuration: a community
+// for SNMPv1/v2c or a USM user for SNMPv3.
+type SNMPCredentials struct {
+	Name      string       `json:"name,omitempty"`
+	Version   string       `json:"version"` // "1", "2c" or "3"
+	Community string       `json:"community,omitempty"`
+	V3        SNMPv3Config `json:"v3,omitempty"`
+}
+
+// DefaultSNMPCredentials is used when no credentials are configured.
+var DefaultSNMPCredentials = SNMPCredentials{Name: "default", Version: "2c", Community: "public"}
+
+// SNMPVersion parses the version field.
+func (c SNMPCredentials) SNMPVersion() (gosnmp.SnmpVersion, error) {
+	switch strings.TrimPrefix(strings.ToLower(c.Version), "v") {
+	case "1":
+		return gosnmp.Version1, nil
+	case "2", "2c", "":
+		return gosnmp.Version2c, nil
+	case "3":
+		return gosnmp.Version3, nil
+	}
+	return 0, fmt.Errorf("unknown SNMP version %q", c.Version)
+}
+
+// Validate checks the credential set.
+func (c SNMPCredentials) Validate() error {
+	version, err := c.SNMPVersion()
+	if err != nil {
+		return err
+	}
+	if version == gosnmp.Version3 {
+		return c.V3.Validate()
+	}
+	if c.Community == "" {
+		return fmt.Errorf("SNMP version %s needs a community", c.Version)
+	}
+	return nil
+}
+
+// Apply returns cfg with its version and security settings taken from the
+// credential set.
+func (c SNMPCredentials) Apply(cfg SNMPConfig) (SNMPConfig, error) {
+	if err := c.Validate(); err != nil {
+		return cfg, err
+	}
+	cfg.Version, _ = c.SNMPVersion()
+	cfg.Community = c.Community
+	cfg.V3 = c.V3
+	return cfg, nil
+}
+
+// LoadSNMPCredentials reads credential sets from a JSON file holding either
+// one set or an array of them. A community or passphrase that is exactly
+// $NAME or ${NAME} is read from that environment variable, so the secrets
+// themselves need not be stored in the file. Any other value is taken
+// literally, "$" included.
+func LoadSNMPCredentials(path string) ([]SNMPCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []SNMPCredentials
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &list)
+	} else {
+		var creds SNMPCredentials
+		err = json.Unmarshal(data, &creds)
+		list = []SNMPCredentials{creds}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: no SNMP credentials", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range list {
+		c := &list[i]
+		for _, secret := range []*string{&c.Community, &c.V3.AuthPassphrase, &c.V3.PrivPassphrase} {
+			if *secret, err = secretFromEnv(*secret); err != nil {
+				return nil, fmt.Errorf("%s: credentials %d: %v", path, i+1, err)
+			}
+		}
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: credentials %d: %v", path, i+1, err)
+		}
+		if c.Name == "" {
+			c.Name = c.defaultName(i + 1)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: duplicate credentials name %q", path, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return list, nil
+}
+
+// defaultName names an unnamed credential set without revealing its secret:
+// "v3:monitor" for a USM user, "v2c#2" for the second entry of a file.
+func (c SNMPCredentials) defaultName(n int) string {
+	version := strings.TrimPrefix(strings.ToLower(c.Version), "v")
+	if version == "" || version == "2" {
+		version = "2c"
+	}
+	if version == "3" {
+		return "v3:" + c.V3.User
+	}
+	return fmt.Sprintf("v%s#%d", version, n)
+}
+
+// envReference matches a value that is only a $NAME or ${NAME} reference.
+var envReference = regexp.MustCompile(`^\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)\})$`)
+
+// secretFromEnv resolves a value that references an environment variable
+// and returns any other value unchanged.
+func secretFromEnv(value string) (string, error) {
+	m := envReference.FindStringSubmatch(value)
+	if m == nil {
+		return value, nil
+	}
+	name := m[1] + m[2]
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return secret, nil
+}
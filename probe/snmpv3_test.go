@@ -0,0 +1,56 @@
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSNMPCredentialsSecrets(t *testing.T) {
+	t.Setenv("SENTINEL_TEST_COMMUNITY", "from-env")
+	t.Setenv("SENTINEL_TEST_AUTH", "auth-from-env")
+
+	tests := []struct {
+		name      string
+		json      string
+		community string
+		auth      string
+		priv      string
+		fail      bool
+	}{
+		{"literal", `{"version": "2c", "community": "Pa$sw0rd!"}`, "Pa$sw0rd!", "", "", false},
+		{"literal braces", `{"version": "2c", "community": "a${b}c"}`, "a${b}c", "", "", false},
+		{"variable", `{"version": "2c", "community": "$SENTINEL_TEST_COMMUNITY"}`, "from-env", "", "", false},
+		{"braced variable", `{"version": "2c", "community": "${SENTINEL_TEST_COMMUNITY}"}`, "from-env", "", "", false},
+		{
+			"v3 passphrases",
+			`{"version": "3", "v3": {"user": "monitor", "auth_protocol": "SHA", "auth_passphrase": "${SENTINEL_TEST_AUTH}",
+			  "priv_protocol": "AES", "priv_passphrase": "$ecret-Priv$"}}`,
+			"", "auth-from-env", "$ecret-Priv$", false,
+		},
+		{"unset variable", `{"version": "2c", "community": "$SENTINEL_TEST_UNSET"}`, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "creds.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			list, err := LoadSNMPCredentials(path)
+			if tt.fail {
+				if err == nil {
+					t.Errorf("got %+v, want an error", list)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := list[0]
+			if c.Community != tt.community || c.V3.AuthPassphrase != tt.auth || c.V3.PrivPassphrase != tt.priv {
+				t.Errorf("got %q/%q/%q, want %q/%q/%q",
+					c.Community, c.V3.AuthPassphrase, c.V3.PrivPassphrase, tt.community, tt.auth, tt.priv)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// maxDescriptionSize caps how much of a device description is read.
+const maxDescriptionSize = 1 << 20
+
+// SSDPConfig holds settings for SSDP/UPnP discovery.
+type SSDPConfig struct {
+	Interface    string        // interface to listen for NOTIFY on ("" for the default)
+	SearchTarget string        // M-SEARCH ST header (default "ssdp:all")
+	Timeout      time.Duration // how long to collect responses (default 5s)
+	MX           int           // maximum response delay in seconds (default 2)
+}
+
+// SSDPAnnouncement is an M-SEARCH response or NOTIFY alive message.
+type SSDPAnnouncement struct {
+	Location string // URL of the device description
+	USN      string
+	Target   string // ST (search response) or NT (notify)
+	Server   string
+	Source   string // sender IP
+}
+
+// UPnPDevice is the root device from a UPnP device description.
+type UPnPDevice struct {
+	DeviceType      string `xml:"deviceType"`
+	FriendlyName    string `xml:"friendlyName"`
+	Manufacturer    string `xml:"manufacturer"`
+	ModelName       string `xml:"modelName"`
+	ModelNumber     string `xml:"modelNumber"`
+	SerialNumber    string `xml:"serialNumber"`
+	UDN             string `xml:"UDN"`
+	PresentationURL string `xml:"presentationURL"`
+
+	Location string `xml:"-"`
+	Server   string `xml:"-"`
+	IP       string `xml:"-"`
+}
+
+type upnpRoot struct {
+	XMLName xml.Name   `xml:"root"`
+	Device  UPnPDevice `xml:"device"`
+}
+
+func (cfg *SSDPConfig) setDefaults() {
+	if cfg.SearchTarget == "" {
+		cfg.SearchTarget = "ssdp:all"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MX <= 0 {
+		cfg.MX = 2
+	}
+}
+
+// SSDPDiscover sends an M-SEARCH, listens for NOTIFY announcements for the
+// same window, then fetches every advertised device description.
+func SSDPDiscover(ctx context.Context, cfg SSDPConfig) ([]models.Device, error) {
+	cfg.setDefaults()
+
+	announcements, err := SSDPSearch(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// One description per sender and location; a device answers once per
+	// service. Keying on the sender too keeps a forged announcement from
+	// shadowing the real one.
+	byLocation := make(map[string]SSDPAnnouncement)
+	var locations []string
+	for _, a := range announcements {
+		key := a.Source + "|" + a.Location
+		if _, seen := byLocation[key]; !seen {
+			byLocation[key] = a
+			locations = append(locations, key)
+		}
+	}
+	sort.Strings(locations)
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	var mu sync.Mutex
+	byUDN := make(map[string]*UPnPDevice)
+	var order []string
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for _, loc := range locations {
+		wg.Add(1)
+		go func(a SSDPAnnouncement) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			dev, err := FetchUPnPDescription(ctx, client, a.Location, a.Source)
+			if err != nil {
+				log.Printf("[SSDP] Description %s from %s: %v", a.Location, a.Source, err)
+				return
+			}
+			dev.Server = a.Server
+			key := dev.UDN
+			if key == "" {
+				key = a.Location
+			}
+			mu.Lock()
+			if _, seen := byUDN[key]; !seen {
+				byUDN[key] = dev
+				order = append(order, key)
+			}
+			mu.Unlock()
+		}(byLocation[loc])
+	}
+	wg.Wait()
+
+	sort.Strings(order)
+	devices := make([]models.Device, 0, len(order))
+	for _, key := range order {
+		devices = append(devices, *byUDN[key].ToDevice())
+	}
+	log.Printf("[SSDP] Found %d UPnP device(s)\n", len(devices))
+	return devices, nil
+}
+
+// SSDPSearch multicasts an M-SEARCH and collects responses and NOTIFY alive
+// messages until the timeout. Failing to join the multicast group only
+// disables NOTIFY listening.
+func SSDPSearch(ctx context.Context, cfg SSDPConfig) ([]SSDPAnnouncement, error) {
+	cfg.setDefaults()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("error opening SSDP socket: %v", err)
+	}
+	defer conn.Close()
+
+	var iface *net.Interface
+	if cfg.Interface != "" {
+		iface, err = net.InterfaceByName(cfg.Interface)
+		if err != nil {
+			return nil, fmt.Errorf("error finding interface %s: %v", cfg.Interface, err)
+		}
+	}
+	notify, err := net.ListenMulticastUDP("udp4", iface, ssdpGroup)
+	if err != nil {
+		log.Printf("[SSDP] Not listening for NOTIFY: %v", err)
+		notify = nil
+	} else {
+		defer notify.Close()
+	}
+
+	deadline := time.Now().Add(cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var out []SSDPAnnouncement
+	record := func(a SSDPAnnouncement) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := a.USN + "|" + a.Location
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, a)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range []*net.UDPConn{conn, notify} {
+		if c == nil {
+			continue
+		}
+		c.SetReadDeadline(deadline)
+		wg.Add(1)
+		go func(c *net.UDPConn) {
+			defer wg.Done()
+			readSSDP(c, record)
+		}(c)
+	}
+
+	msg := buildMSearch(cfg.SearchTarget, cfg.MX)
+	// UDP is lossy; repeat the search once as UPnP recommends.
+	for i := 0; i < 2; i++ {
+		if _, err := conn.WriteToUDP(msg, ssdpGroup); err != nil {
+			log.Printf("[SSDP] M-SEARCH failed: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+			if notify != nil {
+				notify.SetReadDeadline(time.Now())
+			}
+		case <-done:
+		}
+	}()
+	wg.Wait()
+	close(done)
+
+	return out, nil
+}
+
+func readSSDP(c *net.UDPConn, record func(SSDPAnnouncement)) {
+	buf := make([]byte, 8192)
+	for {
+		n, src, err := c.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				log.Printf("[SSDP] Read error: %v", err)
+			}
+			return
+		}
+		a, ok := ParseSSDPMessage(buf[:n])
+		if !ok {
+			continue
+		}
+		a.Source = src.IP.String()
+		record(a)
+	}
+}
+
+// buildMSearch builds an M-SEARCH request (UPnP Device Architecture 1.1, 1.3.2).
+func buildMSearch(st string, mx int) []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		fmt.Sprintf("MX: %d\r\n", mx) +
+		"ST: " + st + "\r\n" +
+		"USER-AGENT: sentinel UPnP/1.1\r\n\r\n")
+}
+
+// ParseSSDPMessage parses an M-SEARCH response or a NOTIFY request. Byebye
+// notifications and messages without a LOCATION are rejected.
+func ParseSSDPMessage(b []byte) (SSDPAnnouncement, bool) {
+	var h http.Header
+	r := bufio.NewReader(bytes.NewReader(b))
+	if bytes.HasPrefix(b, []byte("HTTP/")) {
+		resp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			return SSDPAnnouncement{}, false
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return SSDPAnnouncement{}, false
+		}
+		h = resp.Header
+	} else {
+		req, err := http.ReadRequest(r)
+		if err != nil || req.Method != "NOTIFY" {
+			return SSDPAnnouncement{}, false
+		}
+		if req.Header.Get("NTS") != "ssdp:alive" {
+			return SSDPAnnouncement{}, false
+		}
+		h = req.Header
+	}
+
+	a := SSDPAnnouncement{
+		Location: strings.TrimSpace(h.Get("Location")),
+		USN:      h.Get("USN"),
+		Target:   h.Get("ST"),
+		Server:   h.Get("Server"),
+	}
+	if a.Target == "" {
+		a.Target = h.Get("NT")
+	}
+	if a.Location == "" {
+		return SSDPAnnouncement{}, false
+	}
+	return a, true
+}
+
+// FetchUPnPDescription downloads and parses the device description document
+// announced by source, the IP the SSDP message came from. Any host on the
+// segment can send a LOCATION header, so only URLs pointing back at the
+// sender are fetched, redirects elsewhere are not followed, and the device IP
+// is the sender's.
+func FetchUPnPDescription(ctx context.Context, client *http.Client, location, source string) (*UPnPDevice, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid location %q", location)
+	}
+	if !sameHost(u.Hostname(), source) {
+		return nil, fmt.Errorf("location %q does not point at sender %s", location, source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !sameHost(req.URL.Hostname(), source) {
+			return fmt.Errorf("redirect to %s refused", req.URL.Host)
+		}
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	dev, err := ParseUPnPDescription(io.LimitReader(resp.Body, maxDescriptionSize))
+	if err != nil {
+		return nil, err
+	}
+	dev.Location = location
+	dev.IP = source
+	return dev, nil
+}
+
+// sameHost reports whether a URL host is the IP address ip.
+func sameHost(host, ip string) bool {
+	a, b := net.ParseIP(host), net.ParseIP(ip)
+	return a != nil && b != nil && a.Equal(b)
+}
+
+// ParseUPnPDescription decodes the root device of a description document.
+func ParseUPnPDescription(r io.Reader) (*UPnPDevice, error) {
+	var root upnpRoot
+	dec := xml.NewDecoder(r)
+	// Descriptions are usually UTF-8 but some devices declare other charsets.
+	dec.CharsetReader = func(_ string, in io.Reader) (io.Reader, error) { return in, nil }
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("error parsing device description: %v", err)
+	}
+	dev := root.Device
+	for _, f := range []*string{&dev.DeviceType, &dev.FriendlyName, &dev.Manufacturer, &dev.ModelName,
+		&dev.ModelNumber, &dev.SerialNumber, &dev.UDN, &dev.PresentationURL} {
+		*f = strings.TrimSpace(*f)
+	}
+	return &dev, nil
+}
+
+// upnpTypeClasses maps UPnP device type names to device types.
+var upnpTypeClasses = []struct{ urnType, deviceType string }{
+	{"InternetGatewayDevice", "router"},
+	{"WFADevice", "access-point"},
+	{"Printer", "printer"},
+	{"DigitalSecurityCamera", "camera"},
+	{"MediaRenderer", "media-device"},
+	{"MediaServer", "nas"},
+}
+
+// Type classifies the device from its UPnP device type URN, e.g.
+// "urn:schemas-upnp-org:device:MediaRenderer:1".
+func (d *UPnPDevice) Type() string {
+	parts := strings.Split(d.DeviceType, ":")
+	if len(parts) >= 2 {
+		name := parts[len(parts)-2]
+		for _, c := range upnpTypeClasses {
+			if strings.EqualFold(name, c.urnType) {
+				return c.deviceType
+			}
+		}
+	}
+	return "unknown"
+}
+
+// ToDevice maps the description onto a models.Device. The UDN becomes the
+// device ID since it is stable across address changes.
+func (d *UPnPDevice) ToDevice() *models.Device {
+	model := strings.TrimSpace(d.ModelName + " " + d.ModelNumber)
+	device := models.NewDevice(models.DeviceConfig{
+		IPAddress:           d.IP,
+		DeviceType:          d.Type(),
+		Vendor:              d.Manufacturer,
+		Status:              "active",
+		MonitoringProtocols: []string{"SSDP"},
+		Description:         d.FriendlyName,
+		Model:               model,
+		SerialNumber:        d.SerialNumber,
+	})
+	device.SetID(d.UDN)
+	return device
+}
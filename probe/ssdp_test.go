@@ -0,0 +1,132 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaServer:1</deviceType>
+    <friendlyName> DiskStation </friendlyName>
+    <manufacturer>Synology</manufacturer>
+    <modelName>DS920+</modelName>
+    <modelNumber>DS920+ 7.2</modelNumber>
+    <serialNumber>2030ABC123</serialNumber>
+    <UDN>uuid:73796e6f-6473-6d00-0000-0011327a1b2c</UDN>
+    <presentationURL>http://192.0.2.20:5000/</presentationURL>
+  </device>
+</root>`
+
+func TestFetchUPnPDescription(t *testing.T) {
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(testDescription))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Redirect(w, r, "/desc.xml", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Redirect(w, r, "http://192.0.2.99/desc.xml", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	client := srv.Client()
+
+	dev, err := FetchUPnPDescription(context.Background(), client, srv.URL+"/desc.xml", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UPnPDevice{
+		DeviceType:      "urn:schemas-upnp-org:device:MediaServer:1",
+		FriendlyName:    "DiskStation",
+		Manufacturer:    "Synology",
+		ModelName:       "DS920+",
+		ModelNumber:     "DS920+ 7.2",
+		SerialNumber:    "2030ABC123",
+		UDN:             "uuid:73796e6f-6473-6d00-0000-0011327a1b2c",
+		PresentationURL: "http://192.0.2.20:5000/",
+		Location:        srv.URL + "/desc.xml",
+		IP:              "127.0.0.1",
+	}
+	if *dev != want {
+		t.Errorf("got %+v\nwant %+v", *dev, want)
+	}
+	if got := dev.Type(); got != "nas" {
+		t.Errorf("Type() = %q, want nas", got)
+	}
+
+	if _, err := FetchUPnPDescription(context.Background(), client, srv.URL+"/moved", "127.0.0.1"); err != nil {
+		t.Errorf("redirect on the same host: %v", err)
+	}
+
+	hits.Store(0)
+	refused := []struct {
+		name, location, source string
+		wantHits               int32
+	}{
+		{"location on another host", srv.URL + "/desc.xml", "192.0.2.7", 0},
+		{"host name instead of sender IP", strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + "/desc.xml", "127.0.0.1", 0},
+		{"not http", "file:///etc/passwd", "127.0.0.1", 0},
+		{"redirect to another host", srv.URL + "/elsewhere", "127.0.0.1", 1},
+		{"not found", srv.URL + "/missing.xml", "127.0.0.1", 0},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			if dev, err := FetchUPnPDescription(context.Background(), client, tt.location, tt.source); err == nil {
+				t.Errorf("got %+v, want an error", dev)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestParseSSDPMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		ok       bool
+		location string
+		target   string
+	}{
+		{
+			"search response",
+			"HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nLOCATION: http://192.0.2.20:5000/desc.xml\r\n" +
+				"SERVER: Linux/4.4 UPnP/1.0 Synology/7.2\r\nST: upnp:rootdevice\r\nUSN: uuid:1::upnp:rootdevice\r\n\r\n",
+			true, "http://192.0.2.20:5000/desc.xml", "upnp:rootdevice",
+		},
+		{
+			"notify alive",
+			"NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nLOCATION: http://192.0.2.1:1900/igd.xml\r\n" +
+				"NT: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\nNTS: ssdp:alive\r\nUSN: uuid:2\r\n\r\n",
+			true, "http://192.0.2.1:1900/igd.xml", "urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+		},
+		{
+			"notify byebye",
+			"NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nLOCATION: http://192.0.2.1/igd.xml\r\nNTS: ssdp:byebye\r\n\r\n",
+			false, "", "",
+		},
+		{"no location", "HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\n\r\n", false, "", ""},
+		{"m-search", "M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n\r\n", false, "", ""},
+	}
+	for _, tt := range tests {
+		a, ok := ParseSSDPMessage([]byte(tt.msg))
+		if ok != tt.ok || a.Location != tt.location || a.Target != tt.target {
+			t.Errorf("%s: got %+v %v, want location %q target %q %v", tt.name, a, ok, tt.location, tt.target, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshClientVersion identifies the prober in server logs.
+const sshClientVersion = "SSH-2.0-sentinel"
+
+// sshKexInit is the SSH_MSG_KEXINIT message number (RFC 4253 section 7.1).
+const sshKexInit = 20
+
+// Algorithms offered to the server when fetching host keys. The library
+// defaults leave out legacy algorithms that old appliances still require.
+var (
+	sshProbeKexAlgos = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group-exchange-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1",
+		"diffie-hellman-group-exchange-sha1", "diffie-hellman-group1-sha1",
+	}
+	sshProbeCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+	}
+	sshProbeMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+	}
+	// sshProbeHostKeyAlgos are the plain key algorithms the client can
+	// verify, one per key type; RSA is fetched through its SHA-2 signature.
+	sshProbeHostKeyAlgos = []string{
+		ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+	}
+)
+
+// sshWeakAlgorithms are broken or deprecated (RFC 9142, RFC 8758, OpenSSH
+// release notes).
+var sshWeakAlgorithms = map[string]bool{
+	"diffie-hellman-group1-sha1":         true,
+	"diffie-hellman-group14-sha1":        true,
+	"diffie-hellman-group-exchange-sha1": true,
+	"ssh-dss":                            true,
+	"ssh-rsa":                            true,
+	"3des-cbc":                           true,
+	"aes128-cbc":                         true,
+	"aes192-cbc":                         true,
+	"aes256-cbc":                         true,
+	"blowfish-cbc":                       true,
+	"cast128-cbc":                        true,
+	"rijndael-cbc@lysator.liu.se":        true,
+	"arcfour":                            true,
+	"arcfour128":                         true,
+	"arcfour256":                         true,
+	"none":                               true,
+	"hmac-md5":                           true,
+	"hmac-md5-96":                        true,
+	"hmac-md5-etm@openssh.com":           true,
+	"hmac-md5-96-etm@openssh.com":        true,
+	"hmac-sha1-96":                       true,
+	"hmac-sha1-96-etm@openssh.com":       true,
+	"umac-64@openssh.com":                true,
+	"umac-64-etm@openssh.com":            true,
+}
+
+// SSHHostKey is one host key presented by a server.
+type SSHHostKey struct {
+	Type        string `json:"type"`
+	Bits        int    `json:"bits,omitempty"` // RSA modulus size
+	Fingerprint string `json:"fingerprint"`    // "SHA256:..."
+}
+
+// SSHHostInfo is what an SSH server reveals before authentication.
+type SSHHostInfo struct {
+	Host              string
+	Port              int
+	Version           string // server identification string
+	KexAlgorithms     []string
+	HostKeyAlgorithms []string
+	Ciphers           []string // client to server and server to client, merged
+	MACs              []string
+	Compression       []string
+	HostKeys          []SSHHostKey
+	Weak              []string // offered algorithms or keys considered weak
+}
+
+// errHostKeyCaptured aborts the handshake once the host key is known.
+var errHostKeyCaptured = errors.New("host key captured")
+
+// ProbeSSH reads the server's identification and KEXINIT, then completes a
+// key exchange for every host key type the server offers to record and
+// fingerprint each key. It never authenticates.
+func ProbeSSH(ctx context.Context, host string, port int, timeout time.Duration) (*SSHHostInfo, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	info, err := sshReadKexInit(ctx, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	info.Host, info.Port = host, port
+
+	seen := make(map[string]bool)
+	for _, algo := range sshProbeHostKeyAlgos {
+		if !hasString(info.HostKeyAlgorithms, algo) {
+			continue
+		}
+		key, err := sshFetchHostKey(ctx, addr, algo, timeout)
+		if err != nil {
+			continue
+		}
+		fp := ssh.FingerprintSHA256(key)
+		if seen[fp] {
+			continue // same RSA key under another signature algorithm
+		}
+		seen[fp] = true
+		hk := SSHHostKey{Type: key.Type(), Fingerprint: fp}
+		if ck, ok := key.(ssh.CryptoPublicKey); ok {
+			if rsaKey, ok := ck.CryptoPublicKey().(*rsa.PublicKey); ok {
+				hk.Bits = rsaKey.N.BitLen()
+			}
+		}
+		info.HostKeys = append(info.HostKeys, hk)
+	}
+
+	info.Weak = info.weakAlgorithms()
+	return info, nil
+}
+
+// Fingerprints returns the host keys as "type SHA256:..." strings.
+func (info *SSHHostInfo) Fingerprints() []string {
+	out := make([]string, 0, len(info.HostKeys))
+	for _, k := range info.HostKeys {
+		out = append(out, k.Type+" "+k.Fingerprint)
+	}
+	return out
+}
+
+func (info *SSHHostInfo) weakAlgorithms() []string {
+	var weak []string
+	for _, list := range [][]string{info.KexAlgorithms, info.HostKeyAlgorithms, info.Ciphers, info.MACs} {
+		for _, algo := range list {
+			if sshWeakAlgorithms[algo] {
+				weak = appendUnique(weak, algo)
+			}
+		}
+	}
+	for _, k := range info.HostKeys {
+		if k.Bits > 0 && k.Bits < 2048 {
+			weak = appendUnique(weak, fmt.Sprintf("%s-%d", k.Type, k.Bits))
+		}
+	}
+	return weak
+}
+
+// String renders the probe as "OpenSSH_9.6 ssh-ed25519 SHA256:... [weak: ssh-rsa]".
+func (info *SSHHostInfo) String() string {
+	parts := []string{strings.TrimPrefix(info.Version, "SSH-2.0-")}
+	parts = append(parts, info.Fingerprints()...)
+	if len(info.Weak) > 0 {
+		parts = append(parts, "[weak: "+strings.Join(info.Weak, " ")+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// sshReadKexInit exchanges identification strings and parses the server's
+// cleartext KEXINIT packet.
+func sshReadKexInit(ctx context.Context, addr string, timeout time.Duration) (*SSHHostInfo, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte(sshClientVersion + "\r\n")); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+
+	// Servers may send other lines before the identification string.
+	var version string
+	for i := 0; i < 16 && version == ""; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("no SSH identification from %s: %v", addr, err)
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			version = strings.TrimRight(line, "\r\n")
+		}
+	}
+	if version == "" {
+		return nil, fmt.Errorf("no SSH identification from %s", addr)
+	}
+
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	padding := uint32(header[4])
+	if length < padding+1 || length > 35000 {
+		return nil, fmt.Errorf("invalid SSH packet length %d from %s", length, addr)
+	}
+	packet := make([]byte, length-1)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	payload := packet[:len(packet)-int(padding)]
+	if len(payload) < 17 || payload[0] != sshKexInit {
+		return nil, fmt.Errorf("expected KEXINIT from %s", addr)
+	}
+
+	// Skip the message number and the 16-byte cookie, then read the
+	// ten name-lists.
+	lists, err := parseNameLists(payload[17:], 10)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KEXINIT from %s: %v", addr, err)
+	}
+	info := &SSHHostInfo{
+		Version:           version,
+		KexAlgorithms:     lists[0],
+		HostKeyAlgorithms: lists[1],
+		Compression:       lists[6],
+	}
+	for _, c := range append(lists[2], lists[3]...) {
+		info.Ciphers = appendUnique(info.Ciphers, c)
+	}
+	for _, m := range append(lists[4], lists[5]...) {
+		info.MACs = appendUnique(info.MACs, m)
+	}
+	for _, c := range lists[7] {
+		info.Compression = appendUnique(info.Compression, c)
+	}
+	return info, nil
+}
+
+func parseNameLists(b []byte, n int) ([][]string, error) {
+	lists := make([][]string, n)
+	for i := 0; i < n; i++ {
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		if uint32(len(b)) < size {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if size > 0 {
+			lists[i] = strings.Split(string(b[:size]), ",")
+		}
+		b = b[size:]
+	}
+	return lists, nil
+}
+
+// sshFetchHostKey runs a key exchange limited to one host key algorithm and
+// aborts as soon as the server has proven possession of the key.
+func sshFetchHostKey(ctx context.Context, addr, algo string, timeout time.Duration) (ssh.PublicKey, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var key ssh.PublicKey
+	config := &ssh.ClientConfig{
+		Config: ssh.Config{
+			KeyExchanges: sshProbeKexAlgos,
+			Ciphers:      sshProbeCiphers,
+			MACs:         sshProbeMACs,
+		},
+		User:              "sentinel",
+		ClientVersion:     sshClientVersion,
+		HostKeyAlgorithms: []string{algo},
+		HostKeyCallback: func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errHostKeyCaptured
+		},
+		Timeout: timeout,
+	}
+	_, _, _, err = ssh.NewClientConn(conn, addr, config)
+	if key != nil {
+		return key, nil
+	}
+	return nil, err
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SSHKeyStore remembers host key fingerprints between scans, keyed by
+// "host:port" and then key type.
+type SSHKeyStore struct {
+	path  string
+	Hosts map[string]map[string]string `json:"hosts"`
+}
+
+// SSHKeyChange is a host key that differs from the one seen last time.
+type SSHKeyChange struct {
+	Addr     string
+	Type     string
+	Previous string
+	Current  string
+}
+
+// LoadSSHKeyStore reads a key store. A missing file yields an empty store
+// that Save will create.
+func LoadSSHKeyStore(path string) (*SSHKeyStore, error) {
+	store := &SSHKeyStore{path: path, Hosts: make(map[string]map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("error parsing SSH key store %s: %v", path, err)
+	}
+	if store.Hosts == nil {
+		store.Hosts = make(map[string]map[string]string)
+	}
+	return store, nil
+}
+
+// Update records the keys of a probe and returns those that changed since the
+// last recorded scan. New hosts and new key types are not changes.
+func (s *SSHKeyStore) Update(info *SSHHostInfo) []SSHKeyChange {
+	addr := net.JoinHostPort(info.Host, strconv.Itoa(info.Port))
+	known := s.Hosts[addr]
+	if known == nil {
+		known = make(map[string]string)
+		s.Hosts[addr] = known
+	}
+	var changes []SSHKeyChange
+	for _, k := range info.HostKeys {
+		if prev, ok := known[k.Type]; ok && prev != k.Fingerprint {
+			changes = append(changes, SSHKeyChange{Addr: addr, Type: k.Type, Previous: prev, Current: k.Fingerprint})
+		}
+		known[k.Type] = k.Fingerprint
+	}
+	return changes
+}
+
+// Save writes the store back to its file.
+func (s *SSHKeyStore) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// SharedSSHHostKeys groups hosts by host key fingerprint and returns the
+// fingerprints presented on more than one address, which usually means one
+// box with several IPs (or a cloned image).
+func SharedSSHHostKeys(infos []*SSHHostInfo) map[string][]string {
+	hosts := make(map[string][]string)
+	for _, info := range infos {
+		for _, k := range info.HostKeys {
+			hosts[k.Fingerprint] = appendUnique(hosts[k.Fingerprint], info.Host)
+		}
+	}
+	for fp, list := range hosts {
+		if len(list) < 2 {
+			delete(hosts, fp)
+			continue
+		}
+		sort.Strings(list)
+	}
+	return hosts
+}
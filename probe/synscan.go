@@ -0,0 +1,217 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// synScanner sends crafted SYNs over a raw IPv4 socket and classifies ports
+// from the SYN/ACK or RST that comes back. The kernel answers each SYN/ACK
+// with a reset, so no connection is ever completed.
+type synScanner struct {
+	conn    net.PacketConn
+	srcPort layers.TCPPort
+	seq     uint32
+
+	mu     sync.Mutex
+	states map[synTarget]PortState
+}
+
+type synTarget struct {
+	addr netip.Addr
+	port int
+}
+
+// newSYNScanner opens the raw socket. It fails without CAP_NET_RAW.
+func newSYNScanner() (*synScanner, error) {
+	conn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("error opening raw TCP socket: %v", err)
+	}
+	return &synScanner{
+		conn:    conn,
+		srcPort: layers.TCPPort(32768 + rand.Intn(28232)),
+		seq:     rand.Uint32(),
+		states:  make(map[synTarget]PortState),
+	}, nil
+}
+
+func (s *synScanner) close() {
+	s.conn.Close()
+}
+
+// scan probes every host:port, resending to unanswered ports cfg.Retries
+// times. Ports that never answer are reported as filtered. Hosts that are not
+// IPv4 addresses, or that have no route, are returned untouched in skipped.
+func (s *synScanner) scan(ctx context.Context, hosts []string, cfg PortScanConfig, limiter *rateLimiter) (results []PortResult, skipped []string) {
+	names := make(map[netip.Addr]string, len(hosts))
+	sources := make(map[netip.Addr]net.IP, len(hosts))
+	var addrs []netip.Addr
+	for _, h := range hosts {
+		addr, err := netip.ParseAddr(h)
+		if err != nil || !addr.Is4() {
+			skipped = append(skipped, h)
+			continue
+		}
+		src, err := sourceAddrFor(addr)
+		if err != nil {
+			skipped = append(skipped, h)
+			continue
+		}
+		names[addr] = h
+		sources[addr] = src
+		addrs = append(addrs, addr)
+	}
+
+	stop := make(chan struct{})
+	var readerWG sync.WaitGroup
+	readerWG.Add(1)
+	go func() {
+		defer readerWG.Done()
+		s.readReplies(stop)
+	}()
+
+	sent := make(map[synTarget]bool)
+SEND:
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		pending := 0
+		for _, port := range cfg.Ports {
+			for _, addr := range addrs {
+				t := synTarget{addr, port}
+				if s.answered(t) {
+					continue
+				}
+				if err := limiter.wait(ctx); err != nil {
+					break SEND
+				}
+				if err := s.send(sources[addr], addr, port); err != nil {
+					continue
+				}
+				sent[t] = true
+				pending++
+			}
+		}
+		if pending == 0 {
+			break
+		}
+		select {
+		case <-time.After(cfg.Timeout):
+		case <-ctx.Done():
+			break SEND
+		}
+	}
+
+	close(stop)
+	s.conn.SetReadDeadline(time.Now())
+	readerWG.Wait()
+
+	results = make([]PortResult, 0, len(sent))
+	for t := range sent {
+		state, ok := s.states[t]
+		if !ok {
+			state = PortFiltered
+		}
+		results = append(results, PortResult{Host: names[t.addr], Port: t.port, Protocol: "tcp", State: state})
+	}
+	return results, skipped
+}
+
+func (s *synScanner) answered(t synTarget) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.states[t]
+	return ok
+}
+
+func (s *synScanner) send(src net.IP, dst netip.Addr, port int) error {
+	dstIP := net.IP(dst.AsSlice())
+	ip := &layers.IPv4{SrcIP: src, DstIP: dstIP, Protocol: layers.IPProtocolTCP}
+	tcp := &layers.TCP{
+		SrcPort: s.srcPort,
+		DstPort: layers.TCPPort(port),
+		Seq:     s.seq,
+		SYN:     true,
+		Window:  1024,
+		Options: []layers.TCPOption{{
+			OptionType:   layers.TCPOptionKindMSS,
+			OptionLength: 4,
+			OptionData:   []byte{0x05, 0xb4}, // 1460
+		}},
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, tcp); err != nil {
+		return err
+	}
+	_, err := s.conn.WriteTo(buf.Bytes(), &net.IPAddr{IP: dstIP})
+	return err
+}
+
+// readReplies records SYN/ACK and RST answers to our probes until stop is
+// closed. The raw socket delivers the TCP segment without the IP header.
+func (s *synScanner) readReplies(stop <-chan struct{}) {
+	buf := make([]byte, 1500)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		s.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return
+		}
+		ipAddr, ok := from.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(ipAddr.IP.To4())
+		if !ok {
+			continue
+		}
+
+		packet := gopacket.NewPacket(buf[:n], layers.LayerTypeTCP, gopacket.NoCopy)
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok || tcp.DstPort != s.srcPort || tcp.Ack != s.seq+1 {
+			continue
+		}
+		var state PortState
+		switch {
+		case tcp.SYN && tcp.ACK:
+			state = PortOpen
+		case tcp.RST:
+			state = PortClosed
+		default:
+			continue
+		}
+		s.mu.Lock()
+		s.states[synTarget{addr, int(tcp.SrcPort)}] = state
+		s.mu.Unlock()
+	}
+}
+
+// sourceAddrFor asks the kernel which local address routes to dst.
+func sourceAddrFor(dst netip.Addr) (net.IP, error) {
+	conn, err := net.Dial("udp4", netip.AddrPortFrom(dst, 9).String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.To4(), nil
+}
@@ -0,0 +1,280 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+// TLSPorts are ports that normally speak TLS from the first byte.
+var TLSPorts = map[int]bool{
+	261: true, 443: true, 465: true, 563: true, 636: true, 853: true, 989: true,
+	990: true, 992: true, 993: true, 994: true, 995: true, 2376: true, 3269: true,
+	4443: true, 5061: true, 5223: true, 5986: true, 6443: true, 6697: true,
+	8443: true, 8883: true, 9443: true, 10443: true, 16993: true, 17990: true,
+}
+
+// tlsVersions are tried from newest to oldest; the chain is taken from the
+// first that handshakes.
+var tlsVersions = []uint16{tls.VersionTLS13, tls.VersionTLS12, tls.VersionTLS11, tls.VersionTLS10}
+
+// TLSInspectConfig holds settings for a TLS inspection.
+type TLSInspectConfig struct {
+	ServerName    string        // SNI and hostname to verify; not verified when empty
+	Timeout       time.Duration // per handshake (default 5s)
+	ExpiryWarning time.Duration // flag certificates expiring within this window (default 30 days)
+}
+
+func (cfg *TLSInspectConfig) setDefaults() {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.ExpiryWarning <= 0 {
+		cfg.ExpiryWarning = 30 * 24 * time.Hour
+	}
+}
+
+// InspectTLS handshakes with host:port at every TLS version, enumerates the
+// accepted cipher suites, and records and checks the certificate chain. It
+// fails when no version handshakes, i.e. the port does not speak TLS.
+func InspectTLS(ctx context.Context, host string, port int, cfg TLSInspectConfig) (*models.TLSService, error) {
+	cfg.setDefaults()
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	svc := &models.TLSService{Port: port, CheckedAt: time.Now()}
+
+	var chain []*x509.Certificate
+	for _, version := range tlsVersions {
+		suites, certs := tlsEnumerateSuites(ctx, addr, version, cfg)
+		if len(suites) == 0 {
+			continue
+		}
+		svc.Versions = append([]string{tls.VersionName(version)}, svc.Versions...)
+		for _, id := range suites {
+			svc.CipherSuites = appendUnique(svc.CipherSuites, tls.CipherSuiteName(id))
+		}
+		if chain == nil {
+			chain = certs
+		}
+	}
+	if len(svc.Versions) == 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("no TLS handshake on " + addr)
+	}
+
+	for _, cert := range chain {
+		svc.Chain = append(svc.Chain, describeCertificate(cert))
+	}
+	svc.Issues = certificateIssues(chain, cfg.ServerName, svc.CheckedAt, cfg.ExpiryWarning)
+	return svc, nil
+}
+
+// InspectTLSPorts inspects several ports of a host concurrently, skipping
+// ports that do not speak TLS and raw print ports.
+func InspectTLSPorts(ctx context.Context, host string, ports []int, cfg TLSInspectConfig) []models.TLSService {
+	var mu sync.Mutex
+	var services []models.TLSService
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 4)
+	for _, port := range ports {
+		if IsRawPrintPort(port) {
+			continue
+		}
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			svc, err := InspectTLS(ctx, host, port, cfg)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			services = append(services, *svc)
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Port < services[j].Port })
+	return services
+}
+
+// tlsEnumerateSuites returns the suites the server accepts at one version, in
+// its preference order, by repeatedly removing the suite it picked. TLS 1.3
+// suites are not configurable, so only the negotiated one is reported. The
+// chain comes from the first successful handshake.
+func tlsEnumerateSuites(ctx context.Context, addr string, version uint16, cfg TLSInspectConfig) ([]uint16, []*x509.Certificate) {
+	var offered []uint16
+	if version != tls.VersionTLS13 {
+		for _, list := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+			for _, s := range list {
+				for _, v := range s.SupportedVersions {
+					if v == version {
+						offered = append(offered, s.ID)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	var accepted []uint16
+	var chain []*x509.Certificate
+	for {
+		state, err := tlsHandshake(ctx, addr, version, offered, cfg)
+		if err != nil {
+			break
+		}
+		if chain == nil {
+			chain = state.PeerCertificates
+		}
+		accepted = append(accepted, state.CipherSuite)
+		if version == tls.VersionTLS13 {
+			break
+		}
+		offered = removeSuite(offered, state.CipherSuite)
+		if len(offered) == 0 {
+			break
+		}
+	}
+	return accepted, chain
+}
+
+func tlsHandshake(ctx context.Context, addr string, version uint16, suites []uint16, cfg TLSInspectConfig) (*tls.ConnectionState, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: cfg.Timeout},
+		Config: &tls.Config{
+			ServerName:         cfg.ServerName,
+			InsecureSkipVerify: true, // the chain is inspected, not trusted
+			MinVersion:         version,
+			MaxVersion:         version,
+			CipherSuites:       suites,
+		},
+	}
+	hctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(hctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	state := conn.(*tls.Conn).ConnectionState()
+	if state.Version != version {
+		return nil, errors.New("server negotiated " + tls.VersionName(state.Version))
+	}
+	return &state, nil
+}
+
+func removeSuite(suites []uint16, id uint16) []uint16 {
+	out := suites[:0]
+	for _, s := range suites {
+		if s != id {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func describeCertificate(cert *x509.Certificate) models.Certificate {
+	sum := sha256.Sum256(cert.Raw)
+	keyType, keyBits := publicKeyInfo(cert)
+	c := models.Certificate{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		Serial:             cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyType:            keyType,
+		KeyBits:            keyBits,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SHA256:             hex.EncodeToString(sum[:]),
+	}
+	c.SANs = append(c.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, ip.String())
+	}
+	c.SANs = append(c.SANs, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		c.SANs = append(c.SANs, uri.String())
+	}
+	return c
+}
+
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	case *dsa.PublicKey:
+		return "DSA", key.P.BitLen()
+	default:
+		return cert.PublicKeyAlgorithm.String(), 0
+	}
+}
+
+// weakKey applies the usual minimums: 2048-bit RSA/DSA and 256-bit ECDSA.
+func weakKey(cert *x509.Certificate) bool {
+	keyType, bits := publicKeyInfo(cert)
+	switch keyType {
+	case "RSA", "DSA":
+		return bits < 2048
+	case "ECDSA":
+		return bits < 256
+	default:
+		return false
+	}
+}
+
+// certificateIssues flags problems with the chain. Validity and key strength
+// are checked on every certificate, identity only on the leaf.
+func certificateIssues(chain []*x509.Certificate, name string, now time.Time, warn time.Duration) []string {
+	if len(chain) == 0 {
+		return nil
+	}
+	var issues []string
+	for _, cert := range chain {
+		switch {
+		case now.After(cert.NotAfter):
+			issues = appendUnique(issues, models.CertExpired)
+		case now.Before(cert.NotBefore):
+			issues = appendUnique(issues, models.CertNotYetValid)
+		case cert.NotAfter.Sub(now) < warn:
+			issues = appendUnique(issues, models.CertExpiring)
+		}
+		if weakKey(cert) {
+			issues = appendUnique(issues, models.CertWeakKey)
+		}
+	}
+
+	leaf := chain[0]
+	// CheckSignatureFrom would insist on a CA flag that self-signed leaves lack.
+	if bytes.Equal(leaf.RawIssuer, leaf.RawSubject) &&
+		leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil {
+		issues = appendUnique(issues, models.CertSelfSigned)
+	}
+	if name != "" && leaf.VerifyHostname(name) != nil {
+		issues = appendUnique(issues, models.CertHostnameMismatch)
+	}
+	return issues
+}
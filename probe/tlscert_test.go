@@ -0,0 +1,51 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+func TestInspectTLSServerName(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	// httptest's certificate is for example.com and 127.0.0.1
+	tests := []struct {
+		serverName string
+		mismatch   bool
+	}{
+		{"", false}, // no DNS name known: the hostname is not checked
+		{"example.com", false},
+		{"nas.example.net", true},
+	}
+	for _, tt := range tests {
+		svc, err := InspectTLS(context.Background(), host, port, TLSInspectConfig{ServerName: tt.serverName, Timeout: 2 * time.Second})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.serverName, err)
+		}
+		if got := hasString(svc.Issues, models.CertHostnameMismatch); got != tt.mismatch {
+			t.Errorf("%q: hostname mismatch = %v, want %v (issues %v)", tt.serverName, got, tt.mismatch, svc.Issues)
+		}
+	}
+}
+
+func TestIdentifyServicesTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	matches := DefaultSignatureDB().IdentifyServices(context.Background(), host, []int{port}, 500*time.Millisecond)
+	if len(matches) != 1 || matches[0].Service != "ssl" {
+		t.Errorf("got %v, want one ssl match", matches)
+	}
+}
@@ -0,0 +1,270 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// udpProbe is a protocol-correct payload for a UDP service. Services answer
+// only well-formed requests, so an empty datagram would leave them
+// indistinguishable from a filtered port.
+type udpProbe struct {
+	port    int
+	service string
+	payload func() []byte
+}
+
+// udpProbes lists the UDP services probed by default. A port may have more
+// than one payload; the first that gets an answer wins.
+var udpProbes = []udpProbe{
+	{53, "dns", dnsVersionBindQuery},
+	{69, "tftp", tftpReadRequest},
+	{123, "ntp", ntpClientRequest},
+	{123, "ntp", ntpControlReadVars},
+	{137, "netbios-ns", func() []byte { return BuildNBSTATQuery(uint16(time.Now().UnixNano())) }},
+	{161, "snmp", snmpGetSysDescr},
+	{514, "syslog", func() []byte { return nil }}, // never answers; ICMP decides
+	{623, "ipmi", rmcpPing},
+	{1900, "ssdp", func() []byte { return buildMSearch("ssdp:all", 1) }},
+}
+
+// DefaultUDPPorts returns the ports that have a probe payload.
+func DefaultUDPPorts() []int {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, p := range udpProbes {
+		if !seen[p.port] {
+			seen[p.port] = true
+			ports = append(ports, p.port)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// UDPScanConfig holds settings for a UDP service scan.
+type UDPScanConfig struct {
+	Ports   []int         // ports to probe (default DefaultUDPPorts)
+	Timeout time.Duration // reply window per attempt (default 2s)
+	Retries int           // extra attempts for silent ports (default 1, negative for none)
+	Workers int           // concurrent probes (default 64)
+	Rate    int           // probes per second across all hosts (default 100)
+}
+
+func (cfg *UDPScanConfig) setDefaults() {
+	if len(cfg.Ports) == 0 {
+		cfg.Ports = DefaultUDPPorts()
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	} else if cfg.Retries == 0 {
+		cfg.Retries = 1
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 64
+	}
+	if cfg.Rate <= 0 {
+		// Hosts rate-limit ICMP unreachables (Linux: one per second by
+		// default), so probing faster turns closed ports into open|filtered.
+		cfg.Rate = 100
+	}
+}
+
+// ScanUDP probes UDP services on every host. A reply marks the port open, an
+// ICMP port unreachable marks it closed, other ICMP errors mark it filtered,
+// and silence after every retry leaves it open|filtered.
+func ScanUDP(ctx context.Context, hosts []string, cfg UDPScanConfig) ([]PortResult, error) {
+	cfg.setDefaults()
+	limiter := newRateLimiter(cfg.Rate)
+	defer limiter.stop()
+
+	type job struct {
+		host string
+		port int
+	}
+	jobs := make(chan job)
+	var mu sync.Mutex
+	var results []PortResult
+	var wg sync.WaitGroup
+
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				state, service := udpProbePort(ctx, j.host, j.port, cfg)
+				if state == "" {
+					continue // cancelled
+				}
+				mu.Lock()
+				results = append(results, PortResult{Host: j.host, Port: j.port, Protocol: "udp", State: state, Service: service})
+				mu.Unlock()
+			}
+		}()
+	}
+
+FEED:
+	for _, port := range cfg.Ports {
+		for _, host := range hosts {
+			if err := limiter.wait(ctx); err != nil {
+				break FEED
+			}
+			select {
+			case jobs <- job{host, port}:
+			case <-ctx.Done():
+				break FEED
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sortPortResults(results)
+	return results, ctx.Err()
+}
+
+// udpProbePort tries each payload for the port in turn. It returns "" when
+// ctx was cancelled.
+func udpProbePort(ctx context.Context, host string, port int, cfg UDPScanConfig) (PortState, string) {
+	probes := udpProbesFor(port)
+	service := probes[0].service
+
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		for _, p := range probes {
+			if ctx.Err() != nil {
+				return "", service
+			}
+			state := udpExchange(host, port, p.payload(), cfg.Timeout)
+			if state != PortOpenFiltered {
+				return state, p.service
+			}
+		}
+	}
+	return PortOpenFiltered, service
+}
+
+func udpProbesFor(port int) []udpProbe {
+	var probes []udpProbe
+	for _, p := range udpProbes {
+		if p.port == port {
+			probes = append(probes, p)
+		}
+	}
+	if len(probes) == 0 {
+		probes = append(probes, udpProbe{port, "", func() []byte { return nil }})
+	}
+	return probes
+}
+
+// udpExchange sends one datagram on a connected socket. The kernel reports an
+// ICMP port unreachable for a connected socket as ECONNREFUSED on the next
+// read, which is what tells closed apart from silent.
+func udpExchange(host string, port int, payload []byte, timeout time.Duration) PortState {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return PortFiltered
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(payload); err != nil {
+		return udpErrorState(err)
+	}
+	buf := make([]byte, 2048)
+	if _, err := conn.Read(buf); err != nil {
+		return udpErrorState(err)
+	}
+	return PortOpen
+}
+
+func udpErrorState(err error) PortState {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return PortOpenFiltered
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return PortClosed
+	default:
+		// Host/network unreachable or administratively prohibited.
+		return PortFiltered
+	}
+}
+
+// dnsVersionBindQuery asks for the CHAOS TXT record version.bind.
+func dnsVersionBindQuery() []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: uint16(time.Now().UnixNano()), RecursionDesired: false})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName("version.bind."),
+		Type:  dnsmessage.TypeTXT,
+		Class: dnsmessage.Class(3), // CHAOS
+	})
+	msg, _ := b.Finish()
+	return msg
+}
+
+// tftpReadRequest asks for a file that should not exist; a TFTP server
+// answers with an error packet, which still proves the service is there.
+func tftpReadRequest() []byte {
+	msg := []byte{0x00, 0x01} // RRQ
+	msg = append(msg, "sentinel-probe.txt"...)
+	msg = append(msg, 0x00)
+	msg = append(msg, "octet"...)
+	return append(msg, 0x00)
+}
+
+// ntpClientRequest is a 48-byte NTPv4 mode 3 (client) packet.
+func ntpClientRequest() []byte {
+	msg := make([]byte, 48)
+	msg[0] = 0x23 // LI 0, version 4, mode 3
+	return msg
+}
+
+// ntpControlReadVars is an NTP mode 6 READVAR request, answered by ntpd even
+// when it does not serve time to clients.
+func ntpControlReadVars() []byte {
+	return []byte{
+		0x16,       // LI 0, version 2, mode 6
+		0x02,       // opcode 2: read variables
+		0x00, 0x01, // sequence
+		0x00, 0x00, // status
+		0x00, 0x00, // association ID
+		0x00, 0x00, // offset
+		0x00, 0x00, // count
+	}
+}
+
+// snmpGetSysDescr is an SNMPv2c GetRequest for sysDescr.0 with community
+// "public".
+func snmpGetSysDescr() []byte {
+	packet := gosnmp.SnmpPacket{
+		Version:   gosnmp.Version2c,
+		Community: "public",
+		PDUType:   gosnmp.GetRequest,
+		RequestID: uint32(time.Now().UnixNano()) & 0x7fffffff,
+		Variables: []gosnmp.SnmpPDU{{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.Null}},
+	}
+	msg, _ := packet.MarshalMsg()
+	return msg
+}
+
+// rmcpPing is an RMCP/ASF presence ping, answered by IPMI BMCs with a pong.
+func rmcpPing() []byte {
+	return []byte{
+		0x06, 0x00, 0xff, 0x06, // RMCP v1.0, reserved, no ack, class ASF
+		0x00, 0x00, 0x11, 0xbe, // ASF IANA enterprise number
+		0x80, 0x00, 0x00, 0x00, // presence ping, tag, reserved, data length
+	}
+}
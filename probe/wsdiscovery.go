@@ -0,0 +1,265 @@
+package probe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+var wsdGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 3702}
+
+// onvifVideoTransmitter is the ONVIF device type cameras answer typed probes for.
+const onvifVideoTransmitter = "dn:NetworkVideoTransmitter"
+
+// WSDiscoveryConfig holds settings for WS-Discovery probing.
+type WSDiscoveryConfig struct {
+	Timeout time.Duration // how long to collect ProbeMatches (default 3s)
+}
+
+// WSDiscoveryMatch is one ProbeMatch from a WS-Discovery target service.
+type WSDiscoveryMatch struct {
+	Address string   // endpoint reference, usually "urn:uuid:..."
+	Types   []string // e.g. "dn:NetworkVideoTransmitter", "pub:Computer"
+	Scopes  []string
+	XAddrs  []string // transport addresses of the device's services
+	Source  string   // sender IP
+}
+
+type wsdEnvelope struct {
+	Header struct {
+		Action    string `xml:"Action"`
+		RelatesTo string `xml:"RelatesTo"`
+	} `xml:"Header"`
+	Body struct {
+		ProbeMatches struct {
+			Matches []struct {
+				Address string `xml:"EndpointReference>Address"`
+				Types   string `xml:"Types"`
+				Scopes  string `xml:"Scopes"`
+				XAddrs  string `xml:"XAddrs"`
+			} `xml:"ProbeMatch"`
+		} `xml:"ProbeMatches"`
+	} `xml:"Body"`
+}
+
+// WSDiscover probes for WS-Discovery target services (ONVIF cameras, Windows
+// hosts, WSD printers and scanners) and returns them as devices.
+func WSDiscover(ctx context.Context, cfg WSDiscoveryConfig) ([]models.Device, error) {
+	matches, err := WSDiscoveryProbe(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]models.Device, 0, len(matches))
+	for i := range matches {
+		devices = append(devices, *matches[i].ToDevice())
+	}
+	log.Printf("[WSD] Found %d device(s)\n", len(devices))
+	return devices, nil
+}
+
+// WSDiscoveryProbe multicasts an untyped Probe and an ONVIF-typed Probe (some
+// cameras only answer the latter) and collects the ProbeMatches.
+func WSDiscoveryProbe(ctx context.Context, cfg WSDiscoveryConfig) ([]WSDiscoveryMatch, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 3 * time.Second
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("error opening WS-Discovery socket: %v", err)
+	}
+	defer conn.Close()
+
+	ids := make(map[string]bool)
+	for _, types := range []string{"", onvifVideoTransmitter} {
+		id := "urn:uuid:" + newUUID()
+		ids[id] = true
+		if _, err := conn.WriteToUDP(BuildWSDiscoveryProbe(id, types), wsdGroup); err != nil {
+			log.Printf("[WSD] Probe failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	byAddress := make(map[string]*WSDiscoveryMatch)
+	var order []string
+	buf := make([]byte, 65535)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				log.Printf("[WSD] Read error: %v", err)
+			}
+			break
+		}
+		matches, relatesTo, err := ParseWSDiscoveryProbeMatches(buf[:n])
+		if err != nil || !ids[relatesTo] {
+			continue
+		}
+		for _, m := range matches {
+			m.Source = src.IP.String()
+			key := m.Address
+			if key == "" {
+				key = m.Source
+			}
+			if existing, ok := byAddress[key]; ok {
+				// The typed and untyped probes can both be answered.
+				for _, t := range m.Types {
+					existing.Types = appendUnique(existing.Types, t)
+				}
+				continue
+			}
+			match := m
+			byAddress[key] = &match
+			order = append(order, key)
+		}
+	}
+
+	sort.Strings(order)
+	out := make([]WSDiscoveryMatch, 0, len(order))
+	for _, key := range order {
+		out = append(out, *byAddress[key])
+	}
+	return out, nil
+}
+
+// BuildWSDiscoveryProbe builds a SOAP-over-UDP Probe message. types is a
+// space-separated list of QNames; empty probes for every target service.
+func BuildWSDiscoveryProbe(messageID, types string) []byte {
+	typesElem := ""
+	if types != "" {
+		typesElem = "<d:Types>" + types + "</d:Types>"
+	}
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+		`<e:Envelope xmlns:e="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+		` xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery"` +
+		` xmlns:dn="http://www.onvif.org/ver10/network/wsdl">` +
+		`<e:Header>` +
+		`<w:MessageID>` + messageID + `</w:MessageID>` +
+		`<w:To e:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</w:To>` +
+		`<w:Action e:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe</w:Action>` +
+		`</e:Header>` +
+		`<e:Body><d:Probe>` + typesElem + `</d:Probe></e:Body>` +
+		`</e:Envelope>`)
+}
+
+// ParseWSDiscoveryProbeMatches decodes a ProbeMatches message and returns its
+// matches and the MessageID of the probe it answers.
+func ParseWSDiscoveryProbeMatches(b []byte) ([]WSDiscoveryMatch, string, error) {
+	var env wsdEnvelope
+	if err := xml.Unmarshal(b, &env); err != nil {
+		return nil, "", fmt.Errorf("error parsing WS-Discovery message: %v", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(env.Header.Action), "/ProbeMatches") {
+		return nil, "", errors.New("not a ProbeMatches message")
+	}
+
+	var matches []WSDiscoveryMatch
+	for _, m := range env.Body.ProbeMatches.Matches {
+		matches = append(matches, WSDiscoveryMatch{
+			Address: strings.TrimSpace(m.Address),
+			Types:   strings.Fields(m.Types),
+			Scopes:  strings.Fields(m.Scopes),
+			XAddrs:  strings.Fields(m.XAddrs),
+		})
+	}
+	return matches, strings.TrimSpace(env.Header.RelatesTo), nil
+}
+
+// hasType reports whether the match advertises a type with the given local
+// name, ignoring the namespace prefix.
+func (m *WSDiscoveryMatch) hasType(local string) bool {
+	for _, t := range m.Types {
+		if i := strings.LastIndex(t, ":"); i >= 0 {
+			t = t[i+1:]
+		}
+		if t == local {
+			return true
+		}
+	}
+	return false
+}
+
+// onvifScope returns the decoded value of an ONVIF scope such as
+// "onvif://www.onvif.org/name/Front%20Door" for key "name".
+func (m *WSDiscoveryMatch) onvifScope(key string) string {
+	prefix := "onvif://www.onvif.org/" + key + "/"
+	for _, s := range m.Scopes {
+		if strings.HasPrefix(s, prefix) {
+			v, err := url.PathUnescape(s[len(prefix):])
+			if err != nil {
+				v = s[len(prefix):]
+			}
+			return strings.ReplaceAll(v, "_", " ")
+		}
+	}
+	return ""
+}
+
+// DeviceType classifies the match from its advertised types.
+func (m *WSDiscoveryMatch) DeviceType() string {
+	switch {
+	case m.hasType("NetworkVideoTransmitter"):
+		return "camera"
+	case m.hasType("PrintDeviceType"), m.hasType("ScanDeviceType"):
+		return "printer"
+	case m.hasType("Computer"):
+		return "workstation"
+	}
+	return "unknown"
+}
+
+// IP returns the host of the first XAddr, falling back to the sender.
+func (m *WSDiscoveryMatch) IP() string {
+	for _, x := range m.XAddrs {
+		if u, err := url.Parse(x); err == nil && net.ParseIP(u.Hostname()) != nil {
+			return u.Hostname()
+		}
+	}
+	return m.Source
+}
+
+// ToDevice maps the match onto a models.Device. ONVIF name, hardware and
+// manufacturer scopes are used when present.
+func (m *WSDiscoveryMatch) ToDevice() *models.Device {
+	description := m.onvifScope("name")
+	if description == "" {
+		description = strings.Join(m.Types, " ")
+	}
+	device := models.NewDevice(models.DeviceConfig{
+		IPAddress:           m.IP(),
+		DeviceType:          m.DeviceType(),
+		Vendor:              m.onvifScope("mfr"),
+		Status:              "active",
+		MonitoringProtocols: []string{"WS-Discovery"},
+		Description:         description,
+		Model:               m.onvifScope("hardware"),
+	})
+	device.SetID(m.Address)
+	return device
+}
+
+// newUUID returns a random (version 4) UUID string.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
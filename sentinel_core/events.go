@@ -0,0 +1,99 @@
+package sentinel
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// EventType identifies what changed about a device.
+type EventType string
+
+const (
+	EventDeviceAdded     EventType = "device_added"
+	EventDeviceRemoved   EventType = "device_removed" // stale, or merged into another device
+	EventIPChanged       EventType = "ip_changed"
+	EventMACChanged      EventType = "mac_changed"
+	EventStatusChanged   EventType = "status_changed"
+	EventPortOpened      EventType = "port_opened"
+	EventHostnameChanged EventType = "hostname_changed"
+)
+
+// DeviceEvent describes one change to the device inventory. Old and New hold
+// the previous and current value of the attribute that changed; New is the
+// port number for EventPortOpened ("161/udp" for a UDP port) and, when a
+// device was merged away, the ID of the device it was merged into.
+type DeviceEvent struct {
+	Type     EventType
+	DeviceID string
+	Time     time.Time
+	Old      string
+	New      string
+	Device   DeviceRecord // the device after the change
+}
+
+func (e DeviceEvent) String() string {
+	switch e.Type {
+	case EventDeviceAdded:
+		return fmt.Sprintf("%s %s (%s)", e.Type, e.DeviceID, e.Device.IP)
+	case EventPortOpened:
+		return fmt.Sprintf("%s %s %s", e.Type, e.DeviceID, e.New)
+	default:
+		return fmt.Sprintf("%s %s %q -> %q", e.Type, e.DeviceID, e.Old, e.New)
+	}
+}
+
+// subscriber is one consumer of the event stream.
+type subscriber struct {
+	ch      chan DeviceEvent
+	dropped int
+}
+
+// diffRecords returns the events that turn old into cur.
+func diffRecords(old, cur DeviceRecord, now time.Time) []DeviceEvent {
+	var events []DeviceEvent
+	add := func(t EventType, from, to string) {
+		events = append(events, DeviceEvent{Type: t, DeviceID: cur.DeviceID, Time: now, Old: from, New: to, Device: cur})
+	}
+	if cur.IP != old.IP && cur.IP != "" {
+		add(EventIPChanged, old.IP, cur.IP)
+	}
+	if cur.MAC != old.MAC && cur.MAC != "" {
+		add(EventMACChanged, old.MAC, cur.MAC)
+	}
+	if cur.Status != old.Status && cur.Status != "" {
+		add(EventStatusChanged, old.Status, cur.Status)
+	}
+	if cur.Hostname != old.Hostname && cur.Hostname != "" {
+		add(EventHostnameChanged, old.Hostname, cur.Hostname)
+	}
+	for _, port := range cur.OpenPorts {
+		if !hasPort(old.OpenPorts, port) {
+			add(EventPortOpened, "", strconv.Itoa(port))
+		}
+	}
+	for _, port := range cur.OpenUDPPorts {
+		if !hasPort(old.OpenUDPPorts, port) {
+			add(EventPortOpened, "", strconv.Itoa(port)+"/udp")
+		}
+	}
+	return events
+}
+
+// publish hands events to every subscriber without blocking the caller. A
+// subscriber whose buffer is full misses the event.
+func (p *Processor) publish(events []DeviceEvent) {
+	for _, e := range events {
+		for _, s := range p.subscribers {
+			select {
+			case s.ch <- e:
+			default:
+				s.dropped++
+				if s.dropped == 1 || s.dropped%100 == 0 {
+					log.Printf("[Processor] Event subscriber is not keeping up, %d events dropped", s.dropped)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package sentinel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/sofc-t/sentinel/domain/models"
+	"github.com/sofc-t/sentinel/probe"
+)
+
+// Probe names recorded in DeviceRecord.Source and DeviceRecord.Provenance.
+const (
+	SourceLLDP    = "lldp"
+	SourceNmap    = "nmap"
+	SourceARP     = "arp"
+	SourceNDP     = "ndp"
+	SourceMDNS    = "mdns"
+	SourceSSDP    = "ssdp"
+	SourceWSD     = "wsd"
+	SourcePassive = "passive"
+	SourcePing    = "ping"
+	SourceDNS     = "dns"
+	SourceNetBIOS = "netbios"
+	SourceLLMNR   = "llmnr"
+	SourceBanner  = "banner"
+	SourceHTTP    = "http"
+	SourceSNMP    = "snmp"
+	SourceOUI     = "oui"
+)
+
+// fieldPrecedence ranks the sources trusted for each attribute, best first.
+// A value from a better source replaces one from a worse source, and a newer
+// value replaces an older one from the same source; sources not listed only
+// fill empty fields.
+var fieldPrecedence = map[string][]string{
+	"Hostname":     {SourceSNMP, SourceDNS, SourceNetBIOS, SourceLLMNR, SourceMDNS, SourceLLDP, SourceWSD, SourceSSDP, SourceNmap, SourcePassive},
+	"SysName":      {SourceSNMP, SourceLLDP},
+	"MAC":          {SourceARP, SourceNDP, SourcePassive, SourceNmap, SourceNetBIOS, SourceLLDP},
+	"Status":       {SourcePing, SourceARP, SourceNDP, SourceNmap},
+	"LLDP":         {SourceLLDP, SourceSNMP},
+	"Descr":        {SourceSNMP, SourceLLDP, SourceSSDP, SourceMDNS, SourceWSD, SourceNmap},
+	"Type":         {SourceHTTP, SourceSNMP, SourceMDNS, SourceSSDP, SourceWSD, SourceLLDP, SourceBanner, SourceNmap},
+	"Vendor":       {SourceSNMP, SourceSSDP, SourceMDNS, SourceWSD, SourceHTTP, SourceLLDP, SourceNmap, SourceOUI},
+	"SerialNumber": {SourceSNMP, SourceSSDP, SourceLLDP},
+	"Uptime":       {SourceSNMP},
+}
+
+// stringFields are the scalar attributes merged by precedence.
+var stringFields = []struct {
+	name string
+	get  func(*DeviceRecord) *string
+}{
+	{"Hostname", func(d *DeviceRecord) *string { return &d.Hostname }},
+	{"SysName", func(d *DeviceRecord) *string { return &d.SysName }},
+	{"MAC", func(d *DeviceRecord) *string { return &d.MAC }},
+	{"Status", func(d *DeviceRecord) *string { return &d.Status }},
+	{"LLDP", func(d *DeviceRecord) *string { return &d.LLDP }},
+	{"Descr", func(d *DeviceRecord) *string { return &d.Descr }},
+	{"Type", func(d *DeviceRecord) *string { return &d.Type }},
+	{"Vendor", func(d *DeviceRecord) *string { return &d.Vendor }},
+	{"SerialNumber", func(d *DeviceRecord) *string { return &d.SerialNumber }},
+	{"Uptime", func(d *DeviceRecord) *string { return &d.Uptime }},
+	{"SNMPProfile", func(d *DeviceRecord) *string { return &d.SNMPProfile }},
+}
+
+// Note records that source supplied the given fields of the record.
+func (d *DeviceRecord) Note(source string, fields ...string) {
+	if d.Provenance == nil {
+		d.Provenance = make(map[string]string)
+	}
+	for _, f := range fields {
+		d.Provenance[f] = source
+	}
+}
+
+// SourceOf returns the probe that supplied a field, falling back to the
+// probe that produced the record.
+func (d *DeviceRecord) SourceOf(field string) string {
+	if s, ok := d.Provenance[field]; ok {
+		return s
+	}
+	return d.Source
+}
+
+// Sources lists the probes that contributed to the record.
+func (d *DeviceRecord) Sources() []string {
+	var sources []string
+	if d.Source != "" {
+		sources = append(sources, d.Source)
+	}
+	for _, s := range d.Provenance {
+		if !hasString(sources, s) {
+			sources = append(sources, s)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Resolver correlates records from different probes that describe the same
+// device and merges them into one record per device.
+//
+// Records are matched by serial number, SSH host key, MAC address, LLDP
+// chassis ID, hostname or SNMP sysName, and IP address. Hostnames and IP
+// addresses are reused (DHCP, NAT, stale DNS) and cloned VMs share SSH host
+// keys, so these do not join records whose MAC addresses or serial numbers
+// disagree.
+//
+// A device keeps the DeviceID it was given when it was first seen, even when
+// later records bring a stronger identifier. A record that arrives with a
+// DeviceID keeps it; otherwise one is derived from its strongest identifier.
+type Resolver struct {
+	records []*DeviceRecord // nil once merged into another record
+	keys    map[string]int  // identity key -> index into records
+}
+
+// NewResolver returns an empty resolver.
+func NewResolver() *Resolver {
+	return &Resolver{keys: make(map[string]int)}
+}
+
+// Add merges a record into the device it matches, or starts a new device.
+func (r *Resolver) Add(rec DeviceRecord) {
+	prepareRecord(&rec)
+	r.merge(&rec, r.match(&rec))
+}
+
+// match returns the positions of the devices a record belongs to, lowest
+// first.
+func (r *Resolver) match(rec *DeviceRecord) []int {
+	var matches []int
+	seen := make(map[int]bool)
+	for _, k := range recordKeys(rec) {
+		pos, ok := r.keys[k]
+		if !ok || seen[pos] {
+			continue
+		}
+		seen[pos] = true
+		if !strongKey(k) && conflicting(r.records[pos], rec) {
+			continue
+		}
+		matches = append(matches, pos)
+	}
+	sort.Ints(matches)
+	return matches
+}
+
+// merge folds a record into the first matched device, or appends it when
+// nothing matched, and returns the device's position.
+func (r *Resolver) merge(rec *DeviceRecord, matches []int) int {
+	if len(matches) == 0 {
+		if rec.DeviceID == "" {
+			rec.DeviceID = stableID(rec)
+		}
+		r.records = append(r.records, rec)
+		r.index(len(r.records) - 1)
+		return len(r.records) - 1
+	}
+
+	target := matches[0]
+	mergeRecord(r.records[target], rec)
+	// The new record may bridge devices that were separate until now.
+	for _, pos := range matches[1:] {
+		mergeRecord(r.records[target], r.records[pos])
+		r.records[pos] = nil
+		for k, p := range r.keys {
+			if p == pos {
+				r.keys[k] = target
+			}
+		}
+	}
+	if r.records[target].DeviceID == "" {
+		r.records[target].DeviceID = stableID(r.records[target])
+	}
+	r.index(target)
+	return target
+}
+
+// remove forgets a device and every key that pointed at it.
+func (r *Resolver) remove(pos int) {
+	r.records[pos] = nil
+	for k, p := range r.keys {
+		if p == pos {
+			delete(r.keys, k)
+		}
+	}
+}
+
+// Records returns copies of the merged devices in discovery order, each with
+// the DeviceID of its first record.
+func (r *Resolver) Records() []DeviceRecord {
+	out := make([]DeviceRecord, 0, len(r.records))
+	for _, rec := range r.records {
+		if rec == nil {
+			continue
+		}
+		out = append(out, rec.clone())
+	}
+	return out
+}
+
+// Len returns the number of distinct devices.
+func (r *Resolver) Len() int {
+	n := 0
+	for _, rec := range r.records {
+		if rec != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// MergeRecords collapses records that describe the same device. Each device's
+// DeviceID is derived from the strongest identifier of the merged record, so
+// a scan yields the same IDs whichever probe reported a device first.
+func MergeRecords(records []DeviceRecord) []DeviceRecord {
+	r := NewResolver()
+	for _, rec := range records {
+		r.Add(rec)
+	}
+	merged := r.Records()
+	for i := range merged {
+		merged[i].DeviceID = stableID(&merged[i])
+	}
+	return merged
+}
+
+// clone returns a copy of the record that shares no slices or maps with it.
+func (d *DeviceRecord) clone() DeviceRecord {
+	c := *d
+	c.IPs = append([]string(nil), d.IPs...)
+	c.TLS = append([]models.TLSService(nil), d.TLS...)
+	c.SSHHostKeys = append([]string(nil), d.SSHHostKeys...)
+	c.OpenPorts = append([]int(nil), d.OpenPorts...)
+	c.OpenUDPPorts = append([]int(nil), d.OpenUDPPorts...)
+	c.Interfaces = append([]models.Interface(nil), d.Interfaces...)
+	c.Rates = append([]InterfaceRate(nil), d.Rates...)
+	c.Storage = append([]probe.StorageUsage(nil), d.Storage...)
+	if d.Provenance != nil {
+		c.Provenance = make(map[string]string, len(d.Provenance))
+		for f, s := range d.Provenance {
+			c.Provenance[f] = s
+		}
+	}
+	return c
+}
+
+// prepareRecord normalizes a record before it is matched.
+func prepareRecord(rec *DeviceRecord) {
+	rec.Provenance = stampProvenance(rec)
+	rec.MAC = normalizeMAC(rec.MAC)
+}
+
+// index points every identity key of a record at it.
+func (r *Resolver) index(pos int) {
+	for _, k := range recordKeys(r.records[pos]) {
+		r.keys[k] = pos
+	}
+}
+
+// recordKeys returns the identity keys of a record, strongest first.
+func recordKeys(d *DeviceRecord) []string {
+	var keys []string
+	if serial := strings.ToLower(strings.TrimSpace(d.SerialNumber)); serial != "" {
+		keys = append(keys, "serial:"+serial)
+	}
+	for _, fp := range d.SSHHostKeys {
+		keys = append(keys, "ssh:"+fp)
+	}
+	if d.MAC != "" && usableMAC(d.MAC) {
+		keys = append(keys, "mac:"+d.MAC)
+	}
+	if d.LLDP != "" {
+		chassis := strings.ToLower(d.LLDP)
+		if mac := normalizeMAC(d.LLDP); isMAC(mac) {
+			chassis = mac
+		}
+		keys = append(keys, "chassis:"+chassis)
+	}
+	for _, name := range []string{d.Hostname, d.SysName} {
+		if name := shortName(name); name != "" && name != "localhost" {
+			keys = append(keys, "name:"+name)
+		}
+	}
+	for _, ip := range recordIPs(d) {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// strongKey reports whether a key identifies one device on its own. SSH host
+// keys do not: VMs cloned from one image share them.
+func strongKey(k string) bool {
+	return !strings.HasPrefix(k, "ip:") && !strings.HasPrefix(k, "name:") && !strings.HasPrefix(k, "ssh:")
+}
+
+// conflicting reports whether two records carry hardware identifiers that
+// show they are different devices.
+func conflicting(a, b *DeviceRecord) bool {
+	if a.MAC != "" && b.MAC != "" && a.MAC != b.MAC {
+		return true
+	}
+	sa := strings.TrimSpace(a.SerialNumber)
+	sb := strings.TrimSpace(b.SerialNumber)
+	return sa != "" && sb != "" && !strings.EqualFold(sa, sb)
+}
+
+// mergeRecord folds src into dst.
+func mergeRecord(dst, src *DeviceRecord) {
+	if dst.Provenance == nil {
+		dst.Provenance = make(map[string]string)
+	}
+	for _, f := range stringFields {
+		d, s := f.get(dst), f.get(src)
+		if *s == "" {
+			continue
+		}
+		// A newer record may describe another interface of the device, so
+		// the MAC is not replaced for being newer.
+		rs, rd := rank(f.name, src.SourceOf(f.name)), rank(f.name, dst.SourceOf(f.name))
+		newer := rs == rd && rs < len(fieldPrecedence[f.name]) && f.name != "MAC" && src.LastSeen.After(dst.LastSeen)
+		if *d == "" || rs < rd || newer {
+			*d = *s
+			dst.Provenance[f.name] = src.SourceOf(f.name)
+		}
+	}
+
+	ips := recordIPs(dst)
+	for _, ip := range recordIPs(src) {
+		if !hasIP(ips, ip) {
+			ips = append(ips, ip)
+		}
+	}
+	dst.IPs = ips
+	if preferIP(src.IP, dst.IP) {
+		dst.IP = src.IP
+	}
+
+	if dst.PingMs == 0 {
+		dst.PingMs = src.PingMs
+	}
+	if dst.CPU == 0 {
+		dst.CPU = src.CPU
+	}
+	if dst.Mem == 0 {
+		dst.Mem = src.Mem
+	}
+	if len(dst.Interfaces) == 0 {
+		dst.Interfaces = src.Interfaces
+	}
+	if len(dst.Rates) == 0 {
+		dst.Rates = src.Rates
+		dst.InBps, dst.OutBps = src.InBps, src.OutBps
+	}
+	if len(dst.Storage) == 0 {
+		dst.Storage = src.Storage
+	}
+	if dst.IntIn == 0 && dst.IntOut == 0 {
+		dst.IntIn, dst.IntOut = src.IntIn, src.IntOut
+		dst.InErrors, dst.OutErrors = src.InErrors, src.OutErrors
+	}
+	if src.LastSeen.After(dst.LastSeen) {
+		dst.LastSeen = src.LastSeen
+	}
+
+	dst.Protocols = mergeList(dst.Protocols, src.Protocols)
+	for _, port := range src.OpenPorts {
+		if !hasPort(dst.OpenPorts, port) {
+			dst.OpenPorts = append(dst.OpenPorts, port)
+		}
+	}
+	sort.Ints(dst.OpenPorts)
+	for _, port := range src.OpenUDPPorts {
+		if !hasPort(dst.OpenUDPPorts, port) {
+			dst.OpenUDPPorts = append(dst.OpenUDPPorts, port)
+		}
+	}
+	sort.Ints(dst.OpenUDPPorts)
+	for _, fp := range src.SSHHostKeys {
+		if !hasString(dst.SSHHostKeys, fp) {
+			dst.SSHHostKeys = append(dst.SSHHostKeys, fp)
+		}
+	}
+	for _, svc := range src.TLS {
+		known := false
+		for _, have := range dst.TLS {
+			if have.Port == svc.Port {
+				known = true
+				break
+			}
+		}
+		if !known {
+			dst.TLS = append(dst.TLS, svc)
+		}
+	}
+
+	if dst.Source == "" {
+		dst.Source = src.Source
+	}
+}
+
+// stampProvenance attributes every populated field without an explicit
+// source to the probe that produced the record.
+func stampProvenance(d *DeviceRecord) map[string]string {
+	prov := make(map[string]string, len(d.Provenance))
+	for f, s := range d.Provenance {
+		prov[f] = s
+	}
+	if d.Source == "" {
+		return prov
+	}
+	for _, f := range stringFields {
+		if _, ok := prov[f.name]; !ok && *f.get(d) != "" {
+			prov[f.name] = d.Source
+		}
+	}
+	return prov
+}
+
+// rank returns the position of source in the precedence list of field.
+func rank(field, source string) int {
+	order := fieldPrecedence[field]
+	for i, s := range order {
+		if s == source {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// stableID derives an ID that stays the same across scans from the
+// strongest identifier the device has.
+func stableID(d *DeviceRecord) string {
+	var key string
+	switch {
+	case strings.TrimSpace(d.SerialNumber) != "":
+		key = "serial:" + strings.ToLower(strings.TrimSpace(d.SerialNumber))
+	case d.MAC != "" && usableMAC(d.MAC) && !localMAC(d.MAC):
+		key = "mac:" + d.MAC
+	case len(d.SSHHostKeys) > 0:
+		fps := append([]string(nil), d.SSHHostKeys...)
+		sort.Strings(fps)
+		key = "ssh:" + fps[0]
+	case d.LLDP != "":
+		key = "chassis:" + strings.ToLower(d.LLDP)
+	case d.MAC != "" && usableMAC(d.MAC):
+		key = "mac:" + d.MAC
+	case shortName(d.SysName) != "":
+		key = "name:" + shortName(d.SysName)
+	case shortName(d.Hostname) != "":
+		key = "name:" + shortName(d.Hostname)
+	case d.IP != "":
+		key = "ip:" + d.IP
+	default:
+		return d.DeviceID
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "dev-" + hex.EncodeToString(sum[:6])
+}
+
+// recordIPs returns the primary and additional addresses without duplicates.
+func recordIPs(d *DeviceRecord) []string {
+	var ips []string
+	for _, ip := range append([]string{d.IP}, d.IPs...) {
+		if ip != "" && !hasIP(ips, ip) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// preferIP reports whether candidate should replace the current primary
+// address: any address beats none, and IPv4 beats IPv6.
+func preferIP(candidate, current string) bool {
+	if candidate == "" {
+		return false
+	}
+	if current == "" {
+		return true
+	}
+	c, err1 := netip.ParseAddr(candidate)
+	cur, err2 := netip.ParseAddr(current)
+	return err1 == nil && err2 == nil && c.Is4() && !cur.Is4()
+}
+
+func hasIP(ips []string, ip string) bool {
+	for _, have := range ips {
+		if have == ip {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+func hasString(list []string, s string) bool {
+	for _, have := range list {
+		if have == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeList unions two comma-separated lists, keeping the order of first
+// appearance and ignoring case.
+func mergeList(a, b string) string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(a+","+b, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[strings.ToLower(item)] {
+			continue
+		}
+		seen[strings.ToLower(item)] = true
+		out = append(out, item)
+	}
+	return strings.Join(out, ",")
+}
+
+// normalizeMAC renders a MAC address as lowercase colon-separated hex, or
+// returns it unchanged when it does not parse.
+func normalizeMAC(mac string) string {
+	mac = strings.TrimSpace(mac)
+	if hw, err := net.ParseMAC(mac); err == nil {
+		return hw.String()
+	}
+	return mac
+}
+
+// usableMAC rejects the all-zero, broadcast and multicast addresses some
+// probes report for hosts they could not resolve.
+func usableMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return false
+	}
+	if hw[0]&0x01 != 0 {
+		return false
+	}
+	for _, b := range hw {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// localMAC reports whether a MAC is locally administered (randomized or
+// virtual), and so may change between scans.
+func localMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	return err == nil && len(hw) > 0 && hw[0]&0x02 != 0
+}
@@ -0,0 +1,91 @@
+package sentinel
+
+import "testing"
+
+func TestResolverSSHHostKeys(t *testing.T) {
+	const key = "ssh-ed25519 SHA256:Bv5JcBbkqm6D1bn3jRcDgY5v9bGHy0hRPzgJx3t1Xqs"
+	tests := []struct {
+		name    string
+		records []DeviceRecord
+		devices int
+	}{
+		{"same host", []DeviceRecord{
+			{IP: "192.0.2.10", MAC: "52:54:00:00:00:01", SSHHostKeys: []string{key}},
+			{IP: "198.51.100.10", SSHHostKeys: []string{key}},
+		}, 1},
+		// VMs cloned from one image share host keys but not MACs
+		{"cloned VMs", []DeviceRecord{
+			{IP: "192.0.2.10", MAC: "52:54:00:00:00:01", SSHHostKeys: []string{key}},
+			{IP: "192.0.2.11", MAC: "52:54:00:00:00:02", SSHHostKeys: []string{key}},
+		}, 2},
+		{"cloned appliances", []DeviceRecord{
+			{IP: "192.0.2.10", SerialNumber: "FOC1234A", SSHHostKeys: []string{key}},
+			{IP: "192.0.2.11", SerialNumber: "FOC5678B", SSHHostKeys: []string{key}},
+		}, 2},
+	}
+	for _, tt := range tests {
+		if got := len(MergeRecords(tt.records)); got != tt.devices {
+			t.Errorf("%s: got %d devices, want %d", tt.name, got, tt.devices)
+		}
+	}
+}
+
+func TestResolverKeepsDeviceID(t *testing.T) {
+	r := NewResolver()
+	r.Add(DeviceRecord{IP: "192.0.2.10", Source: SourcePing})
+	id := r.Records()[0].DeviceID
+	if id == "" {
+		t.Fatal("no DeviceID assigned")
+	}
+
+	// A MAC, then a serial number, would each give a stronger ID
+	r.Add(DeviceRecord{IP: "192.0.2.10", MAC: "00:1b:54:00:00:01", Source: SourceARP})
+	r.Add(DeviceRecord{IP: "192.0.2.10", SerialNumber: "FOC1234A", Source: SourceSNMP})
+	// A record bridging a second device into the first
+	r.Add(DeviceRecord{IP: "192.0.2.20", Hostname: "sw1", Source: SourceDNS})
+	r.Add(DeviceRecord{IP: "192.0.2.20", MAC: "00:1b:54:00:00:01", Source: SourceARP})
+
+	records := r.Records()
+	if len(records) != 1 {
+		t.Fatalf("got %d devices, want 1", len(records))
+	}
+	if records[0].DeviceID != id {
+		t.Errorf("DeviceID changed from %s to %s", id, records[0].DeviceID)
+	}
+}
+
+func TestMergeRecordsIDIndependentOfOrder(t *testing.T) {
+	const mac = "00:1b:54:00:00:01"
+	tests := []struct {
+		name    string
+		records []DeviceRecord
+		// want is the device with only its strongest identifier
+		want DeviceRecord
+	}{
+		{"ping and ARP", []DeviceRecord{
+			{IP: "192.0.2.10", Status: "active", Source: SourcePing},
+			{IP: "192.0.2.10", MAC: mac, Source: SourceARP},
+		}, DeviceRecord{MAC: mac}},
+		{"SSDP UDN, ARP and DNS", []DeviceRecord{
+			{DeviceID: "uuid:2fac1234-31f8-11b4-a222-08002b34c003", IP: "192.0.2.20", SerialNumber: "1130ABCD", Source: SourceSSDP},
+			{IP: "192.0.2.20", MAC: mac, Source: SourceARP},
+			{IP: "192.0.2.20", Hostname: "nas", Source: SourceDNS},
+		}, DeviceRecord{SerialNumber: "1130ABCD"}},
+	}
+	for _, tt := range tests {
+		want := MergeRecords([]DeviceRecord{tt.want})[0].DeviceID
+		reversed := make([]DeviceRecord, len(tt.records))
+		for i, rec := range tt.records {
+			reversed[len(reversed)-1-i] = rec
+		}
+		for _, records := range [][]DeviceRecord{tt.records, reversed} {
+			merged := MergeRecords(records)
+			if len(merged) != 1 {
+				t.Fatalf("%s: got %d devices, want 1", tt.name, len(merged))
+			}
+			if merged[0].DeviceID != want {
+				t.Errorf("%s: first source %s: DeviceID = %s, want %s", tt.name, records[0].Source, merged[0].DeviceID, want)
+			}
+		}
+	}
+}
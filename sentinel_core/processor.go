@@ -2,135 +2,517 @@ package sentinel
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"net/netip"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/sofc-t/sentinel/domain/models"
+	"github.com/sofc-t/sentinel/probe"
 )
 
 // DeviceRecord holds all collected data for a single device.
 type DeviceRecord struct {
-	DeviceID   string
-	Hostname   string
-	IP         string
-	MAC        string
-	Status     string
-	PingMs     int64
-	LLDP       string
-	CPU        float64
-	Mem        float64
-	IntIn      int64
-	IntOut     int64
-	InErrors   int64
-	OutErrors  int64
-	Uptime     string
-	Descr      string
-	Type       string
-	Vendor     string
-	Protocols  string
-	LastSeen   time.Time
-	SysName    string
-}
-
-// Processor stores device data and handles display.
+	DeviceID     string
+	Hostname     string
+	IP           string
+	IPs          []string // every IPv4/IPv6 address, IP is the primary one
+	MAC          string
+	Status       string
+	PingMs       int64
+	LLDP         string
+	CPU          float64
+	Mem          float64
+	IntIn        int64
+	IntOut       int64
+	InErrors     int64
+	OutErrors    int64
+	Uptime       string
+	Descr        string
+	Type         string
+	Vendor       string
+	Protocols    string
+	LastSeen     time.Time
+	SysName      string
+	TLS          []models.TLSService  // certificate inventory of TLS ports
+	SSHHostKeys  []string             // "type SHA256:..." per SSH host key
+	OpenPorts    []int                // open TCP ports
+	OpenUDPPorts []int                // open UDP ports
+	Interfaces   []models.Interface   // IF-MIB interface table
+	Rates        []InterfaceRate      // per-interface rates since the previous poll
+	InBps        float64              // total inbound throughput, from Rates
+	OutBps       float64              // total outbound throughput, from Rates
+	Storage      []probe.StorageUsage // disk and flash utilisation
+	SerialNumber string
+	SNMPProfile  string            // name of the SNMP credentials the device answered to
+	Source       string            // probe that produced the record
+	Provenance   map[string]string // field name -> probe that supplied it
+}
+
+// Output receives the tables printed by the Display functions. It is
+// stdout unless stdout carries something else, such as a topology export.
+var Output io.Writer = os.Stdout
+
+// Processor stores device data and handles display. It is safe for
+// concurrent use, and reports every change to the inventory to its
+// subscribers.
 type Processor struct {
-	devices map[string]DeviceRecord
+	mu          sync.RWMutex
+	resolver    *Resolver
+	subscribers []*subscriber
 }
 
 // NewProcessor creates a new Processor instance.
 func NewProcessor() *Processor {
 	return &Processor{
-		devices: make(map[string]DeviceRecord),
+		resolver: NewResolver(),
+	}
+}
+
+// Subscribe returns a channel of change events buffered to hold size events,
+// and a function that unsubscribes and closes the channel. Events are never
+// waited for: a subscriber that falls more than size events behind misses
+// them.
+func (p *Processor) Subscribe(size int) (<-chan DeviceEvent, func()) {
+	s := &subscriber{ch: make(chan DeviceEvent, size)}
+	p.mu.Lock()
+	p.subscribers = append(p.subscribers, s)
+	p.mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			for i, have := range p.subscribers {
+				if have == s {
+					p.subscribers = append(p.subscribers[:i], p.subscribers[i+1:]...)
+					break
+				}
+			}
+			close(s.ch)
+		})
 	}
 }
 
-// UpdateDevice updates or inserts a device record.
+// UpdateDevice merges a record into the device it describes, or inserts it
+// as a new device.
 func (p *Processor) UpdateDevice(d DeviceRecord) {
-	if existing, ok := p.devices[d.IP]; ok {
-		d.LastSeen = time.Now()
-		// Preserve missing fields from the previous record.
-		if d.DeviceID == "" {
-			d.DeviceID = existing.DeviceID
+	now := time.Now()
+	if d.LastSeen.IsZero() {
+		d.LastSeen = now
+	}
+	d = d.clone() // the caller keeps its slices
+	prepareRecord(&d)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := p.resolver
+	matches := r.match(&d)
+	replaced := false
+	if len(matches) == 0 {
+		if pos, ok := p.replacedMAC(&d); ok {
+			matches, replaced = []int{pos}, true
 		}
-		if d.Hostname == "" {
-			d.Hostname = existing.Hostname
+	}
+	if len(matches) == 0 {
+		pos := r.merge(&d, nil)
+		cur := r.records[pos].clone()
+		p.publish([]DeviceEvent{{Type: EventDeviceAdded, DeviceID: cur.DeviceID, Time: now, New: cur.IP, Device: cur}})
+		return
+	}
+
+	old := r.records[matches[0]].clone()
+	var absorbed []DeviceRecord
+	for _, pos := range matches[1:] {
+		absorbed = append(absorbed, r.records[pos].clone())
+	}
+	pos := r.merge(&d, matches)
+	cur := r.records[pos]
+	p.followAddress(pos, old, d)
+	if replaced {
+		p.followMAC(pos, old, d)
+	}
+
+	events := diffRecords(old, cur.clone(), now)
+	for _, gone := range absorbed {
+		events = append(events, DeviceEvent{Type: EventDeviceRemoved, DeviceID: gone.DeviceID, Time: now, Old: gone.DeviceID, New: cur.DeviceID, Device: gone})
+	}
+	p.publish(events)
+}
+
+// followAddress moves a device to the address in a newer record that shares
+// its MAC, so a DHCP renumbering reads as an IP change rather than the host
+// gaining a second address.
+func (p *Processor) followAddress(pos int, old, d DeviceRecord) {
+	cur := p.resolver.records[pos]
+	if d.IP == "" || old.IP == "" || d.IP == old.IP || d.MAC == "" || d.MAC != old.MAC {
+		return
+	}
+	if hasIP(d.IPs, old.IP) || !d.LastSeen.After(old.LastSeen) {
+		return
+	}
+	from, err1 := netip.ParseAddr(old.IP)
+	to, err2 := netip.ParseAddr(d.IP)
+	if err1 != nil || err2 != nil || from.Is4() != to.Is4() {
+		return
+	}
+	cur.IP = d.IP
+	ips := cur.IPs[:0]
+	for _, ip := range cur.IPs {
+		if ip != old.IP {
+			ips = append(ips, ip)
 		}
 	}
-	if d.LastSeen.IsZero() {
-		d.LastSeen = time.Now()
+	cur.IPs = ips
+	if p.resolver.keys["ip:"+old.IP] == pos {
+		delete(p.resolver.keys, "ip:"+old.IP)
+	}
+}
+
+// replacedMAC finds the device that held a newer record's primary address
+// under another MAC, so a replaced NIC or a swapped device reads as a MAC
+// change rather than a new device. Devices with a different serial number
+// stay apart.
+func (p *Processor) replacedMAC(d *DeviceRecord) (int, bool) {
+	if d.IP == "" || d.MAC == "" {
+		return 0, false
+	}
+	pos, ok := p.resolver.keys["ip:"+d.IP]
+	if !ok {
+		return 0, false
+	}
+	rec := p.resolver.records[pos]
+	if rec.IP != d.IP || rec.MAC == "" || rec.MAC == d.MAC || !d.LastSeen.After(rec.LastSeen) {
+		return 0, false
+	}
+	sa, sb := strings.TrimSpace(rec.SerialNumber), strings.TrimSpace(d.SerialNumber)
+	if sa != "" && sb != "" && !strings.EqualFold(sa, sb) {
+		return 0, false
+	}
+	return pos, true
+}
+
+// followMAC moves a device found by replacedMAC to the newer record's MAC.
+func (p *Processor) followMAC(pos int, old, d DeviceRecord) {
+	cur := p.resolver.records[pos]
+	cur.MAC = d.MAC
+	cur.Note(d.SourceOf("MAC"), "MAC")
+	if p.resolver.keys["mac:"+old.MAC] == pos {
+		delete(p.resolver.keys, "mac:"+old.MAC)
+	}
+	p.resolver.index(pos)
+}
+
+// ExpireStale removes devices not seen for longer than maxAge and reports
+// each one as removed.
+func (p *Processor) ExpireStale(maxAge time.Duration) []DeviceRecord {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stale []DeviceRecord
+	var events []DeviceEvent
+	for pos, rec := range p.resolver.records {
+		if rec == nil || now.Sub(rec.LastSeen) <= maxAge {
+			continue
+		}
+		gone := rec.clone()
+		stale = append(stale, gone)
+		events = append(events, DeviceEvent{Type: EventDeviceRemoved, DeviceID: gone.DeviceID, Time: now, Old: gone.LastSeen.Format(time.RFC3339), Device: gone})
+		p.resolver.remove(pos)
 	}
-	p.devices[d.IP] = d
+	p.publish(events)
+	return stale
+}
+
+// Devices returns a copy of the merged device records.
+func (p *Processor) Devices() []DeviceRecord {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.resolver.Records()
 }
 
 // DisplayTable prints all stored device info in a table.
 func (p *Processor) DisplayTable() {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	t.SetOutputMirror(Output)
 	t.SetStyle(table.StyleLight)
 	t.Style().Options.SeparateRows = false
 
 	t.AppendHeader(table.Row{
 		"DeviceID", "Hostname", "IP", "MAC", "Status", "Ping(ms)", "LLDP", "CPU%", "Mem%",
 		"InOctets", "OutOctets", "InErr", "OutErr", "Uptime", "Descr", "Type", "Vendor",
-		"Protocols", "SysName", "LastSeen",
+		"Protocols", "SysName", "TLS", "LastSeen",
 	})
 
 	// Sort by IP for consistency
-	var ips []string
-	for ip := range p.devices {
-		ips = append(ips, ip)
-	}
-	sort.Strings(ips)
+	devices := p.Devices()
+	sort.Slice(devices, func(i, j int) bool { return devices[i].IP < devices[j].IP })
 
-	for _, ip := range ips {
-		d := p.devices[ip]
+	for _, d := range devices {
 		t.AppendRow(table.Row{
 			d.DeviceID, d.Hostname, d.IP, d.MAC, d.Status, d.PingMs, d.LLDP, d.CPU, d.Mem,
 			d.IntIn, d.IntOut, d.InErrors, d.OutErrors, d.Uptime, d.Descr, d.Type, d.Vendor,
-			d.Protocols, d.SysName, d.LastSeen.Format("15:04:05"),
+			d.Protocols, d.SysName, tlsSummary(d.TLS), d.LastSeen.Format("15:04:05"),
 		})
 	}
 
-	if len(ips) == 0 {
-		fmt.Println("No devices discovered.")
+	if len(devices) == 0 {
+		fmt.Fprintln(Output, "No devices discovered.")
 		return
 	}
 
 	t.SetColumnConfigs([]table.ColumnConfig{
 		{Name: "Descr", WidthMax: 20, Align: text.AlignLeft},
+		{Name: "TLS", WidthMax: 40, Align: text.AlignLeft},
 	})
 	t.Render()
 }
 
 func DisplayTable(devices []DeviceRecord) {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	t.SetOutputMirror(Output)
 	t.SetStyle(table.StyleLight)
 	t.Style().Options.SeparateRows = false
 
 	t.AppendHeader(table.Row{
 		"DeviceID", "Hostname", "IP", "MAC", "Status", "Ping(ms)", "LLDP", "CPU%", "Mem%",
 		"InOctets", "OutOctets", "InErr", "OutErr", "Uptime", "Descr", "Type", "Vendor",
-		"Protocols", "SysName", "LastSeen",
+		"Protocols", "SysName", "TLS", "LastSeen",
 	})
 
 	for _, d := range devices {
 		t.AppendRow(table.Row{
 			d.DeviceID, d.Hostname, d.IP, d.MAC, d.Status, d.PingMs, d.LLDP, d.CPU, d.Mem,
 			d.IntIn, d.IntOut, d.InErrors, d.OutErrors, d.Uptime, d.Descr, d.Type, d.Vendor,
-			d.Protocols, d.SysName, d.LastSeen.Format("15:04:05"),
+			d.Protocols, d.SysName, tlsSummary(d.TLS), d.LastSeen.Format("15:04:05"),
 		})
 	}
 
 	if len(devices) == 0 {
-		fmt.Println("No devices discovered.")
+		fmt.Fprintln(Output, "No devices discovered.")
 		return
 	}
 
 	t.SetColumnConfigs([]table.ColumnConfig{
 		{Name: "Descr", WidthMax: 20, Align: text.AlignLeft},
+		{Name: "TLS", WidthMax: 40, Align: text.AlignLeft},
 	})
 	t.Render()
-}
\ No newline at end of file
+}
+
+// tlsSummary renders one line per TLS port for the table.
+func tlsSummary(services []models.TLSService) string {
+	lines := make([]string, 0, len(services))
+	for i := range services {
+		lines = append(lines, services[i].Summary())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ToDevice converts a record into a models.Device.
+func (d DeviceRecord) ToDevice() *models.Device {
+	hostname := d.Hostname
+	if hostname == "" {
+		hostname = d.SysName
+	}
+	var protocols []string
+	for _, p := range strings.Split(d.Protocols, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			protocols = append(protocols, p)
+		}
+	}
+	device := models.NewDevice(models.DeviceConfig{
+		Hostname:            hostname,
+		IPAddress:           d.IP,
+		IPAddresses:         d.IPs,
+		DeviceType:          d.Type,
+		Vendor:              d.Vendor,
+		Status:              d.Status,
+		MonitoringProtocols: protocols,
+		MACAddress:          d.MAC,
+		ChassisID:           d.LLDP,
+		Description:         d.Descr,
+		TLSServices:         d.TLS,
+		OpenPorts:           d.OpenPorts,
+		OpenUDPPorts:        d.OpenUDPPorts,
+		SerialNumber:        d.SerialNumber,
+	})
+	device.SetID(d.DeviceID)
+	if len(d.Interfaces) > 0 {
+		ifaces := make([]models.Interface, len(d.Interfaces))
+		for i, iface := range d.Interfaces {
+			iface.SetDeviceID(d.DeviceID)
+			ifaces[i] = iface
+		}
+		device.SetInterfaces(ifaces)
+	}
+	return device
+}
+
+// SetInterfaces attaches an interface table and sets the device-wide
+// octet and error counters to its totals, leaving loopbacks out.
+func (d *DeviceRecord) SetInterfaces(ifaces []models.Interface) {
+	d.Interfaces = ifaces
+	var in, out, inErr, outErr uint64
+	for _, iface := range ifaces {
+		if iface.GetIfType() == probe.IfTypeSoftwareLoopback {
+			continue
+		}
+		c := iface.GetCounters()
+		in += c.InOctets
+		out += c.OutOctets
+		inErr += c.InErrors
+		outErr += c.OutErrors
+	}
+	d.IntIn, d.IntOut = int64(in), int64(out)
+	d.InErrors, d.OutErrors = int64(inErr), int64(outErr)
+}
+
+// SetHostResources fills CPU, memory, uptime and storage from an SNMP poll.
+// Figures the device did not report are left as they were.
+func (d *DeviceRecord) SetHostResources(hr *probe.HostResources) {
+	if hr.HasCPU {
+		d.CPU = math.Round(hr.CPU*10) / 10
+	}
+	if hr.HasMem {
+		d.Mem = math.Round(hr.Mem*10) / 10
+	}
+	if uptime := probe.FormatUptime(hr.Uptime); uptime != "" {
+		d.Uptime = uptime
+		d.Note(SourceSNMP, "Uptime")
+	}
+	d.Storage = hr.Storage
+}
+
+// DevicesFromRecords converts records into models.Device values.
+func DevicesFromRecords(records []DeviceRecord) []models.Device {
+	devices := make([]models.Device, 0, len(records))
+	for _, r := range records {
+		devices = append(devices, *r.ToDevice())
+	}
+	return devices
+}
+
+// DisplayInterfaces prints the interface tables collected over SNMP.
+func DisplayInterfaces(devices []DeviceRecord) {
+	t := table.NewWriter()
+	t.SetOutputMirror(Output)
+	t.SetStyle(table.StyleLight)
+	t.Style().Options.SeparateRows = false
+
+	t.AppendHeader(table.Row{
+		"Device", "ifIndex", "Name", "Alias", "Type", "Admin", "Oper", "Speed", "MTU", "MAC",
+		"InOctets", "OutOctets", "InErr", "OutErr", "InDisc", "OutDisc", "In bps", "Out bps", "In%", "Out%",
+	})
+	rows := 0
+	for _, d := range devices {
+		name := d.Hostname
+		if name == "" {
+			name = d.IP
+		}
+		rates := make(map[int]InterfaceRate, len(d.Rates))
+		for _, r := range d.Rates {
+			rates[r.IfIndex] = r
+		}
+		for _, iface := range d.Interfaces {
+			c := iface.GetCounters()
+			var inBps, outBps, inUtil, outUtil string
+			if r, ok := rates[iface.GetIfIndex()]; ok && r.Valid {
+				inBps, outBps = formatBitrate(r.InBps), formatBitrate(r.OutBps)
+				if iface.GetSpeedBps() > 0 {
+					inUtil, outUtil = fmt.Sprintf("%.1f", r.InUtil), fmt.Sprintf("%.1f", r.OutUtil)
+				}
+			}
+			t.AppendRow(table.Row{
+				name, iface.GetIfIndex(), iface.GetName(), iface.GetAlias(), iface.GetIfType(),
+				iface.GetAdminStatus(), iface.GetStatus(), iface.GetSpeed(), iface.GetMTU(), iface.GetMACAddress(),
+				c.InOctets, c.OutOctets, c.InErrors, c.OutErrors, c.InDiscards, c.OutDiscards,
+				inBps, outBps, inUtil, outUtil,
+			})
+			rows++
+		}
+	}
+	if rows == 0 {
+		return
+	}
+
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Alias", WidthMax: 24, Align: text.AlignLeft},
+	})
+	t.Render()
+}
+
+// DisplayStorage prints the disk and flash utilisation collected over SNMP.
+func DisplayStorage(devices []DeviceRecord) {
+	t := table.NewWriter()
+	t.SetOutputMirror(Output)
+	t.SetStyle(table.StyleLight)
+	t.Style().Options.SeparateRows = false
+
+	t.AppendHeader(table.Row{"Device", "Storage", "Type", "Size", "Used", "Used%"})
+	rows := 0
+	for _, d := range devices {
+		name := d.Hostname
+		if name == "" {
+			name = d.IP
+		}
+		for _, s := range d.Storage {
+			t.AppendRow(table.Row{
+				name, s.Description, s.Type, formatBytes(s.SizeBytes), formatBytes(s.UsedBytes),
+				fmt.Sprintf("%.1f", s.Percent),
+			})
+			rows++
+		}
+	}
+	if rows == 0 {
+		return
+	}
+
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Storage", WidthMax: 32, Align: text.AlignLeft},
+	})
+	t.Render()
+}
+
+// formatBytes renders a size for tables, e.g. "15.6G".
+func formatBytes(n uint64) string {
+	units := []string{"B", "K", "M", "G", "T", "P"}
+	size := float64(n)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return strconv.FormatFloat(size, 'f', 1, 64) + units[i]
+}
+
+// DisplayLinks prints the discovered links in a table.
+func DisplayLinks(links []models.Link) {
+	if len(links) == 0 {
+		fmt.Fprintln(Output, "No links discovered.")
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(Output)
+	t.SetStyle(table.StyleLight)
+	t.Style().Options.SeparateRows = false
+
+	t.AppendHeader(table.Row{"Source", "SourcePort", "Destination", "DestinationPort", "Status"})
+	for _, l := range links {
+		t.AppendRow(table.Row{
+			l.GetSourceDevice(), l.GetSourceInterface(), l.GetDestinationDevice(), l.GetDestinationInterface(), l.GetStatus(),
+		})
+	}
+	t.Render()
+}
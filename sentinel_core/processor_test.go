@@ -0,0 +1,186 @@
+package sentinel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProcessorEvents(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int, d DeviceRecord) DeviceRecord {
+		d.LastSeen = t0.Add(time.Duration(minutes) * time.Minute)
+		return d
+	}
+	const mac1, mac2 = "00:1b:54:00:00:01", "00:1b:54:00:00:02"
+
+	tests := []struct {
+		name    string
+		updates []DeviceRecord
+		want    []string
+		devices int
+	}{
+		{
+			"status from the same source",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", MAC: mac1, Status: "active", Source: SourceARP}),
+				at(1, DeviceRecord{IP: "192.0.2.10", MAC: mac1, Status: "inactive", Source: SourceARP}),
+			},
+			[]string{"device_added  192.0.2.10", "status_changed active inactive"},
+			1,
+		},
+		{
+			"hostname from the same source",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", Hostname: "a", Source: SourceDNS}),
+				at(1, DeviceRecord{IP: "192.0.2.10", Hostname: "b", Source: SourceDNS}),
+			},
+			[]string{"device_added  192.0.2.10", "hostname_changed a b"},
+			1,
+		},
+		{
+			"older record",
+			[]DeviceRecord{
+				at(1, DeviceRecord{IP: "192.0.2.10", Hostname: "b", Source: SourceDNS}),
+				at(0, DeviceRecord{IP: "192.0.2.10", Hostname: "a", Source: SourceDNS}),
+			},
+			[]string{"device_added  192.0.2.10"},
+			1,
+		},
+		{
+			"worse source",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", Hostname: "nas", Source: SourceSNMP}),
+				at(1, DeviceRecord{IP: "192.0.2.10", Hostname: "nas-2", Source: SourceMDNS}),
+			},
+			[]string{"device_added  192.0.2.10"},
+			1,
+		},
+		{
+			"better source",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", Hostname: "nas-2", Source: SourceMDNS}),
+				at(1, DeviceRecord{IP: "192.0.2.10", Hostname: "nas", Source: SourceSNMP}),
+			},
+			[]string{"device_added  192.0.2.10", "hostname_changed nas-2 nas"},
+			1,
+		},
+		{
+			"MAC change on a known address",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", MAC: mac1, Source: SourceARP}),
+				at(1, DeviceRecord{IP: "192.0.2.10", MAC: mac2, Source: SourceARP}),
+				at(2, DeviceRecord{IP: "192.0.2.10", MAC: mac2, Status: "active", Source: SourceARP}),
+			},
+			[]string{"device_added  192.0.2.10", "mac_changed " + mac1 + " " + mac2, "status_changed  active"},
+			1,
+		},
+		{
+			"stale MAC on a known address",
+			[]DeviceRecord{
+				at(1, DeviceRecord{IP: "192.0.2.10", MAC: mac1, Source: SourceARP}),
+				at(0, DeviceRecord{IP: "192.0.2.10", MAC: mac2, Source: SourceARP}),
+			},
+			[]string{"device_added  192.0.2.10", "device_added  192.0.2.10"},
+			2,
+		},
+		{
+			"another device on a known address",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", MAC: mac1, SerialNumber: "FOC1234A", Source: SourceSNMP}),
+				at(1, DeviceRecord{IP: "192.0.2.10", MAC: mac2, SerialNumber: "FOC5678B", Source: SourceSNMP}),
+			},
+			[]string{"device_added  192.0.2.10", "device_added  192.0.2.10"},
+			2,
+		},
+		{
+			"DHCP renumbering",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", MAC: mac1, Source: SourceARP}),
+				at(1, DeviceRecord{IP: "192.0.2.11", MAC: mac1, Source: SourceARP}),
+			},
+			[]string{"device_added  192.0.2.10", "ip_changed 192.0.2.10 192.0.2.11"},
+			1,
+		},
+		{
+			"port opened",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", OpenPorts: []int{22}, Source: SourceNmap}),
+				at(1, DeviceRecord{IP: "192.0.2.10", OpenPorts: []int{22, 443}, Source: SourceNmap}),
+			},
+			[]string{"device_added  192.0.2.10", "port_opened  443"},
+			1,
+		},
+		{
+			"udp port opened",
+			[]DeviceRecord{
+				at(0, DeviceRecord{IP: "192.0.2.10", OpenPorts: []int{22}, Source: SourceNmap}),
+				at(1, DeviceRecord{IP: "192.0.2.10", OpenUDPPorts: []int{161}, Source: SourceNmap}),
+			},
+			[]string{"device_added  192.0.2.10", "port_opened  161/udp"},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor()
+			events, unsubscribe := p.Subscribe(16)
+			defer unsubscribe()
+
+			var ids []string
+			for _, d := range tt.updates {
+				p.UpdateDevice(d)
+			}
+			var got []string
+			for len(events) > 0 {
+				e := <-events
+				got = append(got, string(e.Type)+" "+e.Old+" "+e.New)
+				ids = append(ids, e.DeviceID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events:\n got %q\nwant %q", got, tt.want)
+			}
+			if n := len(p.Devices()); n != tt.devices {
+				t.Errorf("got %d devices, want %d", n, tt.devices)
+			}
+			if tt.devices == 1 {
+				for _, id := range ids {
+					if id != ids[0] {
+						t.Errorf("events carry DeviceIDs %q, want one", ids)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessorKeepsCallerDeviceID(t *testing.T) {
+	// IDs from MergeRecords, SSDP UDNs and WS-Discovery endpoints key the
+	// rate engine, so the processor must not replace them.
+	batch := MergeRecords([]DeviceRecord{
+		{IP: "192.0.2.10", Source: SourcePing},
+		{IP: "192.0.2.10", MAC: "00:1b:54:00:00:01", Source: SourceARP},
+	})
+	updates := []DeviceRecord{
+		batch[0],
+		{DeviceID: "uuid:2fac1234-31f8-11b4-a222-08002b34c003", IP: "192.0.2.20", Source: SourceSSDP},
+	}
+
+	p := NewProcessor()
+	for _, d := range updates {
+		p.UpdateDevice(d)
+	}
+	// A later record without an ID joins the device and keeps its ID
+	p.UpdateDevice(DeviceRecord{IP: "192.0.2.20", MAC: "00:1b:54:00:00:02", Source: SourceARP})
+
+	devices := p.Devices()
+	if len(devices) != len(updates) {
+		t.Fatalf("got %d devices, want %d", len(devices), len(updates))
+	}
+	for i, d := range devices {
+		if d.DeviceID != updates[i].DeviceID {
+			t.Errorf("device %s: DeviceID = %s, want %s", d.IP, d.DeviceID, updates[i].DeviceID)
+		}
+	}
+}
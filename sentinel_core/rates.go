@@ -0,0 +1,249 @@
+package sentinel
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+	"github.com/sofc-t/sentinel/probe"
+)
+
+// Reasons an InterfaceRate carries no rate.
+const (
+	RateFirstSample   = "first sample"
+	RateCounterReset  = "counter reset"
+	RateNoInterval    = "no time elapsed"
+	RateAmbiguousWrap = "32-bit counter may have wrapped more than once"
+)
+
+// CounterSample is one poll of a device's interface counters.
+type CounterSample struct {
+	Time       time.Time
+	SysUpTime  uint32 // agent uptime in hundredths of a second, 0 if unknown
+	Interfaces []models.Interface
+}
+
+// InterfaceRate is the traffic of one interface between two samples.
+type InterfaceRate struct {
+	IfIndex  int
+	Name     string
+	Interval time.Duration
+	// Valid is false when no rate could be computed; Reason says why.
+	Valid  bool
+	Reason string
+
+	InBps, OutBps               float64
+	InPps, OutPps               float64
+	InErrorsPs, OutErrorsPs     float64 // errors per second
+	InDiscardsPs, OutDiscardsPs float64 // discards per second
+	// InUtil and OutUtil are percentages of the interface speed, 0 when the
+	// speed is unknown.
+	InUtil, OutUtil float64
+}
+
+// RateEngine turns cumulative interface counters into rates by remembering
+// the previous sample of every device. It is safe for concurrent use.
+type RateEngine struct {
+	mu   sync.Mutex
+	prev map[string]CounterSample // device ID -> last sample
+}
+
+// NewRateEngine returns an engine with no history.
+func NewRateEngine() *RateEngine {
+	return &RateEngine{prev: make(map[string]CounterSample)}
+}
+
+// Update records a sample and returns the rate of every interface in it
+// since the previous sample of the same device.
+//
+// Counter32 and Counter64 wraps are taken into account. A sysUpTime that
+// went backwards means the agent restarted and its counters started over,
+// so the sample only becomes the new baseline.
+func (e *RateEngine) Update(deviceID string, cur CounterSample) []InterfaceRate {
+	e.mu.Lock()
+	prev, seen := e.prev[deviceID]
+	e.prev[deviceID] = cur
+	e.mu.Unlock()
+
+	rates := make([]InterfaceRate, 0, len(cur.Interfaces))
+	if !seen {
+		for _, iface := range cur.Interfaces {
+			rates = append(rates, noRate(iface, 0, RateFirstSample))
+		}
+		return rates
+	}
+
+	interval, restarted := sampleInterval(prev, cur)
+	before := make(map[int]models.Interface, len(prev.Interfaces))
+	for _, iface := range prev.Interfaces {
+		before[iface.GetIfIndex()] = iface
+	}
+	for _, iface := range cur.Interfaces {
+		old, ok := before[iface.GetIfIndex()]
+		switch {
+		case !ok:
+			rates = append(rates, noRate(iface, interval, RateFirstSample))
+		case restarted:
+			rates = append(rates, noRate(iface, interval, RateCounterReset))
+		case interval <= 0:
+			rates = append(rates, noRate(iface, interval, RateNoInterval))
+		default:
+			rates = append(rates, interfaceRate(old, iface, interval))
+		}
+	}
+	return rates
+}
+
+// Forget drops the history of a device.
+func (e *RateEngine) Forget(deviceID string) {
+	e.mu.Lock()
+	delete(e.prev, deviceID)
+	e.mu.Unlock()
+}
+
+// sampleInterval returns the time between two samples, measured on the
+// agent's clock when both carry sysUpTime, and whether the agent restarted
+// in between.
+func sampleInterval(prev, cur CounterSample) (time.Duration, bool) {
+	wall := cur.Time.Sub(prev.Time)
+	if prev.SysUpTime == 0 || cur.SysUpTime == 0 {
+		return wall, false
+	}
+	if cur.SysUpTime >= prev.SysUpTime {
+		return ticksDuration(cur.SysUpTime - prev.SysUpTime), false
+	}
+	// sysUpTime is a 32-bit TimeTicks and wraps after 497 days. A wrap shows
+	// as a small forward step that matches the wall clock; anything else is
+	// a restart.
+	wrapped := ticksDuration(cur.SysUpTime - prev.SysUpTime) // modulo 2^32
+	if wall > 0 && math.Abs(float64(wrapped-wall)) <= math.Max(float64(wall)/10, float64(5*time.Second)) {
+		return wrapped, false
+	}
+	return wall, true
+}
+
+func ticksDuration(ticks uint32) time.Duration {
+	return time.Duration(ticks) * 10 * time.Millisecond
+}
+
+// interfaceRate computes the rates of one interface between two samples.
+func interfaceRate(old, cur models.Interface, interval time.Duration) InterfaceRate {
+	a, b := old.GetCounters(), cur.GetCounters()
+	if a.HighCapacity != b.HighCapacity {
+		// The agent switched between ifTable and ifXTable counters
+		return noRate(cur, interval, RateCounterReset)
+	}
+	bits := 32
+	if b.HighCapacity {
+		bits = 64
+	}
+	secs := interval.Seconds()
+
+	// A 32-bit octet counter on a fast link can wrap more than once per
+	// interval, which no amount of arithmetic can detect.
+	if bits == 32 && float64(cur.GetSpeedBps())/8*secs >= 1<<32 {
+		return noRate(cur, interval, RateAmbiguousWrap)
+	}
+
+	if countersCleared(a, b, bits, cur.GetSpeedBps(), secs) {
+		return noRate(cur, interval, RateCounterReset)
+	}
+
+	rate := func(prev, now uint64, bits int) float64 {
+		return float64(counterDelta(prev, now, bits)) / secs
+	}
+	r := InterfaceRate{
+		IfIndex:  cur.GetIfIndex(),
+		Name:     cur.GetName(),
+		Interval: interval,
+		Valid:    true,
+		InBps:    rate(a.InOctets, b.InOctets, bits) * 8,
+		OutBps:   rate(a.OutOctets, b.OutOctets, bits) * 8,
+		InPps: rate(a.InUcastPkts, b.InUcastPkts, bits) +
+			rate(a.InMulticastPkts, b.InMulticastPkts, bits) +
+			rate(a.InBroadcastPkts, b.InBroadcastPkts, bits),
+		OutPps: rate(a.OutUcastPkts, b.OutUcastPkts, bits) +
+			rate(a.OutMulticastPkts, b.OutMulticastPkts, bits) +
+			rate(a.OutBroadcastPkts, b.OutBroadcastPkts, bits),
+		// Error and discard counters are Counter32 in every IF-MIB table
+		InErrorsPs:    rate(a.InErrors, b.InErrors, 32),
+		OutErrorsPs:   rate(a.OutErrors, b.OutErrors, 32),
+		InDiscardsPs:  rate(a.InDiscards, b.InDiscards, 32),
+		OutDiscardsPs: rate(a.OutDiscards, b.OutDiscards, 32),
+	}
+	if speed := float64(cur.GetSpeedBps()); speed > 0 {
+		r.InUtil = r.InBps / speed * 100
+		r.OutUtil = r.OutBps / speed * 100
+	}
+	return r
+}
+
+// countersCleared reports whether octet counters that went backwards were
+// reset (by "clear counters" or a line card restart) rather than wrapped. A
+// 64-bit counter cannot wrap from below 2^63 within a poll interval, and a
+// 32-bit wrap that would mean more traffic than the line carries is a reset.
+func countersCleared(a, b models.InterfaceCounters, bits int, speedBps uint64, secs float64) bool {
+	for _, c := range [][2]uint64{{a.InOctets, b.InOctets}, {a.OutOctets, b.OutOctets}} {
+		prev, cur := c[0], c[1]
+		if cur >= prev {
+			continue
+		}
+		if bits == 64 && prev < 1<<63 {
+			return true
+		}
+		if bits == 32 && speedBps > 0 && float64(counterDelta(prev, cur, 32))*8 > float64(speedBps)*secs*1.05 {
+			return true
+		}
+	}
+	return false
+}
+
+// counterDelta returns how far a counter of the given width advanced,
+// assuming at most one wrap.
+func counterDelta(prev, cur uint64, bits int) uint64 {
+	if bits == 32 {
+		return uint64(uint32(cur) - uint32(prev))
+	}
+	return cur - prev
+}
+
+func noRate(iface models.Interface, interval time.Duration, reason string) InterfaceRate {
+	return InterfaceRate{
+		IfIndex:  iface.GetIfIndex(),
+		Name:     iface.GetName(),
+		Interval: interval,
+		Reason:   reason,
+	}
+}
+
+// SetRates attaches interface rates and sets the device-wide throughput to
+// their totals, leaving loopbacks out.
+func (d *DeviceRecord) SetRates(rates []InterfaceRate) {
+	d.Rates = rates
+	d.InBps, d.OutBps = 0, 0
+	loopback := make(map[int]bool)
+	for _, iface := range d.Interfaces {
+		if iface.GetIfType() == probe.IfTypeSoftwareLoopback {
+			loopback[iface.GetIfIndex()] = true
+		}
+	}
+	for _, r := range rates {
+		if r.Valid && !loopback[r.IfIndex] {
+			d.InBps += r.InBps
+			d.OutBps += r.OutBps
+		}
+	}
+}
+
+// formatBitrate renders a rate for tables, e.g. "12.5M".
+func formatBitrate(bps float64) string {
+	units := []string{"", "k", "M", "G", "T"}
+	i := 0
+	for bps >= 1000 && i < len(units)-1 {
+		bps /= 1000
+		i++
+	}
+	return strconv.FormatFloat(bps, 'f', 1, 64) + units[i]
+}
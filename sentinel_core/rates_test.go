@@ -0,0 +1,197 @@
+package sentinel
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sofc-t/sentinel/domain/models"
+)
+
+func TestRateEngineUpdate(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	port := func(speedBps uint64, c models.InterfaceCounters) models.Interface {
+		var iface models.Interface
+		iface.SetIfIndex(1)
+		iface.SetName("Gi1/0/1")
+		iface.SetSpeedBps(speedBps)
+		iface.SetCounters(c)
+		return iface
+	}
+	sample := func(secs int, uptime uint32, iface models.Interface) *CounterSample {
+		return &CounterSample{Time: t0.Add(time.Duration(secs) * time.Second), SysUpTime: uptime, Interfaces: []models.Interface{iface}}
+	}
+	octets := func(in, out uint64, hc bool) models.InterfaceCounters {
+		return models.InterfaceCounters{InOctets: in, OutOctets: out, HighCapacity: hc}
+	}
+	const (
+		fastEthernet = 100_000_000
+		gigabit      = 1_000_000_000
+		tenGigabit   = 10_000_000_000
+	)
+
+	tests := []struct {
+		name      string
+		prev, cur *CounterSample // prev nil: cur is the first sample
+		reason    string         // empty when a rate is expected
+		interval  time.Duration
+		inBps     float64
+		outBps    float64
+		inUtil    float64
+	}{
+		{
+			name:   "first sample",
+			cur:    sample(0, 0, port(fastEthernet, octets(1000, 1000, false))),
+			reason: RateFirstSample,
+		},
+		{
+			name:     "steady Counter32",
+			prev:     sample(0, 0, port(fastEthernet, octets(1000, 2000, false))),
+			cur:      sample(60, 0, port(fastEthernet, octets(751000, 8000, false))),
+			interval: time.Minute,
+			inBps:    100_000, outBps: 800, inUtil: 0.1,
+		},
+		{
+			name:     "Counter32 wrap",
+			prev:     sample(0, 0, port(fastEthernet, octets(1<<32-1000, 0, false))),
+			cur:      sample(60, 0, port(fastEthernet, octets(5000, 6000, false))),
+			interval: time.Minute,
+			inBps:    800, outBps: 800, inUtil: 0.0008,
+		},
+		{
+			name:     "Counter64 step",
+			prev:     sample(0, 0, port(tenGigabit, octets(1<<40, 1<<64-1000, true))),
+			cur:      sample(60, 0, port(tenGigabit, octets(1<<40+75_000_000_000, 5000, true))),
+			interval: time.Minute,
+			inBps:    10_000_000_000, outBps: 800, inUtil: 100,
+		},
+		{
+			name:     "Counter64 reset",
+			prev:     sample(0, 0, port(tenGigabit, octets(1_000_000_000, 1_000_000_000, true))),
+			cur:      sample(60, 0, port(tenGigabit, octets(1000, 1_000_006_000, true))),
+			interval: time.Minute,
+			reason:   RateCounterReset,
+		},
+		{
+			// Going from 3e9 to 1000 would be 1.3 GB through a 10 Mb/s port
+			name:     "Counter32 reset",
+			prev:     sample(0, 0, port(10_000_000, octets(3_000_000_000, 0, false))),
+			cur:      sample(60, 0, port(10_000_000, octets(1000, 6000, false))),
+			interval: time.Minute,
+			reason:   RateCounterReset,
+		},
+		{
+			name:     "sysUpTime preferred to the wall clock",
+			prev:     sample(0, 100_000, port(fastEthernet, octets(0, 0, false))),
+			cur:      sample(65, 106_000, port(fastEthernet, octets(6000, 6000, false))),
+			interval: time.Minute,
+			inBps:    800, outBps: 800, inUtil: 0.0008,
+		},
+		{
+			name:     "sysUpTime went backwards",
+			prev:     sample(0, 100_000, port(fastEthernet, octets(5_000_000, 5_000_000, false))),
+			cur:      sample(60, 500, port(fastEthernet, octets(6000, 6000, false))),
+			interval: time.Minute,
+			reason:   RateCounterReset,
+		},
+		{
+			// 6000 ticks past the 497-day wrap agree with the wall clock
+			name:     "sysUpTime wrap",
+			prev:     sample(0, 1<<32-3000, port(fastEthernet, octets(0, 0, false))),
+			cur:      sample(61, 3000, port(fastEthernet, octets(6000, 6000, false))),
+			interval: time.Minute,
+			inBps:    800, outBps: 800, inUtil: 0.0008,
+		},
+		{
+			// A gigabit port can move 7.5 GB a minute, more than 2^32
+			name:     "ambiguous Counter32 on a fast link",
+			prev:     sample(0, 0, port(gigabit, octets(0, 0, false))),
+			cur:      sample(60, 0, port(gigabit, octets(6000, 6000, false))),
+			interval: time.Minute,
+			reason:   RateAmbiguousWrap,
+		},
+		{
+			name:     "switch to HC counters",
+			prev:     sample(0, 0, port(gigabit, octets(3_000_000_000, 0, false))),
+			cur:      sample(60, 0, port(gigabit, octets(7_000_000_000, 6000, true))),
+			interval: time.Minute,
+			reason:   RateCounterReset,
+		},
+		{
+			name:     "switch from HC counters",
+			prev:     sample(0, 0, port(fastEthernet, octets(7_000_000_000, 0, true))),
+			cur:      sample(60, 0, port(fastEthernet, octets(6000, 6000, false))),
+			interval: time.Minute,
+			reason:   RateCounterReset,
+		},
+		{
+			name:   "no time elapsed",
+			prev:   sample(0, 0, port(fastEthernet, octets(0, 0, false))),
+			cur:    sample(0, 0, port(fastEthernet, octets(6000, 6000, false))),
+			reason: RateNoInterval,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewRateEngine()
+			if tt.prev != nil {
+				e.Update("sw1", *tt.prev)
+			}
+			rates := e.Update("sw1", *tt.cur)
+			if len(rates) != 1 {
+				t.Fatalf("got %d rates, want 1", len(rates))
+			}
+			r := rates[0]
+			if r.IfIndex != 1 || r.Name != "Gi1/0/1" {
+				t.Errorf("rate is for %d/%q", r.IfIndex, r.Name)
+			}
+			if r.Interval != tt.interval {
+				t.Errorf("interval = %v, want %v", r.Interval, tt.interval)
+			}
+			if tt.reason != "" {
+				if r.Valid || r.Reason != tt.reason {
+					t.Errorf("got valid=%v reason %q, want reason %q", r.Valid, r.Reason, tt.reason)
+				}
+				return
+			}
+			if !r.Valid {
+				t.Fatalf("no rate: %s", r.Reason)
+			}
+			if !near(r.InBps, tt.inBps) || !near(r.OutBps, tt.outBps) || !near(r.InUtil, tt.inUtil) {
+				t.Errorf("got in %v out %v util %v, want in %v out %v util %v",
+					r.InBps, r.OutBps, r.InUtil, tt.inBps, tt.outBps, tt.inUtil)
+			}
+		})
+	}
+}
+
+func TestRateEnginePacketsAndErrors(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(secs int, c models.InterfaceCounters) CounterSample {
+		var iface models.Interface
+		iface.SetIfIndex(3)
+		iface.SetSpeedBps(10_000_000_000)
+		iface.SetCounters(c)
+		return CounterSample{Time: t0.Add(time.Duration(secs) * time.Second), Interfaces: []models.Interface{iface}}
+	}
+
+	e := NewRateEngine()
+	e.Update("sw1", sample(0, models.InterfaceCounters{
+		InUcastPkts: 100, InMulticastPkts: 10, InBroadcastPkts: 1, OutUcastPkts: 1 << 40,
+		InErrors: 1<<32 - 10, OutDiscards: 50, HighCapacity: true,
+	}))
+	// Error and discard counters are Counter32 even in ifXTable
+	r := e.Update("sw1", sample(10, models.InterfaceCounters{
+		InUcastPkts: 1100, InMulticastPkts: 110, InBroadcastPkts: 11, OutUcastPkts: 1<<40 + 500,
+		InErrors: 20, OutDiscards: 60, HighCapacity: true,
+	}))[0]
+
+	want := InterfaceRate{IfIndex: 3, Interval: 10 * time.Second, Valid: true, InPps: 111, OutPps: 50, InErrorsPs: 3, OutDiscardsPs: 1}
+	if r != want {
+		t.Errorf("got  %+v\nwant %+v", r, want)
+	}
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}
@@ -0,0 +1,346 @@
+package sentinel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sofc-t/sentinel/domain/models"
+	"github.com/sofc-t/sentinel/probe"
+)
+
+// Topology correlates LLDP/CDP neighbor entries with discovered devices and
+// builds the set of physical links between them.
+type Topology struct {
+	devices []models.Device
+	keys    map[string]int // identity key -> index into devices
+	links   []models.Link
+}
+
+// NewTopology seeds a topology with already discovered devices.
+func NewTopology(devices []models.Device) *Topology {
+	t := &Topology{keys: make(map[string]int)}
+	for _, d := range devices {
+		t.devices = append(t.devices, d)
+		pos := len(t.devices) - 1
+		t.ensureID(pos)
+		t.register(pos)
+	}
+	return t
+}
+
+// AddNeighbors feeds a batch of neighbor entries into the topology.
+func (t *Topology) AddNeighbors(entries []probe.NeighborEntry) {
+	for _, e := range entries {
+		t.AddNeighbor(e)
+	}
+}
+
+// AddNeighbor records one adjacency. Both ends are resolved to devices
+// (creating them when unknown), and the link is merged with the one reported
+// from the opposite side if it already exists.
+func (t *Topology) AddNeighbor(e probe.NeighborEntry) {
+	local := t.resolve(e.LocalChassisID, e.LocalSysName, e.LocalAddress, "", e.Protocol)
+	remote := t.resolve(e.RemoteChassisID, e.RemoteSysName, e.RemoteAddress, e.RemotePlatform, e.Protocol)
+	if local == remote {
+		return
+	}
+
+	localPort := e.LocalPort
+	remotePort := e.RemotePort
+	if remotePort == "" {
+		remotePort = e.RemotePortDescr
+	}
+
+	localID := t.devices[local].GetID()
+	remoteID := t.devices[remote].GetID()
+
+	l := t.findLink(localID, localPort, remoteID, remotePort)
+	if l != nil {
+		// Fill in the port the other side could not name.
+		if l.GetSourceDevice() == localID {
+			fillPort(l.GetSourceInterface, l.SetSourceInterface, localPort)
+			fillPort(l.GetDestinationInterface, l.SetDestinationInterface, remotePort)
+		} else {
+			fillPort(l.GetSourceInterface, l.SetSourceInterface, remotePort)
+			fillPort(l.GetDestinationInterface, l.SetDestinationInterface, localPort)
+		}
+		l.SetID(linkID(l.GetSourceDevice(), l.GetSourceInterface(), l.GetDestinationDevice(), l.GetDestinationInterface()))
+	} else {
+		var link models.Link
+		link.SetSourceDevice(localID)
+		link.SetSourceInterface(localPort)
+		link.SetDestinationDevice(remoteID)
+		link.SetDestinationInterface(remotePort)
+		link.SetID(linkID(localID, localPort, remoteID, remotePort))
+		t.links = append(t.links, link)
+		l = &t.links[len(t.links)-1]
+	}
+
+	t.connect(local, localPort, "", remoteID, remotePort)
+	t.connect(remote, remotePort, e.RemotePortDescr, localID, localPort)
+	l.SetStatus(linkStatus(t.portStatus(local, localPort), t.portStatus(remote, remotePort)))
+}
+
+// portStatus returns the ifOperStatus of a device port, or "" when the port
+// was only named by a neighbor advertisement.
+func (t *Topology) portStatus(pos int, port string) string {
+	ifaces := t.devices[pos].GetInterfaces()
+	if i := findInterface(ifaces, port); i >= 0 {
+		return ifaces[i].GetStatus()
+	}
+	return ""
+}
+
+// linkStatus derives the state of a link from the ifOperStatus of its ends:
+// down if either end is down, up if either end is up, unknown otherwise.
+func linkStatus(a, b string) string {
+	for _, s := range []string{a, b} {
+		switch s {
+		case "down", "lowerLayerDown", "notPresent":
+			return "down"
+		}
+	}
+	if a == "up" || b == "up" {
+		return "up"
+	}
+	return "unknown"
+}
+
+// Devices returns every device in the topology, including neighbors that were
+// only known from LLDP/CDP advertisements.
+func (t *Topology) Devices() []models.Device {
+	return t.devices
+}
+
+// Links returns the deduplicated links sorted by ID.
+func (t *Topology) Links() []models.Link {
+	links := append([]models.Link(nil), t.links...)
+	sort.Slice(links, func(i, j int) bool { return links[i].GetID() < links[j].GetID() })
+	return links
+}
+
+// Network bundles the devices and links into a models.Network.
+func (t *Topology) Network(name string) *models.Network {
+	network := &models.Network{}
+	network.SetID(name)
+	network.SetName(name)
+	network.SetDevices(t.Devices())
+	network.SetLinks(t.Links())
+	return network
+}
+
+// resolve finds the device matching any of the given identifiers, creating a
+// placeholder device when none matches.
+func (t *Topology) resolve(chassisID, sysName, address, platform, protocol string) int {
+	candidates := identityKeys(chassisID, sysName, address)
+	pos := -1
+	for _, k := range candidates {
+		if p, ok := t.keys[k]; ok {
+			pos = p
+			break
+		}
+	}
+
+	if pos < 0 {
+		device := models.NewDevice(models.DeviceConfig{
+			Hostname:            sysName,
+			IPAddress:           address,
+			DeviceType:          "unknown",
+			Status:              "active",
+			MonitoringProtocols: []string{protocol},
+			ChassisID:           chassisID,
+			Description:         platform,
+		})
+		if isMAC(chassisID) {
+			device.SetMACAddress(chassisID)
+		}
+		t.devices = append(t.devices, *device)
+		pos = len(t.devices) - 1
+		t.ensureID(pos)
+	} else {
+		d := &t.devices[pos]
+		if d.GetChassisID() == "" {
+			d.SetChassisID(chassisID)
+		}
+		if d.GetHostname() == "" {
+			d.SetHostname(sysName)
+		}
+		if d.GetIPAddress() == "" {
+			d.SetIPAddress(address)
+		}
+		if d.GetDescription() == "" {
+			d.SetDescription(platform)
+		}
+	}
+
+	t.register(pos)
+	return pos
+}
+
+// register indexes a device under all of its identity keys.
+func (t *Topology) register(pos int) {
+	d := &t.devices[pos]
+	for _, k := range identityKeys(d.GetChassisID(), d.GetHostname(), d.GetIPAddress()) {
+		if _, taken := t.keys[k]; !taken {
+			t.keys[k] = pos
+		}
+	}
+	if mac := strings.ToLower(d.GetMACAddress()); mac != "" {
+		if _, taken := t.keys["chassis:"+mac]; !taken {
+			t.keys["chassis:"+mac] = pos
+		}
+	}
+}
+
+// ensureID gives a device without an ID one derived from its identity.
+func (t *Topology) ensureID(pos int) {
+	d := &t.devices[pos]
+	if d.GetID() != "" {
+		return
+	}
+	switch {
+	case d.GetChassisID() != "":
+		d.SetID(d.GetChassisID())
+	case d.GetHostname() != "":
+		d.SetID(d.GetHostname())
+	case d.GetIPAddress() != "":
+		d.SetID(d.GetIPAddress())
+	case d.GetMACAddress() != "":
+		d.SetID(d.GetMACAddress())
+	default:
+		d.SetID(fmt.Sprintf("device-%d", pos+1))
+	}
+}
+
+// connect marks a device port as attached to the given peer port, adding the
+// interface to the device if it was not known yet.
+func (t *Topology) connect(pos int, port, descr, peerID, peerPort string) {
+	if port == "" {
+		return
+	}
+	d := &t.devices[pos]
+	ifaces := d.GetInterfaces()
+	i := findInterface(ifaces, port)
+	if i < 0 {
+		var iface models.Interface
+		iface.SetID(port)
+		iface.SetName(port)
+		iface.SetDeviceID(d.GetID())
+		ifaces = append(ifaces, iface)
+		i = len(ifaces) - 1
+	}
+	if ifaces[i].GetDescription() == "" {
+		ifaces[i].SetDescription(descr)
+	}
+	ifaces[i].SetConnectedDevice(peerID)
+	if peerPort != "" {
+		ifaces[i].SetConnectedInterface(peerPort)
+	}
+	d.SetInterfaces(ifaces)
+}
+
+// findLink returns the link between two devices that shares at least one port
+// with the given adjacency, in either direction.
+func (t *Topology) findLink(devA, portA, devB, portB string) *models.Link {
+	for i := range t.links {
+		l := &t.links[i]
+		switch {
+		case l.GetSourceDevice() == devA && l.GetDestinationDevice() == devB:
+			if samePort(l.GetSourceInterface(), portA) || samePort(l.GetDestinationInterface(), portB) {
+				return l
+			}
+		case l.GetSourceDevice() == devB && l.GetDestinationDevice() == devA:
+			if samePort(l.GetSourceInterface(), portB) || samePort(l.GetDestinationInterface(), portA) {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+func findInterface(ifaces []models.Interface, port string) int {
+	for i := range ifaces {
+		if samePort(ifaces[i].GetName(), port) || samePort(ifaces[i].GetID(), port) {
+			return i
+		}
+	}
+	return -1
+}
+
+func fillPort(get func() string, set func(string), port string) {
+	if get() == "" && port != "" {
+		set(port)
+	}
+}
+
+// identityKeys returns the lookup keys a device can be matched by.
+func identityKeys(chassisID, sysName, address string) []string {
+	var keys []string
+	if chassisID != "" {
+		keys = append(keys, "chassis:"+strings.ToLower(chassisID))
+	}
+	if name := shortName(sysName); name != "" {
+		keys = append(keys, "name:"+name)
+	}
+	if address != "" {
+		keys = append(keys, "ip:"+address)
+	}
+	return keys
+}
+
+// shortName reduces "SW1.corp.example(FOC1234)" to "sw1" so CDP device IDs,
+// LLDP system names and reverse-DNS names compare equal.
+func shortName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.IndexAny(name, ".("); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// Long interface name prefixes and their common abbreviations, longest first.
+var portAbbreviations = []struct{ long, short string }{
+	{"hundredgigabitethernet", "hu"},
+	{"hundredgige", "hu"},
+	{"fortygigabitethernet", "fo"},
+	{"twentyfivegige", "twe"},
+	{"tengigabitethernet", "te"},
+	{"gigabitethernet", "gi"},
+	{"fastethernet", "fa"},
+	{"port-channel", "po"},
+	{"ethernet", "eth"},
+}
+
+// samePort compares port names, treating "GigabitEthernet1/0/1" and
+// "Gi1/0/1" as equal.
+func samePort(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return normalizePort(a) == normalizePort(b)
+}
+
+func normalizePort(p string) string {
+	p = strings.ToLower(strings.ReplaceAll(p, " ", ""))
+	for _, abbr := range portAbbreviations {
+		if strings.HasPrefix(p, abbr.long) {
+			return abbr.short + p[len(abbr.long):]
+		}
+	}
+	return p
+}
+
+// linkID builds an order-independent identifier for a link.
+func linkID(devA, portA, devB, portB string) string {
+	a := devA + ":" + portA
+	b := devB + ":" + portB
+	if b < a {
+		a, b = b, a
+	}
+	return a + "<->" + b
+}
+
+func isMAC(s string) bool {
+	return len(s) == 17 && strings.Count(s, ":") == 5
+}
@@ -0,0 +1,51 @@
+package sentinel
+
+import (
+	"testing"
+
+	"github.com/sofc-t/sentinel/domain/models"
+	"github.com/sofc-t/sentinel/probe"
+)
+
+func TestTopologyLinkStatus(t *testing.T) {
+	port := func(name, status string) models.Interface {
+		var iface models.Interface
+		iface.SetID(name)
+		iface.SetName(name)
+		iface.SetStatus(status)
+		return iface
+	}
+	sw := models.NewDevice(models.DeviceConfig{
+		Hostname:   "sw1",
+		IPAddress:  "192.0.2.1",
+		ChassisID:  "00:1b:54:00:00:01",
+		Interfaces: []models.Interface{port("GigabitEthernet1/0/1", "up"), port("GigabitEthernet1/0/2", "down")},
+	})
+
+	tests := []struct {
+		localPort string
+		remote    string
+		want      string
+	}{
+		{"Gi1/0/1", "host-a", "up"},
+		{"Gi1/0/2", "host-b", "down"},
+		{"Gi1/0/3", "host-c", "unknown"}, // port not in the interface table
+	}
+	for _, tt := range tests {
+		topo := NewTopology([]models.Device{*sw})
+		topo.AddNeighbor(probe.NeighborEntry{
+			LocalChassisID: "00:1b:54:00:00:01",
+			LocalPort:      tt.localPort,
+			RemoteSysName:  tt.remote,
+			RemotePort:     "eth0",
+			Protocol:       "LLDP-MIB",
+		})
+		links := topo.Links()
+		if len(links) != 1 {
+			t.Fatalf("%s: got %d links, want 1", tt.localPort, len(links))
+		}
+		if got := links[0].GetStatus(); got != tt.want {
+			t.Errorf("%s: link status = %q, want %q", tt.localPort, got, tt.want)
+		}
+	}
+}